@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/auth"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/logging"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+// authenticate validates the bearer token or personal API key on r and
+// checks that it grants scope. When it returns false the error response has
+// already been written.
+func (cfg *apiConfig) authenticate(w http.ResponseWriter, r *http.Request, scope string) (uuid.UUID, bool) {
+	if apiKey, err := auth.GetAPIKey(r.Header); err == nil {
+		return cfg.authenticateAPIKey(w, r, apiKey, scope)
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, r, 401, codeUnauthenticated, "Invalid Authorization header")
+		return uuid.Nil, false
+	}
+
+	claims, err := auth.ParseJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondError(w, r, 401, codeInvalidToken, "Invalid JWT")
+		return uuid.Nil, false
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	// third-party tokens stop working as soon as the user revokes consent,
+	// and consenting again doesn't bring back the ones issued before
+	if claims.ClientID != "" {
+		consent, err := cfg.db.GetOAuthConsent(r.Context(), database.GetOAuthConsentParams{
+			UserID:   claims.UserID,
+			ClientID: claims.ClientID,
+		})
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Database error", "error", err)
+			respondInternalError(w, r)
+			return uuid.Nil, false
+		}
+		// JWT timestamps have second precision
+		if err != nil || consent.RevokedAt.Valid || issuedAt.Before(consent.GrantedAt.Truncate(time.Second)) {
+			respondError(w, r, 401, codeInvalidToken, "Access has been revoked")
+			return uuid.Nil, false
+		}
+		// a token never grants more than the user consents to now
+		claims.Scope = intersectScopes(claims.Scope, consent.Scope)
+	}
+
+	if !claims.HasScope(scope) {
+		respondError(w, r, 403, codeInsufficientScope, "Insufficient scope")
+		return uuid.Nil, false
+	}
+	if !cfg.checkAccountActive(w, r, claims.UserID, issuedAt) {
+		return uuid.Nil, false
+	}
+
+	logging.SetUserID(r.Context(), claims.UserID.String())
+	return claims.UserID, true
+}
+
+// checkAccountActive rejects suspended accounts, accounts pending deletion,
+// and access tokens issued before the account was signed out everywhere.
+// API keys pass a zero issuedAt since they survive a forced logout. When it returns false the
+// error response has already been written.
+func (cfg *apiConfig) checkAccountActive(w http.ResponseWriter, r *http.Request, userId uuid.UUID, issuedAt time.Time) bool {
+	state, err := cfg.db.GetUserAuthState(r.Context(), userId)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return false
+	}
+	if err != nil {
+		respondError(w, r, 401, codeInvalidToken, "Account not found")
+		return false
+	}
+	if state.SuspendedAt.Valid {
+		respondError(w, r, 403, codeAccountSuspended, "Account suspended")
+		return false
+	}
+	// JWT timestamps have second precision
+	if !issuedAt.IsZero() && state.SessionsRevokedAt.Valid &&
+		issuedAt.Before(state.SessionsRevokedAt.Time.Truncate(time.Second)) {
+		respondError(w, r, 401, codeSessionRevoked, "Session has been revoked")
+		return false
+	}
+	// signing in cancels the deletion and issues fresh tokens; until then
+	// API keys and OAuth grants stop working
+	if state.DeletionDueAt.Valid {
+		respondError(w, r, 403, codeAccountPendingDeletion, "Account is scheduled for deletion")
+		return false
+	}
+	return true
+}
+
+// authenticateAPIKey checks a personal API key. Keys act for their owner
+// within their scopes but can never manage keys or other credentials.
+func (cfg *apiConfig) authenticateAPIKey(w http.ResponseWriter, r *http.Request, apiKey, scope string) (uuid.UUID, bool) {
+	prefix, err := auth.APIKeyPrefix(apiKey)
+	if err != nil {
+		respondError(w, r, 401, codeInvalidAPIKey, "Invalid API key")
+		return uuid.Nil, false
+	}
+
+	key, err := cfg.db.GetAPIKeyByPrefix(r.Context(), prefix)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return uuid.Nil, false
+	}
+	if err != nil ||
+		!auth.CheckAPIKeyHash(apiKey, key.HashedKey) ||
+		key.RevokedAt.Valid ||
+		(key.ExpiresAt.Valid && key.ExpiresAt.Time.Before(time.Now())) {
+		respondError(w, r, 401, codeInvalidAPIKey, "Invalid API key")
+		return uuid.Nil, false
+	}
+
+	// keys without explicit scopes get every scope a client could be granted
+	if scope == scopeFirstParty || (key.Scope != "" && !auth.ScopeIncludes(key.Scope, scope)) {
+		respondError(w, r, 403, codeInsufficientScope, "Insufficient scope")
+		return uuid.Nil, false
+	}
+
+	if !cfg.checkAccountActive(w, r, key.UserID, time.Time{}) {
+		return uuid.Nil, false
+	}
+
+	err = cfg.db.TouchAPIKey(r.Context(), key.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating API key last use", "error", err)
+	}
+
+	logging.SetUserID(r.Context(), key.UserID.String())
+	return key.UserID, true
+}
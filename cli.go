@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/mattcollier/boot-go-server/internal/auth"
+	"github.com/mattcollier/boot-go-server/internal/config"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+const usage = `usage: chirpy [command] [arguments]
+
+Commands:
+  serve [flags]                      run the server (the default)
+  config check [flags]               validate the configuration and exit
+  migrate up|down|status|redo        manage the database schema
+  user create -email E [-role R]     create an account
+  user promote -email E [-role R]    change an account's role (default admin)
+  user reset-password -email E       set a new password and sign the user out
+  tokens prune [-older-than D]       delete expired and revoked refresh tokens
+  chirps export [-o FILE]            write every chirp as JSON lines
+  chirps import [-i FILE]            load chirps written by export
+
+serve and config check take the same flags as the config file and
+environment. Other commands read settings from the environment and the
+file named by CHIRPY_CONFIG. Passwords are read from standard input.
+`
+
+// run dispatches to a subcommand. Plain flags with no command still start
+// the server, as they did before there were commands.
+func run(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return serve(args)
+	}
+
+	ctx := context.Background()
+	command, rest := args[0], args[1:]
+	switch command {
+	case "serve":
+		return serve(rest)
+	case "config":
+		if len(rest) == 0 || rest[0] != "check" {
+			return errors.New("usage: config check [flags]")
+		}
+		return checkConfig(rest[1:])
+	case "migrate":
+		return withDatabase(func(db *sql.DB) error {
+			return runMigrate(ctx, db, rest)
+		})
+	case "user", "tokens", "chirps":
+		if len(rest) == 0 {
+			return fmt.Errorf("%s: missing subcommand\n\n%s", command, usage)
+		}
+		sub, subArgs := rest[0], rest[1:]
+		return withDatabase(func(db *sql.DB) error {
+			// CLI commands serve no metrics and run outside any trace, so
+			// their queries, including chirps import's transaction, go
+			// straight to the database without instrumentedDB.
+			q := store.NewPostgres(db)
+			switch command + " " + sub {
+			case "user create":
+				return userCreate(ctx, q, subArgs, os.Stdin)
+			case "user promote":
+				return userPromote(ctx, q, subArgs)
+			case "user reset-password":
+				return userResetPassword(ctx, q, subArgs, os.Stdin)
+			case "tokens prune":
+				return tokensPrune(ctx, q, subArgs)
+			case "chirps export":
+				return chirpsExport(ctx, q, subArgs)
+			case "chirps import":
+				return chirpsImport(ctx, db, subArgs)
+			}
+			return fmt.Errorf("unknown command %q\n\n%s", command+" "+sub, usage)
+		})
+	case "help":
+		fmt.Print(usage)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q\n\n%s", command, usage)
+}
+
+// openDatabase connects to Postgres. sql.Open doesn't connect, so the
+// database is pinged to fail early when it's unreachable.
+func openDatabase(url string) (*sql.DB, error) {
+	connector, err := pq.NewConnector(url)
+	if err != nil {
+		return nil, fmt.Errorf("database connection error: %w", err)
+	}
+	db := sql.OpenDB(tracedConnector{connector})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database unreachable: %w", err)
+	}
+	return db, nil
+}
+
+// withDatabase runs fn with a connection configured the same way as the
+// server's.
+func withDatabase(fn func(db *sql.DB) error) error {
+	conf, err := config.Parse(nil, os.Getenv)
+	if err != nil {
+		return err
+	}
+	if conf.DBURL == "" {
+		return errors.New("database.url (DB_URL) must be set")
+	}
+	db, err := openDatabase(conf.DBURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+func checkConfig(args []string) error {
+	conf, err := config.Load(args, os.Getenv)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	fmt.Printf("Configuration is valid; the server would listen on port %d\n", conf.Port)
+	return nil
+}
+
+// readPassword reads one line from r. Passwords aren't taken as flags since
+// arguments are visible to other users of the machine.
+func readPassword(r io.Reader) (string, error) {
+	if f, ok := r.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			fmt.Fprint(os.Stderr, "Password: ")
+		}
+	}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password given on standard input")
+	}
+	return password, nil
+}
+
+func parseRole(role string) error {
+	if !slices.Contains([]string{roleUser, roleModerator, roleAdmin}, role) {
+		return fmt.Errorf("unknown role %q; want %s, %s or %s", role, roleUser, roleModerator, roleAdmin)
+	}
+	return nil
+}
+
+func userCreate(ctx context.Context, db store.Store, args []string, stdin io.Reader) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the new account")
+	role := fs.String("role", roleUser, "role of the new account")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("user create: -email is required")
+	}
+	if err := parseRole(*role); err != nil {
+		return err
+	}
+
+	password, err := readPassword(stdin)
+	if err != nil {
+		return err
+	}
+	hashed, err := auth.HashPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+	user, err := db.CreateUser(ctx, database.CreateUserParams{
+		Email:          *email,
+		HashedPassword: sql.NullString{String: hashed, Valid: true},
+	})
+	if errors.Is(err, store.ErrConflict) {
+		return fmt.Errorf("user create: %s is already registered", *email)
+	}
+	if err != nil {
+		return err
+	}
+	if *role != roleUser {
+		_, err = db.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: *role})
+		if err != nil {
+			return err
+		}
+	}
+	slog.Info("Created user", "email", *email, "user_id", user.ID, "role", *role)
+	return nil
+}
+
+// userPromote changes an existing account's role. It's how the first admin
+// is made.
+func userPromote(ctx context.Context, db store.Store, args []string) error {
+	fs := flag.NewFlagSet("user promote", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the account")
+	role := fs.String("role", roleAdmin, "role to give the account")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("user promote: -email is required")
+	}
+	if err := parseRole(*role); err != nil {
+		return err
+	}
+
+	user, err := db.GetUserByEmail(ctx, *email)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("user promote: no user with email %s", *email)
+	} else if err != nil {
+		return err
+	}
+	_, err = db.SetUserRole(ctx, database.SetUserRoleParams{ID: user.ID, Role: *role})
+	if err != nil {
+		return err
+	}
+	slog.Info("Changed role", "email", *email, "role", *role)
+	return nil
+}
+
+// userResetPassword sets a password and signs the user out everywhere, as
+// a reset usually means the old one was compromised.
+func userResetPassword(ctx context.Context, db store.Store, args []string, stdin io.Reader) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	email := fs.String("email", "", "email of the account")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("user reset-password: -email is required")
+	}
+
+	user, err := db.GetUserByEmail(ctx, *email)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("user reset-password: no user with email %s", *email)
+	} else if err != nil {
+		return err
+	}
+	password, err := readPassword(stdin)
+	if err != nil {
+		return err
+	}
+	hashed, err := auth.HashPassword(ctx, password)
+	if err != nil {
+		return err
+	}
+	_, err = db.UpdateUser(ctx, database.UpdateUserParams{
+		ID:             user.ID,
+		Email:          user.Email,
+		HashedPassword: sql.NullString{String: hashed, Valid: true},
+	})
+	if err != nil {
+		return err
+	}
+	err = db.RevokeUserRefreshTokens(ctx, uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		return err
+	}
+	_, err = db.RevokeUserSessions(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	slog.Info("Reset password and signed the user out", "email", *email)
+	return nil
+}
+
+func tokensPrune(ctx context.Context, db store.Store, args []string) error {
+	fs := flag.NewFlagSet("tokens prune", flag.ContinueOnError)
+	olderThan := fs.Duration("older-than", 0, "only delete tokens that expired or were revoked at least this long ago")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *olderThan < 0 {
+		return errors.New("tokens prune: -older-than must not be negative")
+	}
+
+	deleted, err := db.DeleteStaleRefreshTokens(ctx, time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+	slog.Info("Deleted refresh tokens", "count", deleted)
+	return nil
+}
+
+// chirpsExport writes every chirp, scheduled ones included, one JSON object
+// per line.
+func chirpsExport(ctx context.Context, db store.Store, args []string) error {
+	fs := flag.NewFlagSet("chirps export", flag.ContinueOnError)
+	output := fs.String("o", "-", "file to write, or - for standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	chirps, err := db.ListAllChirps(ctx)
+	if err != nil {
+		return err
+	}
+	w := io.Writer(os.Stdout)
+	var f *os.File
+	if *output != "-" {
+		f, err = os.Create(*output)
+		if err != nil {
+			return err
+		}
+		// covers the error returns; the close that matters is checked below
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	encoder := json.NewEncoder(bw)
+	for _, chirp := range chirps {
+		if err := encoder.Encode(chirp); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	// a failed close can mean the data never reached the disk
+	if f != nil {
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	slog.Info("Exported chirps", "count", len(chirps))
+	return nil
+}
+
+// chirpsImport loads chirps written by chirpsExport in one transaction.
+// Chirps whose ID already exists are skipped, so an import can be rerun.
+func chirpsImport(ctx context.Context, db *sql.DB, args []string) error {
+	fs := flag.NewFlagSet("chirps import", flag.ContinueOnError)
+	input := fs.String("i", "-", "file to read, or - for standard input")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	r := io.Reader(os.Stdin)
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := store.NewPostgres(tx)
+
+	decoder := json.NewDecoder(bufio.NewReader(r))
+	var imported, skipped int64
+	for line := 1; ; line++ {
+		var chirp database.Chirp
+		err := decoder.Decode(&chirp)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("chirps import: record %d: %w", line, err)
+		}
+		n, err := q.ImportChirp(ctx, database.ImportChirpParams(chirp))
+		if err != nil {
+			return fmt.Errorf("chirps import: chirp %s: %w", chirp.ID, err)
+		}
+		imported += n
+		skipped += 1 - n
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	slog.Info("Imported chirps", "imported", imported, "skipped_existing", skipped)
+	return nil
+}
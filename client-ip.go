@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIP returns the address of the client behind r. A connection from a
+// trusted proxy is followed back through X-Forwarded-For, right to left,
+// until an address that isn't a trusted proxy: that is the client.
+// Anything further left was written by the client and can't be believed.
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	var forwarded []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(hop))
+		}
+	}
+	for i := len(forwarded) - 1; i >= 0 && isTrustedProxy(ip, trusted); i-- {
+		ip = forwarded[i]
+	}
+	return ip
+}
+
+func isTrustedProxy(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
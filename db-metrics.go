@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/metrics"
+	"github.com/mattcollier/boot-go-server/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedDB times every query sqlc runs, labelled with the query name,
+// and traces it when it runs as part of a traced request or job.
+type instrumentedDB struct {
+	db       database.DBTX
+	duration *metrics.HistogramVec
+}
+
+func newInstrumentedDB(db database.DBTX, reg *metrics.Registry) *instrumentedDB {
+	return &instrumentedDB{
+		db: db,
+		duration: reg.NewHistogramVec("chirpy_db_query_duration_seconds",
+			"Database query latency by sqlc query name.", metrics.DefBuckets, "query"),
+	}
+}
+
+func (i *instrumentedDB) observe(query string, start time.Time) {
+	i.duration.Observe(time.Since(start).Seconds(), queryName(query))
+}
+
+// startSpan returns nil outside a trace, so background polling that finds
+// nothing to do doesn't start a trace per query.
+func startSpan(ctx context.Context, query string) trace.Span {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return nil
+	}
+	name := queryName(query)
+	_, span := tracing.Start(ctx, name,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation.name", name),
+	)
+	return span
+}
+
+func endSpan(span trace.Span, err error) {
+	if span != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// a lookup that finds nothing hasn't failed
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
+
+func (i *instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer i.observe(query, time.Now())
+	span := startSpan(ctx, query)
+	result, err := i.db.ExecContext(ctx, query, args...)
+	endSpan(span, err)
+	return result, err
+}
+
+func (i *instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return i.db.PrepareContext(ctx, query)
+}
+
+// QueryContext leaves the span open until the rows are closed, so it covers
+// reading the results. That needs the connection opened by openDatabase;
+// on any other the span ends when the query returns.
+func (i *instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer i.observe(query, time.Now())
+	span := startSpan(ctx, query)
+	if span == nil {
+		return i.db.QueryContext(ctx, query, args...)
+	}
+	pending := &pendingSpan{span: span}
+	rows, err := i.db.QueryContext(context.WithValue(ctx, pendingSpanKey{}, pending), query, args...)
+	if !pending.claimed {
+		endSpan(span, err)
+	}
+	return rows, err
+}
+
+func (i *instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer i.observe(query, time.Now())
+	span := startSpan(ctx, query)
+	row := i.db.QueryRowContext(ctx, query, args...)
+	endSpan(span, row.Err())
+	return row
+}
+
+// queryName extracts Name from the "-- name: Name :kind" header sqlc puts on
+// every query.
+func queryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "unknown"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
+
+// pendingSpan is a query span waiting for tracedConn to hand it to the
+// rows the query returns.
+type pendingSpan struct {
+	span    trace.Span
+	claimed bool
+}
+
+type pendingSpanKey struct{}
+
+// tracedConnector wraps a driver's connections so the rows of a traced
+// query end its span when closed. database/sql hands back *sql.Rows, which
+// can't be wrapped, so this has to happen at the driver.
+type tracedConnector struct {
+	driver.Connector
+}
+
+func (c tracedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tracedConn{conn}, nil
+}
+
+// tracedConn passes through the optional interfaces pq implements, so
+// database/sql uses the connection as it would use pq's directly.
+type tracedConn struct {
+	driver.Conn
+}
+
+func (c tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := queryer.QueryContext(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	pending, _ := ctx.Value(pendingSpanKey{}).(*pendingSpan)
+	if pending == nil || pending.claimed {
+		return rows, nil
+	}
+	pending.claimed = true
+	return &tracedRows{Rows: rows, span: pending.span}, nil
+}
+
+func (c tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// tracedRows ends the query's span once the results have been read.
+type tracedRows struct {
+	driver.Rows
+	span trace.Span
+	err  error
+}
+
+func (r *tracedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return err
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	endSpan(r.span, errors.Join(r.err, err))
+	return err
+}
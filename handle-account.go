@@ -0,0 +1,307 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/auth"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+// accounts scheduled for deletion can still be recovered by signing in
+// during this period
+const accountDeletionGracePeriod = 30 * 24 * time.Hour
+
+func (cfg *apiConfig) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	type deletePayload struct {
+		Password string `json:"password"`
+		// passwordless accounts confirm by typing their email instead
+		Email string `json:"email"`
+	}
+	payload := deletePayload{}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	confirmed := false
+	if user.HashedPassword.Valid {
+		confirmed, err = auth.CheckPasswordHash(r.Context(), payload.Password, user.HashedPassword.String)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error validating password", "error", err)
+			respondInternalError(w, r)
+			return
+		}
+	} else {
+		confirmed = payload.Email != "" && payload.Email == user.Email
+	}
+	if !confirmed {
+		respondError(w, r, 401, codeInvalidCredentials, "Confirmation does not match")
+		return
+	}
+
+	dueAt, err := cfg.db.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{
+		ID:            userId,
+		DeletionDueAt: sql.NullTime{Time: time.Now().Add(accountDeletionGracePeriod), Valid: true},
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error scheduling deletion", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	// scheduling the deletion revoked outstanding access tokens; also sign
+	// the account out everywhere. Signing back in cancels the deletion.
+	err = cfg.db.RevokeUserRefreshTokens(r.Context(), uuid.NullUUID{UUID: userId, Valid: true})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking refresh tokens", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	respondJSON(w, r, 202, map[string]string{
+		"deletion_due_at": dueAt.Time.UTC().Format(time.RFC3339),
+	})
+}
+
+// purgeDeletedUsers permanently removes accounts whose grace period has
+// ended. Chirps, sessions and every other per-user row cascade.
+func (cfg *apiConfig) purgeDeletedUsers(ctx context.Context) error {
+	deleted, err := cfg.db.DeleteUsersPastGracePeriod(ctx)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Purged deleted users", "users", deleted)
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handleExportUser(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	archive, err := cfg.buildUserExport(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error exporting user data", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	filename := fmt.Sprintf("chirpy-export-%s.zip", time.Now().UTC().Format("20060102"))
+	w.Header().Add("Content-Type", "application/zip")
+	w.Header().Add("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.WriteHeader(200)
+	w.Write(archive)
+}
+
+// buildUserExport collects everything stored about a user into a ZIP of JSON
+// documents. Secrets such as password hashes and token values are left out.
+// Every document has its own type, so the format doesn't change along with
+// the database schema.
+func (cfg *apiConfig) buildUserExport(ctx context.Context, userId uuid.UUID) ([]byte, error) {
+	nullUserID := uuid.NullUUID{UUID: userId, Valid: true}
+
+	user, err := cfg.db.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	chirps, err := cfg.db.GetChirpsByAuthor(ctx, nullUserID)
+	if err != nil {
+		return nil, err
+	}
+	scheduled, err := cfg.db.GetScheduledChirps(ctx, nullUserID)
+	if err != nil {
+		return nil, err
+	}
+	chirps = append(chirps, scheduled...)
+	refreshTokens, err := cfg.db.ListUserRefreshTokens(ctx, nullUserID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := cfg.db.ListUserIdentities(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys, err := cfg.db.ListAPIKeys(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := cfg.db.ListWebauthnCredentials(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	consents, err := cfg.db.ListOAuthConsents(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := cfg.db.GetSubscription(ctx, userId)
+	hasSubscription := err == nil
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+
+	type profile struct {
+		ID            uuid.UUID  `json:"id"`
+		CreatedAt     time.Time  `json:"created_at"`
+		UpdatedAt     time.Time  `json:"updated_at"`
+		Email         string     `json:"email"`
+		IsChirpyRed   bool       `json:"is_chirpy_red"`
+		HasPassword   bool       `json:"has_password"`
+		DeletionDueAt *time.Time `json:"deletion_due_at"`
+	}
+	p := profile{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		HasPassword: user.HashedPassword.Valid,
+	}
+	if user.DeletionDueAt.Valid {
+		p.DeletionDueAt = &user.DeletionDueAt.Time
+	}
+
+	type chirp struct {
+		ID        uuid.UUID `json:"id"`
+		CreatedAt time.Time `json:"created_at"`
+		UpdatedAt time.Time `json:"updated_at"`
+		Body      string    `json:"body"`
+		PublishAt time.Time `json:"publish_at"`
+		Published bool      `json:"published"`
+	}
+	posted := make([]chirp, 0, len(chirps))
+	for _, c := range chirps {
+		posted = append(posted, chirp{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			Body:      c.Body,
+			PublishAt: c.PublishAt,
+			Published: c.Published,
+		})
+	}
+
+	type session struct {
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+	}
+	sessions := make([]session, 0, len(refreshTokens))
+	for _, t := range refreshTokens {
+		s := session{CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+		if t.RevokedAt.Valid {
+			s.RevokedAt = &t.RevokedAt.Time
+		}
+		sessions = append(sessions, s)
+	}
+
+	type identity struct {
+		Provider  string    `json:"provider"`
+		Subject   string    `json:"subject"`
+		Email     string    `json:"email"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	linked := make([]identity, 0, len(identities))
+	for _, i := range identities {
+		linked = append(linked, identity{
+			Provider:  i.Provider,
+			Subject:   i.Subject,
+			Email:     i.Email.String,
+			CreatedAt: i.CreatedAt,
+		})
+	}
+
+	keys := make([]apiKeyResponse, 0, len(apiKeys))
+	for _, k := range apiKeys {
+		keys = append(keys, newAPIKeyResponse(k))
+	}
+
+	type passkey struct {
+		CredentialID base64URL  `json:"credential_id"`
+		CreatedAt    time.Time  `json:"created_at"`
+		LastUsedAt   *time.Time `json:"last_used_at"`
+	}
+	registered := make([]passkey, 0, len(passkeys))
+	for _, c := range passkeys {
+		pk := passkey{CredentialID: c.CredentialID, CreatedAt: c.CreatedAt}
+		if c.LastUsedAt.Valid {
+			pk.LastUsedAt = &c.LastUsedAt.Time
+		}
+		registered = append(registered, pk)
+	}
+
+	granted := make([]oauthConsentResponse, 0, len(consents))
+	for _, c := range consents {
+		granted = append(granted, newOAuthConsentResponse(c))
+	}
+
+	type subscription struct {
+		Status           string     `json:"status"`
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
+		CreatedAt        time.Time  `json:"created_at"`
+		UpdatedAt        time.Time  `json:"updated_at"`
+	}
+	var subscribed *subscription
+	if hasSubscription {
+		subscribed = &subscription{Status: sub.Status, CreatedAt: sub.CreatedAt, UpdatedAt: sub.UpdatedAt}
+		if sub.CurrentPeriodEnd.Valid {
+			subscribed.CurrentPeriodEnd = &sub.CurrentPeriodEnd.Time
+		}
+	}
+
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", p},
+		{"chirps.json", posted},
+		{"sessions.json", sessions},
+		{"identities.json", linked},
+		{"api_keys.json", keys},
+		{"passkeys.json", registered},
+		{"oauth_consents.json", granted},
+		{"subscription.json", subscribed},
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.v); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+// adminUserResponse is a user as admins see it. Credentials are never
+// included.
+type adminUserResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	Email             string     `json:"email"`
+	Role              string     `json:"role"`
+	IsChirpyRed       bool       `json:"is_chirpy_red"`
+	HasPassword       bool       `json:"has_password"`
+	SuspendedAt       *time.Time `json:"suspended_at"`
+	SessionsRevokedAt *time.Time `json:"sessions_revoked_at"`
+	DeletionDueAt     *time.Time `json:"deletion_due_at"`
+}
+
+func newAdminUserResponse(u database.User) adminUserResponse {
+	resp := adminUserResponse{
+		ID:          u.ID,
+		CreatedAt:   u.CreatedAt,
+		UpdatedAt:   u.UpdatedAt,
+		Email:       u.Email,
+		Role:        u.Role,
+		IsChirpyRed: u.IsChirpyRed,
+		HasPassword: u.HashedPassword.Valid,
+	}
+	if u.SuspendedAt.Valid {
+		resp.SuspendedAt = &u.SuspendedAt.Time
+	}
+	if u.SessionsRevokedAt.Valid {
+		resp.SessionsRevokedAt = &u.SessionsRevokedAt.Time
+	}
+	if u.DeletionDueAt.Valid {
+		resp.DeletionDueAt = &u.DeletionDueAt.Time
+	}
+	return resp
+}
+
+// adminTargetUser loads the user named by the {user_id} path value. When it
+// returns false the error response has already been written.
+func (cfg *apiConfig) adminTargetUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	userUUID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		respondError(w, r, 404, codeNotFound, "User not found")
+		return database.User{}, false
+	}
+	user, err := cfg.db.GetUserByID(r.Context(), userUUID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "User not found")
+			return database.User{}, false
+		}
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return database.User{}, false
+	}
+	return user, true
+}
+
+func (cfg *apiConfig) handleAdminListUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, offset := 50, 0
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 500 {
+			respondInvalid(w, r, fieldError{Field: "limit", Code: fieldInvalid, Detail: "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+	if s := query.Get("offset"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			respondInvalid(w, r, fieldError{Field: "offset", Code: fieldInvalid, Detail: "Invalid offset"})
+			return
+		}
+		offset = n
+	}
+
+	users, err := cfg.db.SearchUsers(r.Context(), database.SearchUsersParams{
+		Query:  query.Get("q"),
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error searching users", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	resp := make([]adminUserResponse, 0, len(users))
+	for _, u := range users {
+		resp = append(resp, newAdminUserResponse(u))
+	}
+	respondJSON(w, r, 200, resp)
+}
+
+func (cfg *apiConfig) handleAdminGetUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+	respondJSON(w, r, 200, newAdminUserResponse(user))
+}
+
+// handleAdminGetUserChirps lists all of a user's chirps, scheduled ones
+// included.
+func (cfg *apiConfig) handleAdminGetUserChirps(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	nullUserID := uuid.NullUUID{UUID: user.ID, Valid: true}
+	chirps, err := cfg.db.GetChirpsByAuthor(r.Context(), nullUserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting chirps", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	scheduled, err := cfg.db.GetScheduledChirps(r.Context(), nullUserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting scheduled chirps", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	chirps = append(chirps, scheduled...)
+	if chirps == nil {
+		chirps = []database.Chirp{}
+	}
+	respondJSON(w, r, 200, chirps)
+}
+
+func (cfg *apiConfig) handleAdminGetUserSessions(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := cfg.db.ListUserRefreshTokens(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing sessions", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	// the token values themselves are credentials, so leave them out
+	type session struct {
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+	}
+	sessions := make([]session, 0, len(tokens))
+	for _, t := range tokens {
+		s := session{CreatedAt: t.CreatedAt, ExpiresAt: t.ExpiresAt}
+		if t.RevokedAt.Valid {
+			s.RevokedAt = &t.RevokedAt.Time
+		}
+		sessions = append(sessions, s)
+	}
+	respondJSON(w, r, 200, sessions)
+}
+
+// handleAdminSuspendUser blocks an account from signing in or using any
+// existing token or API key until it is unsuspended.
+func (cfg *apiConfig) handleAdminSuspendUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.SuspendUser(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error suspending user", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	err = cfg.db.RevokeUserRefreshTokens(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking refresh tokens", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	respondJSON(w, r, 200, newAdminUserResponse(user))
+}
+
+func (cfg *apiConfig) handleAdminUnsuspendUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.UnsuspendUser(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error unsuspending user", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	respondJSON(w, r, 200, newAdminUserResponse(user))
+}
+
+// handleAdminLogoutUser signs a user out everywhere: refresh tokens are
+// revoked and access tokens issued so far stop being accepted.
+func (cfg *apiConfig) handleAdminLogoutUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.RevokeUserRefreshTokens(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking refresh tokens", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	user, err = cfg.db.RevokeUserSessions(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking sessions", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	respondJSON(w, r, 200, newAdminUserResponse(user))
+}
+
+// handleAdminSetChirpyRed grants or removes Chirpy Red by hand. This
+// replaces whatever subscription state Polka last reported.
+func (cfg *apiConfig) handleAdminSetChirpyRed(w http.ResponseWriter, r *http.Request) {
+	user, ok := cfg.adminTargetUser(w, r)
+	if !ok {
+		return
+	}
+
+	type chirpyRedPayload struct {
+		IsChirpyRed *bool `json:"is_chirpy_red"`
+	}
+	payload := chirpyRedPayload{}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+	if payload.IsChirpyRed == nil {
+		respondInvalid(w, r, fieldError{Field: "is_chirpy_red", Code: fieldRequired, Detail: "is_chirpy_red is required"})
+		return
+	}
+
+	status := "expired"
+	if *payload.IsChirpyRed {
+		status = "active"
+	}
+	_, err := cfg.db.SetSubscription(r.Context(), database.SetSubscriptionParams{
+		UserID: user.ID,
+		Status: status,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating subscription", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	isChirpyRed, err := cfg.db.SyncChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error syncing Chirpy Red", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	if isChirpyRed && !user.IsChirpyRed {
+		cfg.emitEvent(r.Context(), user.ID, eventUserUpgraded, struct {
+			UserID uuid.UUID `json:"user_id"`
+		}{user.ID})
+	}
+
+	user.IsChirpyRed = isChirpyRed
+	respondJSON(w, r, 200, newAdminUserResponse(user))
+}
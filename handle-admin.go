@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+// Values of users.role.
+const (
+	roleUser      = "user"
+	roleModerator = "moderator"
+	roleAdmin     = "admin"
+)
+
+// middlewareAdmin requires an admin for every /admin/ route and records
+// each admin request, reads included, in the audit log. Only the metrics
+// dashboard, which exposes no user data, is left out. The role is read from
+// the database on every request so demotions take effect immediately.
+func (cfg *apiConfig) middlewareAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+		if !ok {
+			return
+		}
+		user, err := cfg.db.GetUserByID(r.Context(), userId)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Database error", "error", err)
+			respondInternalError(w, r)
+			return
+		}
+		if err != nil || user.Role != roleAdmin {
+			respondError(w, r, 403, codeAdminRequired, "Admin access required")
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if r.Method == http.MethodGet && r.URL.Path == "/admin/metrics" {
+			return
+		}
+		err = cfg.db.CreateAuditLogEntry(r.Context(), database.CreateAuditLogEntryParams{
+			ActorID:    uuid.NullUUID{UUID: userId, Valid: true},
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			StatusCode: int32(rec.statusCode()),
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error writing audit log", "method", r.Method, "path", r.URL.Path, "error", err)
+		}
+	})
+}
+
+func (cfg *apiConfig) handleListAuditLog(w http.ResponseWriter, r *http.Request) {
+	entries, err := cfg.db.ListAuditLog(r.Context(), 200)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing audit log", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	if entries == nil {
+		entries = []database.AdminAuditLog{}
+	}
+
+	respondJSON(w, r, 200, entries)
+}
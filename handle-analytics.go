@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/analytics"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/hll"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+// visitorHash identifies a visitor for unique counts without storing their
+// IP. The day is part of the input, so the same person cannot be followed
+// across days, and the key keeps the hashes from being reversed by trying
+// every IP address. The key is its own setting so rotating the JWT secret
+// doesn't reset the day's unique counts. Behind a load balancer the IP
+// comes from X-Forwarded-For, which needs server.trusted_proxies set;
+// otherwise every visitor looks like the balancer.
+func (cfg *apiConfig) visitorHash(r *http.Request, day time.Time) uint64 {
+	ip := clientIP(r, cfg.trustedProxies)
+	mac := hmac.New(sha256.New, cfg.visitorSalt)
+	mac.Write([]byte(day.Format("2006-01-02")))
+	mac.Write([]byte{0})
+	mac.Write([]byte(ip))
+	mac.Write([]byte{0})
+	mac.Write([]byte(r.UserAgent()))
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// loadVisitorSalt returns the configured visitor salt or, when there is
+// none, the one stored in the database. The first instance to start
+// generates it, so every instance hashes visitors the same way.
+func loadVisitorSalt(ctx context.Context, db store.PageViewQueries, configured string) ([]byte, error) {
+	if configured != "" {
+		return []byte(configured), nil
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return db.GetOrCreateVisitorSalt(ctx, salt)
+}
+
+// flushPageViews writes the views counted in memory to page_views. Buckets
+// that fail to write are kept for the next flush.
+func (cfg *apiConfig) flushPageViews(ctx context.Context) error {
+	buckets := cfg.pageViews.Drain()
+	for i, b := range buckets {
+		if err := cfg.flushPageViewBucket(ctx, b); err != nil {
+			cfg.pageViews.Restore(buckets[i:])
+			return err
+		}
+	}
+	return nil
+}
+
+// flushPageViewBucket adds a bucket to its stored row. The sketches are
+// merged by the upsert itself, so instances flushing the same path and day
+// at once don't overwrite each other's visitors.
+func (cfg *apiConfig) flushPageViewBucket(ctx context.Context, b analytics.Bucket) error {
+	encoded, err := b.Visitors.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return cfg.db.UpsertPageView(ctx, database.UpsertPageViewParams{
+		Path:     b.Path,
+		Day:      b.Day,
+		Views:    b.Views,
+		Visitors: encoded,
+	})
+}
+
+// handlePageViewStats returns daily views and estimated unique visitors,
+// for one path or summed over all of them.
+func (cfg *apiConfig) handlePageViewStats(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	days := 30
+	if s := query.Get("days"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 366 {
+			respondInvalid(w, r, fieldError{Field: "days", Code: fieldInvalid, Detail: "Invalid days"})
+			return
+		}
+		days = n
+	}
+	path := query.Get("path")
+	since := analytics.Day(time.Now()).AddDate(0, 0, 1-days)
+
+	var rows []database.PageView
+	var err error
+	if path != "" {
+		rows, err = cfg.db.ListPageViewsByPath(r.Context(), database.ListPageViewsByPathParams{Day: since, Path: path})
+	} else {
+		rows, err = cfg.db.ListPageViews(r.Context(), since)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing page views", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	type dayStats struct {
+		Day            string `json:"day"`
+		Views          int64  `json:"views"`
+		UniqueVisitors uint64 `json:"unique_visitors"`
+	}
+	type stats struct {
+		Path  string `json:"path,omitempty"`
+		Since string `json:"since"`
+		Views int64  `json:"views"`
+		// visitor hashes change daily, so over a range a visitor is counted
+		// once per day they visited
+		VisitorDays uint64     `json:"visitor_days"`
+		Days        []dayStats `json:"days"`
+	}
+
+	resp := stats{Path: path, Since: since.Format("2006-01-02"), Days: []dayStats{}}
+	total := hll.New()
+	var dayViews int64
+	var daySketch *hll.Sketch
+	var current time.Time
+	closeDay := func() {
+		if daySketch == nil {
+			return
+		}
+		resp.Days = append(resp.Days, dayStats{
+			Day:            current.Format("2006-01-02"),
+			Views:          dayViews,
+			UniqueVisitors: daySketch.Count(),
+		})
+		total.Merge(daySketch)
+	}
+	for _, row := range rows {
+		if daySketch == nil || !row.Day.Equal(current) {
+			closeDay()
+			current, dayViews, daySketch = row.Day, 0, hll.New()
+		}
+		sketch := hll.New()
+		if err := sketch.UnmarshalBinary(row.Visitors); err == nil {
+			daySketch.Merge(sketch)
+		}
+		dayViews += row.Views
+		resp.Views += row.Views
+	}
+	closeDay()
+	resp.VisitorDays = total.Count()
+
+	respondJSON(w, r, 200, resp)
+}
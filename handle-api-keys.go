@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/auth"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+type apiKeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Key        string     `json:"key,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+func newAPIKeyResponse(k database.ApiKey) apiKeyResponse {
+	resp := apiKeyResponse{
+		ID:        k.ID,
+		CreatedAt: k.CreatedAt,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    strings.Fields(k.Scope),
+	}
+	if k.ExpiresAt.Valid {
+		resp.ExpiresAt = &k.ExpiresAt.Time
+	}
+	if k.LastUsedAt.Valid {
+		resp.LastUsedAt = &k.LastUsedAt.Time
+	}
+	return resp
+}
+
+func (cfg *apiConfig) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	type apiKeyPayload struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	payload := apiKeyPayload{}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	if payload.Name == "" {
+		respondInvalid(w, r, fieldError{Field: "name", Code: fieldRequired, Detail: "Name is required"})
+		return
+	}
+	if !knownScopes(payload.Scopes) {
+		respondInvalid(w, r, fieldError{Field: "scopes", Code: fieldInvalid, Detail: "Invalid scopes"})
+		return
+	}
+	expiresAt := sql.NullTime{}
+	if payload.ExpiresAt != nil {
+		if payload.ExpiresAt.Before(time.Now()) {
+			respondInvalid(w, r, fieldError{Field: "expires_at", Code: fieldInvalid, Detail: "expires_at must be in the future"})
+			return
+		}
+		expiresAt = sql.NullTime{Time: *payload.ExpiresAt, Valid: true}
+	}
+
+	key, prefix, err := auth.MakeAPIKey()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating API key", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	apiKey, err := cfg.db.CreateAPIKey(r.Context(), database.CreateAPIKeyParams{
+		UserID:    userId,
+		Name:      payload.Name,
+		Prefix:    prefix,
+		HashedKey: auth.HashAPIKey(key),
+		Scope:     strings.Join(payload.Scopes, " "),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating API key", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	// the full key is only ever shown in this response
+	resp := newAPIKeyResponse(apiKey)
+	resp.Key = key
+	respondJSON(w, r, 201, resp)
+}
+
+func (cfg *apiConfig) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	keys, err := cfg.db.ListAPIKeys(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing API keys", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	resp := make([]apiKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		resp = append(resp, newAPIKeyResponse(k))
+	}
+	respondJSON(w, r, 200, resp)
+}
+
+func (cfg *apiConfig) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	keyUUID, err := uuid.Parse(r.PathValue("key_id"))
+	if err != nil {
+		respondError(w, r, 404, codeNotFound, "API key not found")
+		return
+	}
+
+	_, err = cfg.db.RevokeAPIKey(r.Context(), database.RevokeAPIKeyParams{
+		ID:     keyUUID,
+		UserID: userId,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "API key not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error revoking API key", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/entitlements"
+)
+
+func (cfg *apiConfig) userEntitlements(ctx context.Context, userId uuid.UUID) (entitlements.Entitlements, error) {
+	user, err := cfg.db.GetUserByID(ctx, userId)
+	if err != nil {
+		return entitlements.Entitlements{}, err
+	}
+	return cfg.entitlements.ForUser(user.IsChirpyRed), nil
+}
+
+func (cfg *apiConfig) handleGetEntitlements(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	ent, err := cfg.userEntitlements(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading entitlements", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	respondJSON(w, r, 200, ent)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/mattcollier/boot-go-server/internal/migrate"
+)
+
+// handleLivez reports that the process is up. It deliberately checks
+// nothing else: a failing database shouldn't get the server restarted.
+func handleLivez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(http.StatusText(http.StatusOK)))
+}
+
+func checkDatabase(db *sql.DB) func(context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
+		if err := db.PingContext(ctx); err != nil {
+			return nil, err
+		}
+		stats := db.Stats()
+		return struct {
+			OpenConnections int `json:"open_connections"`
+			InUse           int `json:"in_use"`
+		}{stats.OpenConnections, stats.InUse}, nil
+	}
+}
+
+// checkSchemaVersion fails when the database is missing migrations built
+// into this binary, for example after another instance rolled one back.
+func checkSchemaVersion(migrator *migrate.Migrator) func(context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return nil, err
+		}
+		detail := struct {
+			Latest  int64 `json:"latest"`
+			Pending int   `json:"pending"`
+		}{migrator.Latest(), len(pending)}
+		if len(pending) > 0 {
+			return detail, fmt.Errorf("%d migrations pending", len(pending))
+		}
+		return detail, nil
+	}
+}
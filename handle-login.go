@@ -1,15 +1,15 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mattcollier/boot-go-server/internal/auth"
 	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
 )
 
 func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -17,75 +17,81 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 		Email    string `json:"email"`
 	}
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
 	ld := loginDetails{}
-	err := decoder.Decode(&ld)
-	if err != nil {
-		log.Printf("Error decoding message: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+	if !decodeJSONStrict(w, r, &ld) {
 		return
 	}
 
 	user, err := cfg.db.GetUserByEmail(r.Context(), ld.Email)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(401)
-			w.Write([]byte(`{"error":"Incorrect email or password"}`))
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 401, codeInvalidCredentials, "Incorrect email or password")
 		} else {
-			log.Printf("Database error: %s", err)
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(500)
-			w.Write([]byte(`{"error":"Something went wrong"}`))
+			slog.ErrorContext(r.Context(), "Database error", "error", err)
+			respondInternalError(w, r)
 		}
 
 		return
 	}
-	passwordValid, err := auth.CheckPasswordHash(ld.Password, user.HashedPassword.String)
+
+	// users created through an external identity provider have no password
+	if !user.HashedPassword.Valid {
+		respondError(w, r, 401, codeInvalidCredentials, "Incorrect email or password")
+		return
+	}
+
+	passwordValid, err := auth.CheckPasswordHash(r.Context(), ld.Password, user.HashedPassword.String)
 
 	if err != nil {
-		log.Printf("Error validating password: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error validating password", "error", err)
+		respondInternalError(w, r)
 		return
 	}
 
 	if !passwordValid {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
-		w.Write([]byte(`{"error":"Incorrect email or password"}`))
+		respondError(w, r, 401, codeInvalidCredentials, "Incorrect email or password")
+		return
+	}
+
+	cfg.respondWithTokens(w, r, user)
+}
+
+// respondWithTokens issues a new JWT and refresh token pair for user and
+// writes the login response. Every sign-in method ends here.
+func (cfg *apiConfig) respondWithTokens(w http.ResponseWriter, r *http.Request, user database.User) {
+	if user.SuspendedAt.Valid {
+		respondError(w, r, 403, codeAccountSuspended, "Account suspended")
 		return
 	}
 
-	jwtTTL := time.Duration(time.Hour)
-	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, jwtTTL)
+	// signing in during the deletion grace period keeps the account
+	if user.DeletionDueAt.Valid {
+		err := cfg.db.CancelUserDeletion(r.Context(), user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error cancelling deletion", "error", err)
+			respondInternalError(w, r)
+			return
+		}
+	}
+
+	token, err := auth.MakeJWT(user.ID, cfg.jwtSecret, cfg.accessTokenTTL)
 	if err != nil {
-		log.Printf("Error in MakeJWT: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error in MakeJWT", "error", err)
+		respondInternalError(w, r)
 		return
 	}
 
-	// 60 days
-	refreshTokenTTL := time.Duration(time.Hour * 24 * 60)
 	now := time.Now()
 	refreshToken, _ := auth.MakeRefreshToken()
 	_, err = cfg.db.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
 		Token:     refreshToken,
-		ExpiresAt: now.Add(refreshTokenTTL),
+		ExpiresAt: now.Add(cfg.refreshTokenTTL),
 		UserID:    uuid.NullUUID{UUID: user.ID, Valid: true},
 	})
 
 	if err != nil {
-		log.Printf("Database error: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
 		return
 	}
 
@@ -108,17 +114,5 @@ func (cfg *apiConfig) handleLogin(w http.ResponseWriter, r *http.Request) {
 		IsChirpyRed:  user.IsChirpyRed,
 	}
 
-	jsonRedacted, err := json.Marshal(ru)
-
-	if err != nil {
-		log.Printf("Error encoding response: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
-		return
-	}
-
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(jsonRedacted)
+	respondJSON(w, r, 200, ru)
 }
@@ -1,40 +1,138 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	_ "embed"
 	"fmt"
-	"log"
+	"html/template"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/analytics"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/metrics"
 )
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverHits.Add(1)
 		w.Header().Add("Cache-Control", "no-cache")
-		next.ServeHTTP(w, r)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// only count pages that exist so junk paths don't fill page_views
+		if rec.statusCode() < 400 {
+			now := time.Now()
+			cfg.pageViews.Record(r.URL.Path, cfg.visitorHash(r, analytics.Day(now)), now)
+		}
 	})
 }
 
+//go:embed templates/admin-dashboard.html
+var adminDashboardHTML string
+
+var adminDashboardTemplate = template.Must(template.New("admin-dashboard").Parse(adminDashboardHTML))
+
+const (
+	dashboardDays       = 14
+	dashboardTopAuthors = 10
+	dashboardEvents     = 20
+	dashboardBarWidth   = 300
+)
+
+type dashboardDay struct {
+	Day   time.Time
+	Count int64
+	// bar length in pixels, relative to the busiest day
+	Width int64
+}
+
+type adminDashboard struct {
+	Visits         int32
+	Users          int64
+	ChirpyRedUsers int64
+	Chirps         int64
+	ActiveSessions int64
+	Days           int
+	ChirpsPerDay   []dashboardDay
+	TopAuthors     []database.TopAuthorsRow
+	WebhookEvents  []database.WebhookEvent
+}
+
+func (cfg *apiConfig) loadAdminDashboard(ctx context.Context) (adminDashboard, error) {
+	d := adminDashboard{
+		Visits: cfg.fileserverHits.Load(),
+		Days:   dashboardDays,
+	}
+	var err error
+	if d.Users, err = cfg.db.CountUsers(ctx); err != nil {
+		return d, err
+	}
+	if d.ChirpyRedUsers, err = cfg.db.CountChirpyRedUsers(ctx); err != nil {
+		return d, err
+	}
+	if d.Chirps, err = cfg.db.CountChirps(ctx); err != nil {
+		return d, err
+	}
+	if d.ActiveSessions, err = cfg.db.CountActiveSessions(ctx); err != nil {
+		return d, err
+	}
+
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-dashboardDays)
+	perDay, err := cfg.db.CountChirpsPerDay(ctx, since)
+	if err != nil {
+		return d, err
+	}
+	var busiest int64
+	for _, day := range perDay {
+		busiest = max(busiest, day.Count)
+	}
+	for _, day := range perDay {
+		d.ChirpsPerDay = append(d.ChirpsPerDay, dashboardDay{
+			Day:   day.Day,
+			Count: day.Count,
+			Width: day.Count * dashboardBarWidth / busiest,
+		})
+	}
+
+	if d.TopAuthors, err = cfg.db.TopAuthors(ctx, dashboardTopAuthors); err != nil {
+		return d, err
+	}
+	if d.WebhookEvents, err = cfg.db.ListRecentWebhookEvents(ctx, dashboardEvents); err != nil {
+		return d, err
+	}
+	return d, nil
+}
+
 func (cfg *apiConfig) handleMetrics(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
+	dashboard, err := cfg.loadAdminDashboard(r.Context())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading admin dashboard", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	// render first so a template error doesn't leave a half-written page
+	buf := &bytes.Buffer{}
+	if err := adminDashboardTemplate.Execute(buf, dashboard); err != nil {
+		slog.ErrorContext(r.Context(), "Error rendering admin dashboard", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	text := fmt.Sprintf(`
-<html>
-<body>
-	<h1>Welcome, Chirpy Admin</h1>
-	<p>Chirpy has been visited %d times!</p>
-</body>
-</html>`, cfg.fileserverHits.Load())
-	w.Write([]byte(text))
+	w.Write(buf.Bytes())
 }
 
 func (cfg *apiConfig) resetMetrics(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform == "dev" {
 		err := cfg.db.DeleteUsers(r.Context())
 		if err != nil {
-			log.Printf("Error deleting users: %s", err)
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(500)
-			w.Write([]byte(`{"error":"Something went wrong"}`))
+			slog.ErrorContext(r.Context(), "Error deleting users", "error", err)
+			respondInternalError(w, r)
 			return
 		}
 	}
@@ -44,3 +142,74 @@ func (cfg *apiConfig) resetMetrics(w http.ResponseWriter, r *http.Request) {
 	text := fmt.Sprintf("Hits: %d", cfg.fileserverHits.Load())
 	w.Write([]byte(text))
 }
+
+// httpMetrics are the Prometheus metrics served on /metrics.
+type httpMetrics struct {
+	requests *metrics.CounterVec
+	duration *metrics.HistogramVec
+	inFlight *metrics.GaugeVec
+}
+
+func newHTTPMetrics(reg *metrics.Registry) *httpMetrics {
+	return &httpMetrics{
+		requests: reg.NewCounterVec("chirpy_http_requests_total",
+			"HTTP requests by route, method and status code.", "method", "route", "code"),
+		duration: reg.NewHistogramVec("chirpy_http_request_duration_seconds",
+			"HTTP request latency by route and method.", metrics.DefBuckets, "method", "route"),
+		inFlight: reg.NewGaugeVec("chirpy_http_requests_in_flight",
+			"HTTP requests currently being served."),
+	}
+}
+
+// middleware records every request that passes through next, which must be
+// the ServeMux so the matched route pattern is known once it returns.
+func (m *httpMetrics) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		// label by pattern rather than path so IDs don't explode cardinality
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.Inc(r.Method, route, strconv.Itoa(rec.statusCode()))
+		m.duration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) statusCode() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
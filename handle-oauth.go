@@ -0,0 +1,461 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/auth"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+const (
+	// scopeChirpsWrite lets a client post and delete chirps as the user
+	scopeChirpsWrite = "chirps:write"
+	// scopeFirstParty is never granted to third-party clients, so endpoints
+	// requiring it only accept tokens from Chirpy's own login flows
+	scopeFirstParty = ""
+)
+
+// oauthScopes are the scopes third-party clients may request, with the
+// description shown on the consent screen.
+var oauthScopes = map[string]string{
+	scopeChirpsWrite: "Post and delete chirps on your behalf",
+}
+
+const (
+	oauthCodeTTL        = 5 * time.Minute
+	oauthAccessTokenTTL = time.Hour
+)
+
+type oauthClientResponse struct {
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURI  string    `json:"redirect_uri"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handleCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	type clientPayload struct {
+		Name         string   `json:"name"`
+		RedirectURI  string   `json:"redirect_uri"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+	payload := clientPayload{}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	if payload.Name == "" {
+		respondInvalid(w, r, fieldError{Field: "name", Code: fieldRequired, Detail: "Name is required"})
+		return
+	}
+	if !validRedirectURI(payload.RedirectURI) {
+		respondInvalid(w, r, fieldError{Field: "redirect_uri", Code: fieldInvalid, Detail: "redirect_uri must be an https URL or a loopback http URL"})
+		return
+	}
+	if len(payload.Scopes) == 0 || !knownScopes(payload.Scopes) {
+		respondInvalid(w, r, fieldError{Field: "scopes", Code: fieldInvalid, Detail: "Invalid scopes"})
+		return
+	}
+
+	clientID, err := auth.MakeNonce()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating client id", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	// public clients (mobile, single page apps) rely on PKCE alone
+	var clientSecret, hashedSecret string
+	if payload.Confidential {
+		clientSecret, _ = auth.MakeRefreshToken()
+		hashedSecret, err = auth.HashPassword(r.Context(), clientSecret)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error hashing client secret", "error", err)
+			respondInternalError(w, r)
+			return
+		}
+	}
+
+	client, err := cfg.db.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           clientID,
+		Name:         payload.Name,
+		HashedSecret: stringToNullString(hashedSecret),
+		RedirectUri:  payload.RedirectURI,
+		Scope:        strings.Join(payload.Scopes, " "),
+		UserID:       userId,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating oauth client", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	resp := oauthClientResponse{
+		ClientID:     client.ID,
+		ClientSecret: clientSecret,
+		Name:         client.Name,
+		RedirectURI:  client.RedirectUri,
+		Scopes:       strings.Fields(client.Scope),
+		CreatedAt:    client.CreatedAt,
+	}
+	respondJSON(w, r, 201, resp)
+}
+
+// handleGetOAuthClient returns the public details of a client so the
+// consent screen can show who is asking for access.
+func (cfg *apiConfig) handleGetOAuthClient(w http.ResponseWriter, r *http.Request) {
+	client, err := cfg.db.GetOAuthClient(r.Context(), r.PathValue("client_id"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "Client not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	respondJSON(w, r, 200, oauthClientResponse{
+		ClientID:    client.ID,
+		Name:        client.Name,
+		RedirectURI: client.RedirectUri,
+		Scopes:      strings.Fields(client.Scope),
+		CreatedAt:   client.CreatedAt,
+	})
+}
+
+// handleOAuthAuthorize is called by Chirpy's consent screen once the signed
+// in user approves or denies a client's request. It answers with the URL the
+// user agent should be sent back to.
+func (cfg *apiConfig) handleOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	type authorizePayload struct {
+		ResponseType        string `json:"response_type"`
+		ClientID            string `json:"client_id"`
+		RedirectURI         string `json:"redirect_uri"`
+		Scope               string `json:"scope"`
+		State               string `json:"state"`
+		CodeChallenge       string `json:"code_challenge"`
+		CodeChallengeMethod string `json:"code_challenge_method"`
+		Approve             bool   `json:"approve"`
+	}
+	payload := authorizePayload{}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), payload.ClientID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondInvalid(w, r, fieldError{Field: "client_id", Code: fieldInvalid, Detail: "Unknown client"})
+			return
+		}
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	// never redirect to a URI the client did not register
+	if payload.RedirectURI != client.RedirectUri {
+		respondInvalid(w, r, fieldError{Field: "redirect_uri", Code: fieldInvalid, Detail: "redirect_uri does not match the registered value"})
+		return
+	}
+
+	// from here on errors are reported to the client through the redirect
+	scopes := strings.Fields(payload.Scope)
+	switch {
+	case payload.ResponseType != "code":
+		writeOAuthRedirect(w, r, client.RedirectUri, payload.State, url.Values{"error": {"unsupported_response_type"}})
+		return
+	case payload.CodeChallenge == "" || payload.CodeChallengeMethod != "S256":
+		writeOAuthRedirect(w, r, client.RedirectUri, payload.State, url.Values{
+			"error":             {"invalid_request"},
+			"error_description": {"PKCE with S256 is required"},
+		})
+		return
+	case len(scopes) == 0 || !scopesAllowed(scopes, client.Scope):
+		writeOAuthRedirect(w, r, client.RedirectUri, payload.State, url.Values{"error": {"invalid_scope"}})
+		return
+	case !payload.Approve:
+		writeOAuthRedirect(w, r, client.RedirectUri, payload.State, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	_, err = cfg.db.UpsertOAuthConsent(r.Context(), database.UpsertOAuthConsentParams{
+		UserID:   userId,
+		ClientID: client.ID,
+		Scope:    strings.Join(scopes, " "),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error recording consent", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	code, _ := auth.MakeRefreshToken()
+	err = cfg.db.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		Code:          code,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+		ClientID:      client.ID,
+		UserID:        userId,
+		RedirectUri:   client.RedirectUri,
+		Scope:         strings.Join(scopes, " "),
+		CodeChallenge: payload.CodeChallenge,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating authorization code", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	writeOAuthRedirect(w, r, client.RedirectUri, payload.State, url.Values{"code": {code}})
+}
+
+// handleOAuthToken implements the RFC 6749 token endpoint for the
+// authorization_code grant.
+func (cfg *apiConfig) handleOAuthToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Cache-Control", "no-store")
+
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, 400, "invalid_request")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeOAuthError(w, 400, "unsupported_grant_type")
+		return
+	}
+
+	clientID, clientSecret, hasBasic := r.BasicAuth()
+	if hasBasic {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+
+	client, err := cfg.db.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeOAuthError(w, 401, "invalid_client")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		writeOAuthError(w, 500, "server_error")
+		return
+	}
+
+	if client.HashedSecret.Valid {
+		match, err := auth.CheckPasswordHash(r.Context(), clientSecret, client.HashedSecret.String)
+		if err != nil || !match {
+			writeOAuthError(w, 401, "invalid_client")
+			return
+		}
+	}
+
+	// codes are single use, so consume it before validating the rest
+	code, err := cfg.db.ConsumeOAuthAuthorizationCode(r.Context(), r.PostForm.Get("code"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			writeOAuthError(w, 400, "invalid_grant")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		writeOAuthError(w, 500, "server_error")
+		return
+	}
+
+	if code.ClientID != client.ID ||
+		code.RedirectUri != r.PostForm.Get("redirect_uri") ||
+		code.ExpiresAt.Before(time.Now()) ||
+		!auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		writeOAuthError(w, 400, "invalid_grant")
+		return
+	}
+
+	consent, err := cfg.db.GetOAuthConsent(r.Context(), database.GetOAuthConsentParams{
+		UserID:   code.UserID,
+		ClientID: client.ID,
+	})
+	// a code issued before consent was revoked and granted again is stale
+	if err != nil || consent.RevokedAt.Valid || code.CreatedAt.Before(consent.GrantedAt) {
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Database error", "error", err)
+		}
+		writeOAuthError(w, 400, "invalid_grant")
+		return
+	}
+
+	scopes := strings.Fields(code.Scope)
+	accessToken, err := auth.MakeScopedJWT(code.UserID, cfg.jwtSecret, oauthAccessTokenTTL, client.ID, scopes)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error in MakeScopedJWT", "error", err)
+		writeOAuthError(w, 500, "server_error")
+		return
+	}
+
+	type tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Scope       string `json:"scope"`
+	}
+	respondJSON(w, r, 200, tokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenTTL.Seconds()),
+		Scope:       code.Scope,
+	})
+}
+
+type oauthConsentResponse struct {
+	ClientID  string    `json:"client_id"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func newOAuthConsentResponse(c database.OauthConsent) oauthConsentResponse {
+	return oauthConsentResponse{
+		ClientID:  c.ClientID,
+		Scopes:    strings.Fields(c.Scope),
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
+
+func (cfg *apiConfig) handleListOAuthConsents(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	consents, err := cfg.db.ListOAuthConsents(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing consents", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	resp := make([]oauthConsentResponse, 0, len(consents))
+	for _, c := range consents {
+		resp = append(resp, newOAuthConsentResponse(c))
+	}
+
+	respondJSON(w, r, 200, resp)
+}
+
+func (cfg *apiConfig) handleRevokeOAuthConsent(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.RevokeOAuthConsent(r.Context(), database.RevokeOAuthConsentParams{
+		UserID:   userId,
+		ClientID: r.PathValue("client_id"),
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "Consent not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error revoking consent", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
+
+// writeOAuthRedirect answers the consent screen with the client redirect
+// URI carrying params and the client's state.
+func writeOAuthRedirect(w http.ResponseWriter, r *http.Request, redirectURI, state string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Invalid registered redirect_uri", "redirect_uri", redirectURI, "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+
+	respondJSON(w, r, 200, map[string]string{"redirect_to": u.String()})
+}
+
+// writeOAuthError writes an RFC 6749 section 5.2 error response.
+func writeOAuthError(w http.ResponseWriter, status int, code string) {
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"error":"%s"}`, code)
+}
+
+func validRedirectURI(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	if u.Scheme == "https" {
+		return true
+	}
+	host := u.Hostname()
+	return u.Scheme == "http" && (host == "localhost" || host == "127.0.0.1" || host == "::1")
+}
+
+func knownScopes(scopes []string) bool {
+	for _, s := range scopes {
+		if _, ok := oauthScopes[s]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// scopesAllowed reports whether every requested scope was registered by the
+// client.
+func scopesAllowed(requested []string, registered string) bool {
+	for _, s := range requested {
+		if !auth.ScopeIncludes(registered, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// intersectScopes returns the scopes in granted that are also in allowed.
+func intersectScopes(granted, allowed string) string {
+	var scopes []string
+	for _, s := range strings.Fields(granted) {
+		if auth.ScopeIncludes(allowed, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
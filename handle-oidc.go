@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/auth"
+	"github.com/mattcollier/boot-go-server/internal/config"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/oidc"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+// how long a user has to complete the provider's sign-in page
+const oidcLoginStateTTL = 10 * time.Minute
+
+var errOIDCEmailTaken = errors.New("email belongs to another account")
+
+// errOIDCEmailUnverified is returned instead of creating an account for an
+// email the provider hasn't verified, which would let anyone take the
+// address before its owner signs up.
+var errOIDCEmailUnverified = errors.New("email not verified by the identity provider")
+
+// newOIDCProviders sets up the configured providers by name. Nothing is
+// fetched until a provider is first used.
+func newOIDCProviders(configs []config.OIDCProvider) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider, len(configs))
+	for _, c := range configs {
+		providers[c.Name] = oidc.NewProvider(oidc.Config{
+			Issuer:       c.Issuer,
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			RedirectURL:  c.RedirectURL,
+		}, nil)
+	}
+	return providers
+}
+
+func (cfg *apiConfig) handleOIDCStart(w http.ResponseWriter, r *http.Request) {
+	providerName := r.PathValue("provider")
+	provider, ok := cfg.oidcProviders[providerName]
+	if !ok {
+		respondError(w, r, 404, codeNotFound, "Unknown identity provider")
+		return
+	}
+
+	state, errState := auth.MakeNonce()
+	nonce, errNonce := auth.MakeNonce()
+	verifier, errVerifier := auth.MakePKCEVerifier()
+	if err := errors.Join(errState, errNonce, errVerifier); err != nil {
+		slog.ErrorContext(r.Context(), "Error generating OIDC parameters", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	err := cfg.db.CreateOIDCLoginState(r.Context(), database.CreateOIDCLoginStateParams{
+		State:        state,
+		ExpiresAt:    time.Now().Add(oidcLoginStateTTL),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, auth.PKCEChallenge(verifier))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error building authorization URL", "provider", providerName, "error", err)
+		respondError(w, r, 502, codeIdentityProviderUnavailable, "Identity provider unavailable")
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (cfg *apiConfig) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	providerName := r.PathValue("provider")
+	provider, ok := cfg.oidcProviders[providerName]
+	if !ok {
+		respondError(w, r, 404, codeNotFound, "Unknown identity provider")
+		return
+	}
+
+	queryParams := r.URL.Query()
+	if queryParams.Get("error") != "" {
+		respondError(w, r, 401, codeSignInIncomplete, "Sign in was not completed")
+		return
+	}
+
+	// states are single use, so consume it before doing anything else
+	loginState, err := cfg.db.ConsumeOIDCLoginState(r.Context(), queryParams.Get("state"))
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Database error", "error", err)
+			respondInternalError(w, r)
+			return
+		}
+		respondError(w, r, 400, codeInvalidState, "Invalid state")
+		return
+	}
+
+	if loginState.Provider != providerName || loginState.ExpiresAt.Before(time.Now()) {
+		respondError(w, r, 400, codeInvalidState, "Invalid state")
+		return
+	}
+
+	rawIDToken, err := provider.Exchange(r.Context(), queryParams.Get("code"), loginState.CodeVerifier)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Error exchanging code", "provider", providerName, "error", err)
+		respondError(w, r, 401, codeSignInIncomplete, "Sign in was not completed")
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Context(), rawIDToken, loginState.Nonce)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Invalid ID token", "provider", providerName, "error", err)
+		respondError(w, r, 401, codeIdentityProviderError, "Invalid ID token")
+		return
+	}
+
+	if claims.Email == "" {
+		respondError(w, r, 400, codeIdentityProviderError, "Identity provider did not share an email address")
+		return
+	}
+
+	user, err := cfg.userForIdentity(r.Context(), providerName, claims)
+	if err != nil {
+		if errors.Is(err, errOIDCEmailTaken) {
+			respondError(w, r, 409, codeEmailTaken, "Email is already registered")
+			return
+		}
+		if errors.Is(err, errOIDCEmailUnverified) {
+			respondError(w, r, 400, codeIdentityProviderError, "Identity provider has not verified the email address")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error linking identity", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	cfg.respondWithTokens(w, r, user)
+}
+
+// userForIdentity returns the user linked to an external identity, linking
+// it to an existing account with the same verified email or creating a new
+// passwordless account when there is none and the email is verified.
+func (cfg *apiConfig) userForIdentity(ctx context.Context, providerName string, claims oidc.Claims) (database.User, error) {
+	identity, err := cfg.db.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Provider: providerName,
+		Subject:  claims.Subject,
+	})
+	if err == nil {
+		return cfg.db.GetUserByID(ctx, identity.UserID)
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return database.User{}, err
+	}
+
+	user, err := cfg.db.GetUserByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		// an unverified email would let anyone claim an existing account
+		if !claims.EmailVerified {
+			return database.User{}, errOIDCEmailTaken
+		}
+	case errors.Is(err, store.ErrNotFound):
+		if !claims.EmailVerified {
+			return database.User{}, errOIDCEmailUnverified
+		}
+		created, err := cfg.db.CreateUser(ctx, database.CreateUserParams{
+			Email: claims.Email,
+		})
+		if errors.Is(err, store.ErrConflict) {
+			// registered since the lookup above
+			return database.User{}, errOIDCEmailTaken
+		}
+		if err != nil {
+			return database.User{}, err
+		}
+		user = database.User{
+			ID:          created.ID,
+			CreatedAt:   created.CreatedAt,
+			UpdatedAt:   created.UpdatedAt,
+			Email:       created.Email,
+			IsChirpyRed: created.IsChirpyRed,
+		}
+	default:
+		return database.User{}, err
+	}
+
+	_, err = cfg.db.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    stringToNullString(claims.Email),
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return user, nil
+}
+
+// purgeExpiredLoginStates removes the state of sign-ins that were started
+// and never completed.
+func (cfg *apiConfig) purgeExpiredLoginStates(ctx context.Context) error {
+	deleted, err := cfg.db.DeleteExpiredOIDCLoginStates(ctx)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		slog.InfoContext(ctx, "Purged expired OIDC login states", "states", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+	"github.com/mattcollier/boot-go-server/internal/webauthn"
+)
+
+const (
+	passkeyCeremonyTimeout  = 5 * time.Minute
+	ceremonyRegistration    = "registration"
+	ceremonyAuthentication  = "authentication"
+	passkeyCredentialType   = "public-key"
+	passkeyUserVerification = "preferred"
+)
+
+// base64URL decodes the unpadded base64url strings browsers send, also
+// tolerating padding.
+type base64URL []byte
+
+func (b *base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+func (b base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+type passkeyCredentialDescriptor struct {
+	Type string    `json:"type"`
+	ID   base64URL `json:"id"`
+}
+
+func (cfg *apiConfig) handlePasskeyRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	existing, err := cfg.db.ListWebauthnCredentials(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	challenge, ok := cfg.newPasskeyChallenge(w, r, ceremonyRegistration, uuid.NullUUID{UUID: userId, Valid: true})
+	if !ok {
+		return
+	}
+
+	type rpEntity struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	type userEntity struct {
+		ID          base64URL `json:"id"`
+		Name        string    `json:"name"`
+		DisplayName string    `json:"displayName"`
+	}
+	type credParam struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	}
+	type authenticatorSelection struct {
+		ResidentKey      string `json:"residentKey"`
+		UserVerification string `json:"userVerification"`
+	}
+	type creationOptions struct {
+		Challenge              string                        `json:"challenge"`
+		RP                     rpEntity                      `json:"rp"`
+		User                   userEntity                    `json:"user"`
+		PubKeyCredParams       []credParam                   `json:"pubKeyCredParams"`
+		Timeout                int64                         `json:"timeout"`
+		Attestation            string                        `json:"attestation"`
+		AuthenticatorSelection authenticatorSelection        `json:"authenticatorSelection"`
+		ExcludeCredentials     []passkeyCredentialDescriptor `json:"excludeCredentials"`
+	}
+
+	options := creationOptions{
+		Challenge: challenge,
+		RP:        rpEntity{ID: cfg.webauthnRP.ID, Name: cfg.webauthnRP.Name},
+		User: userEntity{
+			ID:          user.ID[:],
+			Name:        user.Email,
+			DisplayName: user.Email,
+		},
+		PubKeyCredParams: []credParam{
+			{Type: passkeyCredentialType, Alg: webauthn.AlgES256},
+			{Type: passkeyCredentialType, Alg: webauthn.AlgRS256},
+		},
+		Timeout:     passkeyCeremonyTimeout.Milliseconds(),
+		Attestation: "none",
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: passkeyUserVerification,
+		},
+		ExcludeCredentials: make([]passkeyCredentialDescriptor, 0, len(existing)),
+	}
+	for _, c := range existing {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, passkeyCredentialDescriptor{
+			Type: passkeyCredentialType,
+			ID:   c.CredentialID,
+		})
+	}
+
+	respondJSON(w, r, 200, options)
+}
+
+func (cfg *apiConfig) handlePasskeyRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+
+	type registrationPayload struct {
+		RawID    base64URL `json:"rawId"`
+		Type     string    `json:"type"`
+		Response struct {
+			ClientDataJSON    base64URL `json:"clientDataJSON"`
+			AttestationObject base64URL `json:"attestationObject"`
+		} `json:"response"`
+	}
+	payload := registrationPayload{}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	challenge, ok := cfg.consumePasskeyChallenge(w, r, payload.Response.ClientDataJSON, ceremonyRegistration)
+	if !ok {
+		return
+	}
+	if !challenge.UserID.Valid || challenge.UserID.UUID != userId {
+		respondError(w, r, 400, codePasskeyFailed, "Invalid challenge")
+		return
+	}
+
+	cred, err := cfg.webauthnRP.VerifyRegistration(challenge.Challenge, payload.Response.ClientDataJSON, payload.Response.AttestationObject)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Passkey registration failed", "error", err)
+		respondError(w, r, 400, codePasskeyFailed, "Passkey registration failed")
+		return
+	}
+	// rawId is what the browser will send to sign in, so it must name the
+	// credential the authenticator attested
+	if !bytes.Equal(payload.RawID, cred.ID) {
+		respondError(w, r, 400, codePasskeyFailed, "Credential ID does not match the attestation")
+		return
+	}
+
+	created, err := cfg.db.CreateWebauthnCredential(r.Context(), database.CreateWebauthnCredentialParams{
+		UserID:       userId,
+		CredentialID: cred.ID,
+		PublicKey:    cred.PublicKey,
+		SignCount:    int64(cred.SignCount),
+		Aaguid:       cred.AAGUID,
+	})
+	if errors.Is(err, store.ErrConflict) {
+		respondError(w, r, 409, codeConflict, "Passkey is already registered")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving passkey", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	type passkeyResponse struct {
+		ID           uuid.UUID `json:"id"`
+		CreatedAt    time.Time `json:"created_at"`
+		CredentialID base64URL `json:"credential_id"`
+	}
+	respondJSON(w, r, 201, passkeyResponse{
+		ID:           created.ID,
+		CreatedAt:    created.CreatedAt,
+		CredentialID: created.CredentialID,
+	})
+}
+
+// handlePasskeyLoginBegin starts a discoverable-credential sign in, so the
+// user does not need to type an email first.
+func (cfg *apiConfig) handlePasskeyLoginBegin(w http.ResponseWriter, r *http.Request) {
+	challenge, ok := cfg.newPasskeyChallenge(w, r, ceremonyAuthentication, uuid.NullUUID{})
+	if !ok {
+		return
+	}
+
+	type requestOptions struct {
+		Challenge        string                        `json:"challenge"`
+		RPID             string                        `json:"rpId"`
+		Timeout          int64                         `json:"timeout"`
+		UserVerification string                        `json:"userVerification"`
+		AllowCredentials []passkeyCredentialDescriptor `json:"allowCredentials"`
+	}
+	respondJSON(w, r, 200, requestOptions{
+		Challenge:        challenge,
+		RPID:             cfg.webauthnRP.ID,
+		Timeout:          passkeyCeremonyTimeout.Milliseconds(),
+		UserVerification: passkeyUserVerification,
+		AllowCredentials: []passkeyCredentialDescriptor{},
+	})
+}
+
+func (cfg *apiConfig) handlePasskeyLoginFinish(w http.ResponseWriter, r *http.Request) {
+	type assertionPayload struct {
+		RawID    base64URL `json:"rawId"`
+		Type     string    `json:"type"`
+		Response struct {
+			ClientDataJSON    base64URL `json:"clientDataJSON"`
+			AuthenticatorData base64URL `json:"authenticatorData"`
+			Signature         base64URL `json:"signature"`
+		} `json:"response"`
+	}
+	payload := assertionPayload{}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	challenge, ok := cfg.consumePasskeyChallenge(w, r, payload.Response.ClientDataJSON, ceremonyAuthentication)
+	if !ok {
+		return
+	}
+
+	stored, err := cfg.db.GetWebauthnCredential(r.Context(), payload.RawID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 401, codePasskeyFailed, "Unknown passkey")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	signCount, err := cfg.webauthnRP.VerifyAssertion(webauthn.Credential{
+		ID:        stored.CredentialID,
+		PublicKey: stored.PublicKey,
+		SignCount: uint32(stored.SignCount),
+	}, challenge.Challenge, payload.Response.ClientDataJSON, payload.Response.AuthenticatorData, payload.Response.Signature)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Passkey sign in failed", "error", err)
+		respondError(w, r, 401, codePasskeyFailed, "Passkey sign in failed")
+		return
+	}
+
+	err = cfg.db.UpdateWebauthnSignCount(r.Context(), database.UpdateWebauthnSignCountParams{
+		ID:        stored.ID,
+		SignCount: int64(signCount),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating passkey sign count", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), stored.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	cfg.respondWithTokens(w, r, user)
+}
+
+// newPasskeyChallenge creates and stores a challenge for a ceremony. When it
+// returns false the error response has already been written.
+func (cfg *apiConfig) newPasskeyChallenge(w http.ResponseWriter, r *http.Request, ceremony string, userID uuid.NullUUID) (string, bool) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating challenge", "error", err)
+		respondInternalError(w, r)
+		return "", false
+	}
+
+	err = cfg.db.CreateWebauthnChallenge(r.Context(), database.CreateWebauthnChallengeParams{
+		Challenge: challenge,
+		ExpiresAt: time.Now().Add(passkeyCeremonyTimeout),
+		Ceremony:  ceremony,
+		UserID:    userID,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return "", false
+	}
+	return challenge, true
+}
+
+// consumePasskeyChallenge looks up and deletes the challenge echoed in
+// clientDataJSON. When it returns false the error response has already been
+// written.
+func (cfg *apiConfig) consumePasskeyChallenge(w http.ResponseWriter, r *http.Request, clientDataJSON []byte, ceremony string) (database.WebauthnChallenge, bool) {
+	echoed, err := webauthn.ClientDataChallenge(clientDataJSON)
+	if err != nil {
+		respondError(w, r, 400, codePasskeyFailed, "Invalid client data")
+		return database.WebauthnChallenge{}, false
+	}
+
+	challenge, err := cfg.db.ConsumeWebauthnChallenge(r.Context(), echoed)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return database.WebauthnChallenge{}, false
+	}
+	if err != nil || challenge.Ceremony != ceremony || challenge.ExpiresAt.Before(time.Now()) {
+		respondError(w, r, 400, codePasskeyFailed, "Invalid challenge")
+		return database.WebauthnChallenge{}, false
+	}
+	return challenge, true
+}
@@ -1,81 +1,174 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/mattcollier/boot-go-server/internal/auth"
 	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+	"github.com/mattcollier/boot-go-server/internal/webhook"
+)
+
+const (
+	polkaSource = "polka"
+	// how far a webhook's signed timestamp may drift from our clock
+	polkaSignatureTolerance = 5 * time.Minute
+	polkaMaxBodyBytes       = 1 << 20
+)
+
+// polkaSubscriptionStatus maps Polka lifecycle events to the subscription
+// status they leave behind. Events not listed here are acknowledged and
+// ignored.
+var polkaSubscriptionStatus = map[string]string{
+	"user.upgraded":       "active",
+	"user.renewed":        "active",
+	"user.payment_failed": "past_due",
+	"user.canceled":       "canceled",
+	"user.downgraded":     "expired",
+	"user.refunded":       "refunded",
+}
+
+var (
+	errPolkaInvalidUser = errors.New("invalid user id")
+	errPolkaUnknownUser = errors.New("unknown user")
 )
 
 type PolkaWebookPayload struct {
+	ID    string `json:"id"`
 	Event string `json:"event"`
 	Data  struct {
 		UserID string `json:"user_id"`
+		// end of the billing period the event applies to, if Polka sent one
+		CurrentPeriodEnd *time.Time `json:"current_period_end"`
 	} `json:"data"`
 }
 
 func (cfg *apiConfig) handlePolkaWebhook(w http.ResponseWriter, r *http.Request) {
-	apiKey, err := auth.GetAPIKey(r.Header)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, polkaMaxBodyBytes))
 	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
-		w.Write([]byte(`{"error":"Invalid Authorization header"}`))
+		respondError(w, r, 413, codeBodyTooLarge, "Request body too large")
 		return
 	}
 
-	if apiKey != cfg.polkaAPIKey {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
+	// the signature covers the raw body, so verify before decoding
+	timestamp := r.Header.Get("Polka-Timestamp")
+	err = webhook.Verify(
+		cfg.polkaWebhookSecrets,
+		timestamp,
+		r.Header.Get("Polka-Signature"),
+		body,
+		time.Now(),
+		polkaSignatureTolerance,
+	)
+	if err != nil {
+		slog.WarnContext(r.Context(), "Rejected Polka webhook", "error", err)
+		respondError(w, r, 401, codeInvalidSignature, "Invalid signature")
 		return
 	}
+	// Verify has already parsed it
+	sentAt, _ := webhook.ParseTimestamp(timestamp)
 
-	decoder := json.NewDecoder(r.Body)
 	polkaWebookPayload := PolkaWebookPayload{}
-	err = decoder.Decode(&polkaWebookPayload)
+	err = json.Unmarshal(body, &polkaWebookPayload)
 	if err != nil {
-		log.Printf("Error decoding message: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.WarnContext(r.Context(), "Error decoding message", "error", err)
+		respondError(w, r, 400, codeInvalidJSON, "Invalid request body")
 		return
 	}
 
-	if polkaWebookPayload.Event != "user.upgraded" {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(204)
+	if polkaWebookPayload.ID == "" {
+		respondInvalid(w, r, fieldError{Field: "id", Code: fieldRequired, Detail: "Missing event ID"})
 		return
 	}
 
-	userId, err := uuid.Parse(polkaWebookPayload.Data.UserID)
+	// store the event and acknowledge it; the inbox worker applies it, so a
+	// database problem while processing doesn't depend on Polka retrying
+	_, err = cfg.db.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		Source:  polkaSource,
+		ID:      polkaWebookPayload.ID,
+		Event:   polkaWebookPayload.Event,
+		Payload: body,
+		SentAt:  sql.NullTime{Time: sentAt, Valid: true},
+	})
 	if err != nil {
-		log.Printf("Invalid User ID: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(400)
-		w.Write([]byte(`{"error":"Invalid User ID"}`))
+		slog.ErrorContext(r.Context(), "Error recording webhook event", "error", err)
+		respondInternalError(w, r)
 		return
 	}
 
-	_, err = cfg.db.UpdateIsChirpyRed(r.Context(), database.UpdateIsChirpyRedParams{
-		ID:          userId,
-		IsChirpyRed: true,
-	})
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
+
+// processPolkaEvent records the subscription change an event describes and
+// re-derives the user's Chirpy Red status from it. Polka doesn't guarantee
+// delivery order, so an event signed before the last one applied to the
+// subscription is skipped.
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, payload PolkaWebookPayload, sentAt sql.NullTime) error {
+	status, ok := polkaSubscriptionStatus[payload.Event]
+	if !ok {
+		return nil
+	}
 
+	userId, err := uuid.Parse(payload.Data.UserID)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errPolkaInvalidUser, err)
+	}
+	user, err := cfg.db.GetUserByID(ctx, userId)
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(404)
-			return
+		if errors.Is(err, store.ErrNotFound) {
+			return errPolkaUnknownUser
 		}
-		log.Printf("Error updating user: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
-		return
+		return err
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(204)
+	periodEnd := sql.NullTime{}
+	if payload.Data.CurrentPeriodEnd != nil {
+		periodEnd = sql.NullTime{Time: *payload.Data.CurrentPeriodEnd, Valid: true}
+	}
+	_, err = cfg.db.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:           userId,
+		Status:           status,
+		CurrentPeriodEnd: periodEnd,
+		LastEventAt:      sentAt,
+	})
+	if errors.Is(err, store.ErrNotFound) {
+		slog.InfoContext(ctx, "Skipping out-of-order Polka event", "event_id", payload.ID, "event", payload.Event)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	isChirpyRed, err := cfg.db.SyncChirpyRed(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if isChirpyRed && !user.IsChirpyRed {
+		cfg.emitEvent(ctx, userId, eventUserUpgraded, struct {
+			UserID uuid.UUID `json:"user_id"`
+		}{userId})
+	}
+	return nil
+}
+
+// expireChirpyRed clears Chirpy Red for users whose paid period has run out
+// without a renewal event.
+func (cfg *apiConfig) expireChirpyRed(ctx context.Context) error {
+	expired, err := cfg.db.ExpireLapsedChirpyRed(ctx)
+	if err != nil {
+		return err
+	}
+	if expired > 0 {
+		slog.InfoContext(ctx, "Expired Chirpy Red", "users", expired)
+	}
+	return nil
 }
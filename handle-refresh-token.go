@@ -1,8 +1,7 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -12,56 +11,47 @@ import (
 func (cfg *apiConfig) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Invalid Authorization header"}`))
+		respondError(w, r, 401, codeUnauthenticated, "Invalid Authorization header")
 		return
 	}
 
 	refreshToken, err := cfg.db.GetRefreshToken(r.Context(), token)
 	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
+		respondError(w, r, 401, codeInvalidToken, "Invalid refresh token")
 		return
 	}
 
 	// token is expired or has been revoked
 	if refreshToken.ExpiresAt.Before(time.Now()) || refreshToken.RevokedAt.Valid {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
+		respondError(w, r, 401, codeInvalidToken, "Invalid refresh token")
 		return
 	}
 
-	jwtTTL := time.Duration(time.Hour)
-	newToken, errJwt := auth.MakeJWT(refreshToken.UserID.UUID, cfg.jwtSecret, jwtTTL)
+	if !cfg.checkAccountActive(w, r, refreshToken.UserID.UUID, time.Time{}) {
+		return
+	}
+
+	newToken, errJwt := auth.MakeJWT(refreshToken.UserID.UUID, cfg.jwtSecret, cfg.accessTokenTTL)
 	if errJwt != nil {
-		log.Printf("Error in MakeJWT: %s", errJwt)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error in MakeJWT", "error", errJwt)
+		respondInternalError(w, r)
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(200)
-	fmt.Fprintf(w, `{"token":"%s"}`, newToken)
+	respondJSON(w, r, 200, map[string]string{"token": newToken})
 }
 
 func (cfg *apiConfig) handleRevokeRefreshToken(w http.ResponseWriter, r *http.Request) {
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Invalid Authorization header"}`))
+		respondError(w, r, 401, codeUnauthenticated, "Invalid Authorization header")
 		return
 	}
 
 	err = cfg.db.RevokeRefreshToken(r.Context(), token)
 	if err != nil {
-		log.Printf("Error in RevokeRefreshToken: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error in RevokeRefreshToken", "error", err)
+		respondInternalError(w, r)
 		return
 	}
 
@@ -1,12 +1,13 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 
 	"github.com/mattcollier/boot-go-server/internal/auth"
 	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
 )
 
 type UserPayload struct {
@@ -30,43 +31,22 @@ func (cfg *apiConfig) handleCreateUser(w http.ResponseWriter, r *http.Request) {
 		Email:          userData.Email,
 		HashedPassword: stringToNullString(userData.HashedPassword),
 	})
-	if err != nil {
-		log.Printf("Error creating user: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+	if errors.Is(err, store.ErrConflict) {
+		respondError(w, r, 409, codeEmailTaken, "Email is already registered")
 		return
 	}
-
-	jsonUser, err := json.Marshal(user)
 	if err != nil {
-		// an error will be thrown if the JSON is invalid or has the wrong types
-		// any missing fields will simply have their values in the struct set to their zero value
-		log.Printf("Error decoding message: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error creating user", "error", err)
+		respondInternalError(w, r)
 		return
 	}
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(201)
-	w.Write(jsonUser)
+
+	respondJSON(w, r, 201, user)
 }
 
 func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
-		w.Write([]byte(`{"error":"Invalid Authorization header"}`))
-		return
-	}
-
-	userId, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
-		w.Write([]byte(`{"error":"Invalid JWT"}`))
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
 		return
 	}
 
@@ -82,58 +62,36 @@ func (cfg *apiConfig) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		HashedPassword: stringToNullString(user.HashedPassword),
 	})
 
-	if err != nil {
-		log.Printf("Error updating user: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+	if errors.Is(err, store.ErrConflict) {
+		respondError(w, r, 409, codeEmailTaken, "Email is already registered")
 		return
 	}
-
-	jsonUser, err := json.Marshal(updatedUser)
 	if err != nil {
-		// an error will be thrown if the JSON is invalid or has the wrong types
-		// any missing fields will simply have their values in the struct set to their zero value
-		log.Printf("Error encoding user: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error updating user", "error", err)
+		respondInternalError(w, r)
 		return
 	}
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(jsonUser)
+
+	respondJSON(w, r, 200, updatedUser)
 }
 
 func validateUserPayload(w http.ResponseWriter, r *http.Request) (UserData, bool) {
-	decoder := json.NewDecoder(r.Body)
 	userPayload := UserPayload{}
-	err := decoder.Decode(&userPayload)
-	if err != nil {
-		// an error will be thrown if the JSON is invalid or has the wrong types
-		// any missing fields will simply have their values in the struct set to their zero value
-		log.Printf("Error decoding message: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+	if !decodeJSON(w, r, &userPayload) {
 		return UserData{}, false
 	}
 
 	// TODO: add more stringent password requirements
 	if userPayload.Password == "" {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(400)
-		w.Write([]byte(`{"error":"Password is required"}`))
+		respondInvalid(w, r, fieldError{Field: "password", Code: fieldRequired, Detail: "Password is required"})
 		return UserData{}, false
 	}
 
-	hashedPassword, err := auth.HashPassword(userPayload.Password)
+	hashedPassword, err := auth.HashPassword(r.Context(), userPayload.Password)
 
 	if err != nil {
-		log.Printf("Error hashing password: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error hashing password", "error", err)
+		respondInternalError(w, r)
 		return UserData{}, false
 	}
 
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+	"github.com/mattcollier/boot-go-server/internal/tracing"
+	"github.com/mattcollier/boot-go-server/internal/webhook"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	webhookInboxBatchSize   = 20
+	webhookInboxMaxAttempts = 8
+	webhookRetryBaseDelay   = 30 * time.Second
+	webhookRetryMaxDelay    = time.Hour
+)
+
+// errPermanent marks processing failures that retrying cannot fix, such as
+// an event for a user that doesn't exist. Those events are dead-lettered
+// straight away.
+var errPermanent = errors.New("permanent failure")
+
+type webhookEventResponse struct {
+	Source        string          `json:"source"`
+	ID            string          `json:"id"`
+	Event         string          `json:"event"`
+	Status        string          `json:"status"`
+	ReceivedAt    time.Time       `json:"received_at"`
+	ProcessedAt   *time.Time      `json:"processed_at"`
+	Deliveries    int32           `json:"deliveries"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+func newWebhookEventResponse(e database.WebhookEvent) webhookEventResponse {
+	resp := webhookEventResponse{
+		Source:        e.Source,
+		ID:            e.ID,
+		Event:         e.Event,
+		Status:        e.Status,
+		ReceivedAt:    e.ReceivedAt,
+		Deliveries:    e.Deliveries,
+		Attempts:      e.Attempts,
+		NextAttemptAt: e.NextAttemptAt,
+		LastError:     e.LastError.String,
+	}
+	if e.ProcessedAt.Valid {
+		resp.ProcessedAt = &e.ProcessedAt.Time
+	}
+	if json.Valid(e.Payload) {
+		resp.Payload = e.Payload
+	}
+	return resp
+}
+
+// processWebhookInbox claims due events and applies them. Claimed events are
+// leased for a few minutes, so events held by a worker that died are picked
+// up again once the lease runs out.
+func (cfg *apiConfig) processWebhookInbox(ctx context.Context) error {
+	events, err := cfg.db.ClaimWebhookEvents(ctx, webhookInboxBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		eventCtx, span := tracing.Start(ctx, "webhook.process",
+			attribute.String("webhook.source", event.Source),
+			attribute.String("webhook.event_id", event.ID),
+			attribute.Int("webhook.attempt", int(event.Attempts)),
+		)
+		err := cfg.processWebhookEvent(eventCtx, event)
+		tracing.End(span, err)
+		if err == nil {
+			err = cfg.db.MarkWebhookEventProcessed(ctx, database.MarkWebhookEventProcessedParams{
+				Source: event.Source,
+				ID:     event.ID,
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		lastError := sql.NullString{String: err.Error(), Valid: true}
+		if errors.Is(err, errPermanent) || event.Attempts >= webhookInboxMaxAttempts {
+			slog.ErrorContext(ctx, "Dead-lettering webhook event", "source", event.Source, "event_id", event.ID, "error", err)
+			err = cfg.db.DeadLetterWebhookEvent(ctx, database.DeadLetterWebhookEventParams{
+				Source:    event.Source,
+				ID:        event.ID,
+				LastError: lastError,
+			})
+		} else {
+			slog.WarnContext(ctx, "Error processing webhook event", "source", event.Source, "event_id", event.ID, "attempt", event.Attempts, "error", err)
+			err = cfg.db.RetryWebhookEvent(ctx, database.RetryWebhookEventParams{
+				Source:        event.Source,
+				ID:            event.ID,
+				NextAttemptAt: time.Now().Add(webhook.Backoff(int(event.Attempts), webhookRetryBaseDelay, webhookRetryMaxDelay)),
+				LastError:     lastError,
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, event database.WebhookEvent) error {
+	switch event.Source {
+	case polkaSource:
+		payload := PolkaWebookPayload{}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: %s", errPermanent, err)
+		}
+		err := cfg.processPolkaEvent(ctx, payload, event.SentAt)
+		if errors.Is(err, errPolkaInvalidUser) || errors.Is(err, errPolkaUnknownUser) {
+			return fmt.Errorf("%w: %s", errPermanent, err)
+		}
+		return err
+	}
+	return fmt.Errorf("%w: unknown source %q", errPermanent, event.Source)
+}
+
+func (cfg *apiConfig) handleListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "dead"
+	}
+	limit := 100
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 {
+			respondInvalid(w, r, fieldError{Field: "limit", Code: fieldInvalid, Detail: "Invalid limit"})
+			return
+		}
+		limit = n
+	}
+
+	events, err := cfg.db.ListWebhookEventsByStatus(r.Context(), database.ListWebhookEventsByStatusParams{
+		Status: status,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing webhook events", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	resp := make([]webhookEventResponse, 0, len(events))
+	for _, e := range events {
+		resp = append(resp, newWebhookEventResponse(e))
+	}
+	respondJSON(w, r, 200, resp)
+}
+
+// handleReplayWebhookEvent puts a dead-lettered event back in the inbox with
+// a fresh set of attempts.
+func (cfg *apiConfig) handleReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	event, err := cfg.db.ReplayWebhookEvent(r.Context(), database.ReplayWebhookEventParams{
+		Source: r.PathValue("source"),
+		ID:     r.PathValue("event_id"),
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "No dead-lettered event with that ID")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error replaying webhook event", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	respondJSON(w, r, 202, newWebhookEventResponse(event))
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+	"github.com/mattcollier/boot-go-server/internal/tracing"
+	"github.com/mattcollier/boot-go-server/internal/webhook"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Events Chirpy sends to registered webhook endpoints.
+const (
+	eventChirpCreated = "chirp.created"
+	eventChirpDeleted = "chirp.deleted"
+	eventUserUpgraded = "user.upgraded"
+)
+
+var webhookEventTypes = map[string]bool{
+	eventChirpCreated: true,
+	eventChirpDeleted: true,
+	eventUserUpgraded: true,
+}
+
+const (
+	webhookDeliveryBatchSize   = 20
+	webhookDeliveryMaxAttempts = 10
+	// endpoints are disabled after this many failed deliveries in a row and
+	// stay disabled until their owner re-enables them
+	webhookEndpointMaxFailures = 20
+)
+
+type webhookEndpointResponse struct {
+	ID                  uuid.UUID  `json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Secret              string     `json:"secret,omitempty"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+}
+
+func newWebhookEndpointResponse(e database.WebhookEndpoint) webhookEndpointResponse {
+	resp := webhookEndpointResponse{
+		ID:                  e.ID,
+		CreatedAt:           e.CreatedAt,
+		URL:                 e.Url,
+		Events:              strings.Fields(e.Events),
+		ConsecutiveFailures: e.ConsecutiveFailures,
+	}
+	if e.DisabledAt.Valid {
+		resp.DisabledAt = &e.DisabledAt.Time
+	}
+	return resp
+}
+
+type webhookDeliveryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	LastStatusCode int32           `json:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastResponse   string          `json:"last_response,omitempty"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+func newWebhookDeliveryResponse(d database.WebhookDelivery) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:             d.ID,
+		CreatedAt:      d.CreatedAt,
+		Event:          d.Event,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError.String,
+		LastResponse:   d.LastResponse.String,
+		Payload:        d.Payload,
+	}
+	if d.DeliveredAt.Valid {
+		resp.DeliveredAt = &d.DeliveredAt.Time
+	}
+	return resp
+}
+
+// emitEvent queues event for every enabled endpoint subscribed to it: the
+// endpoints owned by userId and all admin endpoints. Failing to queue is
+// logged rather than failing the request that caused the event.
+func (cfg *apiConfig) emitEvent(ctx context.Context, userId uuid.UUID, event string, data any) {
+	payload, err := json.Marshal(struct {
+		ID        uuid.UUID `json:"id"`
+		Type      string    `json:"type"`
+		CreatedAt time.Time `json:"created_at"`
+		Data      any       `json:"data"`
+	}{uuid.New(), event, time.Now().UTC(), data})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error encoding event", "event", event, "error", err)
+		return
+	}
+
+	_, err = cfg.db.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		UserID:  userId,
+		Event:   event,
+		Payload: payload,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Error queueing event", "event", event, "error", err)
+	}
+}
+
+// deliverWebhooks sends due deliveries, retrying failures with exponential
+// backoff until they run out of attempts.
+func (cfg *apiConfig) deliverWebhooks(ctx context.Context) error {
+	deliveries, err := cfg.db.ClaimWebhookDeliveries(ctx, webhookDeliveryBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		sendCtx, span := tracing.Start(ctx, "webhook.deliver",
+			attribute.String("webhook.delivery_id", d.ID.String()),
+			attribute.String("webhook.endpoint_id", d.EndpointID.String()),
+			attribute.String("webhook.event", d.Event),
+			attribute.Int("webhook.attempt", int(d.Attempts)),
+		)
+		result, sendErr := cfg.webhookSender.Send(sendCtx, webhook.Delivery{
+			ID:      d.ID.String(),
+			URL:     d.Url,
+			Secret:  []byte(d.Secret),
+			Event:   d.Event,
+			Payload: d.Payload,
+		}, time.Now())
+		statusCode := int32(result.StatusCode)
+		lastResponse := sql.NullString{String: result.Body, Valid: result.StatusCode != 0}
+		span.SetAttributes(attribute.Int("http.response.status_code", result.StatusCode))
+		tracing.End(span, sendErr)
+
+		if sendErr == nil {
+			err = cfg.db.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{
+				ID:             d.ID,
+				LastStatusCode: statusCode,
+				LastResponse:   lastResponse,
+			})
+			if err != nil {
+				return err
+			}
+			err = cfg.db.RecordWebhookEndpointSuccess(ctx, d.EndpointID)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		lastError := sql.NullString{String: sendErr.Error(), Valid: true}
+		if d.Attempts >= webhookDeliveryMaxAttempts {
+			err = cfg.db.FailWebhookDelivery(ctx, database.FailWebhookDeliveryParams{
+				ID:             d.ID,
+				LastStatusCode: statusCode,
+				LastError:      lastError,
+				LastResponse:   lastResponse,
+			})
+		} else {
+			err = cfg.db.RetryWebhookDelivery(ctx, database.RetryWebhookDeliveryParams{
+				ID:             d.ID,
+				NextAttemptAt:  time.Now().Add(webhook.Backoff(int(d.Attempts), webhookRetryBaseDelay, webhookRetryMaxDelay)),
+				LastStatusCode: statusCode,
+				LastError:      lastError,
+				LastResponse:   lastResponse,
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		disabledAt, err := cfg.db.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{
+			ID:                  d.EndpointID,
+			ConsecutiveFailures: webhookEndpointMaxFailures,
+		})
+		if err != nil {
+			return err
+		}
+		if disabledAt.Valid {
+			slog.WarnContext(ctx, "Disabled webhook endpoint", "endpoint_id", d.EndpointID, "consecutive_failures", webhookEndpointMaxFailures)
+		}
+	}
+	return nil
+}
+
+func makeWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// validWebhookURL accepts absolute https URLs. Hosts given as internal IP
+// addresses are refused up front; names are checked again when the sender
+// connects, since they can resolve anywhere.
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return false
+	}
+	if strings.EqualFold(u.Hostname(), "localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil {
+		return webhook.PublicAddr(addr)
+	}
+	return true
+}
+
+func (cfg *apiConfig) handleCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+	cfg.createWebhookEndpoint(w, r, uuid.NullUUID{UUID: userId, Valid: true})
+}
+
+func (cfg *apiConfig) handleListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+	cfg.listWebhookEndpoints(w, r, uuid.NullUUID{UUID: userId, Valid: true})
+}
+
+func (cfg *apiConfig) handleDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+	cfg.deleteWebhookEndpoint(w, r, uuid.NullUUID{UUID: userId, Valid: true})
+}
+
+func (cfg *apiConfig) handleEnableWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+	cfg.enableWebhookEndpoint(w, r, uuid.NullUUID{UUID: userId, Valid: true})
+}
+
+func (cfg *apiConfig) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeFirstParty)
+	if !ok {
+		return
+	}
+	cfg.listWebhookDeliveries(w, r, uuid.NullUUID{UUID: userId, Valid: true})
+}
+
+// Admin endpoints have no owner and receive events for every user.
+
+func (cfg *apiConfig) handleAdminCreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	cfg.createWebhookEndpoint(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) handleAdminListWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	cfg.listWebhookEndpoints(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) handleAdminDeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	cfg.deleteWebhookEndpoint(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) handleAdminEnableWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	cfg.enableWebhookEndpoint(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) handleAdminListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	cfg.listWebhookDeliveries(w, r, uuid.NullUUID{})
+}
+
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	type endpointPayload struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+	payload := endpointPayload{}
+	if !decodeJSON(w, r, &payload) {
+		return
+	}
+
+	if !validWebhookURL(payload.URL) {
+		respondInvalid(w, r, fieldError{Field: "url", Code: fieldInvalid, Detail: "url must be an absolute https URL on a public host"})
+		return
+	}
+	if len(payload.Events) == 0 {
+		respondInvalid(w, r, fieldError{Field: "events", Code: fieldRequired, Detail: "events is required"})
+		return
+	}
+	for _, event := range payload.Events {
+		if !webhookEventTypes[event] {
+			respondInvalid(w, r, fieldError{Field: "events", Code: fieldInvalid, Detail: "Unknown event type"})
+			return
+		}
+	}
+
+	secret, err := makeWebhookSecret()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating webhook secret", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: owner,
+		Url:    payload.URL,
+		Secret: secret,
+		Events: strings.Join(payload.Events, " "),
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating webhook endpoint", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	// the secret is only ever shown in this response
+	resp := newWebhookEndpointResponse(endpoint)
+	resp.Secret = secret
+	respondJSON(w, r, 201, resp)
+}
+
+func (cfg *apiConfig) listWebhookEndpoints(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpoints, err := cfg.db.ListWebhookEndpoints(r.Context(), owner)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing webhook endpoints", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	resp := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, e := range endpoints {
+		resp = append(resp, newWebhookEndpointResponse(e))
+	}
+	respondJSON(w, r, 200, resp)
+}
+
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpointUUID, err := uuid.Parse(r.PathValue("endpoint_id"))
+	if err != nil {
+		respondError(w, r, 404, codeNotFound, "Webhook endpoint not found")
+		return
+	}
+
+	_, err = cfg.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointUUID,
+		UserID: owner,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "Webhook endpoint not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error deleting webhook endpoint", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(204)
+}
+
+// enableWebhookEndpoint turns an automatically disabled endpoint back on.
+// Deliveries queued before it was disabled resume with their remaining
+// attempts.
+func (cfg *apiConfig) enableWebhookEndpoint(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpointUUID, err := uuid.Parse(r.PathValue("endpoint_id"))
+	if err != nil {
+		respondError(w, r, 404, codeNotFound, "Webhook endpoint not found")
+		return
+	}
+
+	endpoint, err := cfg.db.EnableWebhookEndpoint(r.Context(), database.EnableWebhookEndpointParams{
+		ID:     endpointUUID,
+		UserID: owner,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "Webhook endpoint not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error enabling webhook endpoint", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	respondJSON(w, r, 200, newWebhookEndpointResponse(endpoint))
+}
+
+func (cfg *apiConfig) listWebhookDeliveries(w http.ResponseWriter, r *http.Request, owner uuid.NullUUID) {
+	endpointUUID, err := uuid.Parse(r.PathValue("endpoint_id"))
+	if err != nil {
+		respondError(w, r, 404, codeNotFound, "Webhook endpoint not found")
+		return
+	}
+
+	_, err = cfg.db.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:     endpointUUID,
+		UserID: owner,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "Webhook endpoint not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		EndpointID: endpointUUID,
+		Limit:      100,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing webhook deliveries", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	resp := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		resp = append(resp, newWebhookDeliveryResponse(d))
+	}
+	respondJSON(w, r, 200, resp)
+}
@@ -1,22 +1,25 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
 	"net/http"
 	"sort"
-	"strings"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/mattcollier/boot-go-server/internal/auth"
 	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
 )
 
+// how many scheduled chirps publishScheduledChirps announces per run
+const scheduledChirpBatchSize = 100
+
 func (cfg *apiConfig) handleGetAllChirps(w http.ResponseWriter, r *http.Request) {
 	queryParams := r.URL.Query()
 	authorId := queryParams.Get("author_id")
-	fmt.Print(authorId)
 
 	var chirps []database.Chirp
 	var err error
@@ -24,10 +27,8 @@ func (cfg *apiConfig) handleGetAllChirps(w http.ResponseWriter, r *http.Request)
 	if authorId != "" {
 		authorUUID, errParse := uuid.Parse(authorId)
 		if errParse != nil {
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(200)
 			// return empty array
-			w.Write([]byte(`[]`))
+			respondJSON(w, r, 200, []database.Chirp{})
 			return
 		}
 		chirps, err = cfg.db.GetChirpsByAuthor(r.Context(), uuid.NullUUID{UUID: authorUUID, Valid: true})
@@ -38,10 +39,8 @@ func (cfg *apiConfig) handleGetAllChirps(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		// an error will be thrown if the JSON is invalid or has the wrong types
 		// any missing fields will simply have their values in the struct set to their zero value
-		log.Printf("Error getting chirps: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error getting chirps", "error", err)
+		respondInternalError(w, r)
 		return
 	}
 
@@ -53,164 +52,118 @@ func (cfg *apiConfig) handleGetAllChirps(w http.ResponseWriter, r *http.Request)
 		})
 	}
 
-	jsonChirps, err := json.Marshal(chirps)
-	if err != nil {
-		// an error will be thrown if the JSON is invalid or has the wrong types
-		// any missing fields will simply have their values in the struct set to their zero value
-		log.Printf("Error encoding chirps: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
-		return
-	}
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(jsonChirps)
+	respondJSON(w, r, 200, chirps)
 }
 
 func (cfg *apiConfig) handleGetChirp(w http.ResponseWriter, r *http.Request) {
 	chirpId := r.PathValue("chirp_id")
 	chirpUUID, err := uuid.Parse(chirpId)
 	if err != nil {
-		log.Printf("Invalid chirp ID: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Invalid chirp ID"}`))
+		slog.WarnContext(r.Context(), "Invalid chirp ID", "error", err)
+		respondError(w, r, 404, codeNotFound, "chirp not found")
 		return
 	}
 
 	chirp, err := cfg.db.GetChirp(r.Context(), chirpUUID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(404)
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "chirp not found")
 		} else {
-			log.Printf("Database error: %s", err)
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(500)
-			w.Write([]byte(`{"error":"Something went wrong"}`))
+			slog.ErrorContext(r.Context(), "Database error", "error", err)
+			respondInternalError(w, r)
 		}
 
 		return
 	}
-
-	jsonChirp, err := json.Marshal(chirp)
-
-	if err != nil {
-		// an error will be thrown if the JSON is invalid or has the wrong types
-		// any missing fields will simply have their values in the struct set to their zero value
-		log.Printf("Error decoding message: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+	// scheduled chirps don't exist publicly until they are published
+	if chirp.PublishAt.After(time.Now()) {
+		respondError(w, r, 404, codeNotFound, "chirp not found")
 		return
 	}
 
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(200)
-	w.Write(jsonChirp)
+	respondJSON(w, r, 200, chirp)
 }
 
 func (cfg *apiConfig) handleChirps(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
-		w.Write([]byte(`{"error":"Invalid Authorization header"}`))
-		return
-	}
-
-	userId, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
-		w.Write([]byte(`{"error":"Invalid JWT"}`))
+	userId, ok := cfg.authenticate(w, r, scopeChirpsWrite)
+	if !ok {
 		return
 	}
 
 	type messageBody struct {
 		Body string `json:"body"`
+		// optional; the chirp stays hidden until then
+		PublishAt *time.Time `json:"publish_at"`
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	mb := messageBody{}
-	err = decoder.Decode(&mb)
+	if !decodeJSON(w, r, &mb) {
+		return
+	}
+
+	ent, err := cfg.userEntitlements(r.Context(), userId)
 	if err != nil {
-		// an error will be thrown if the JSON is invalid or has the wrong types
-		// any missing fields will simply have their values in the struct set to their zero value
-		log.Printf("Error decoding message: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error loading entitlements", "error", err)
+		respondInternalError(w, r)
 		return
 	}
-	if len(mb.Body) > 140 {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(400)
-		w.Write([]byte(`{"error":"Chirp is too long"}`))
+	if len(mb.Body) > ent.MaxChirpLength {
+		respondInvalid(w, r, fieldError{Field: "body", Code: fieldTooLong, Detail: "Chirp is too long"})
 		return
 	}
 
-	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   mb.Body,
-		UserID: uuid.NullUUID{UUID: userId, Valid: true},
-	})
-	if err != nil {
-
+	publishAt := sql.NullTime{}
+	if mb.PublishAt != nil {
+		if !ent.ScheduleChirps {
+			respondError(w, r, 403, codeSubscriptionRequired, "Scheduling chirps requires Chirpy Red")
+			return
+		}
+		if mb.PublishAt.Before(time.Now()) {
+			respondInvalid(w, r, fieldError{Field: "publish_at", Code: fieldInvalid, Detail: "publish_at must be in the future"})
+			return
+		}
+		publishAt = sql.NullTime{Time: *mb.PublishAt, Valid: true}
 	}
 
-	jsonChirp, err := json.Marshal(chirp)
+	chirp, err := cfg.db.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:      mb.Body,
+		UserID:    uuid.NullUUID{UUID: userId, Valid: true},
+		PublishAt: publishAt,
+	})
 	if err != nil {
-		// an error will be thrown if the JSON is invalid or has the wrong types
-		// any missing fields will simply have their values in the struct set to their zero value
-		log.Printf("Error decoding message: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error creating chirp", "error", err)
+		respondInternalError(w, r)
 		return
 	}
-	w.Header().Add("Content-Type", "application/json")
-	w.WriteHeader(201)
-	w.Write(jsonChirp)
+	// scheduled chirps are announced by publishScheduledChirps once live
+	if chirp.Published {
+		cfg.emitEvent(r.Context(), userId, eventChirpCreated, chirp)
+	}
+
+	respondJSON(w, r, 201, chirp)
 }
 
 func (cfg *apiConfig) handleDeleteChirps(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
-		w.Write([]byte(`{"error":"Invalid Authorization header"}`))
-		return
-	}
-
-	userId, err := auth.ValidateJWT(token, cfg.jwtSecret)
-	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(401)
-		w.Write([]byte(`{"error":"Invalid JWT"}`))
+	userId, ok := cfg.authenticate(w, r, scopeChirpsWrite)
+	if !ok {
 		return
 	}
 
 	chirpUUID, err := uuid.Parse(r.PathValue("chirp_id"))
 	if err != nil {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(404)
-		w.Write([]byte(`{"error":"chirp not found"}`))
+		respondError(w, r, 404, codeNotFound, "chirp not found")
 		return
 	}
 
 	chirp, err := cfg.db.GetChirp(r.Context(), chirpUUID)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(404)
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "chirp not found")
 		} else {
-			log.Printf("Database error: %s", err)
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(500)
-			w.Write([]byte(`{"error":"Something went wrong"}`))
+			slog.ErrorContext(r.Context(), "Database error", "error", err)
+			respondInternalError(w, r)
 		}
 
 		return
@@ -218,8 +171,7 @@ func (cfg *apiConfig) handleDeleteChirps(w http.ResponseWriter, r *http.Request)
 
 	// ensure the chirp is owned by the authenticated user
 	if chirp.UserID.UUID != userId {
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(403)
+		respondError(w, r, 403, codeForbidden, "Chirp belongs to another user")
 		return
 	}
 
@@ -228,18 +180,115 @@ func (cfg *apiConfig) handleDeleteChirps(w http.ResponseWriter, r *http.Request)
 		ID:     chirpUUID,
 	})
 	if err != nil {
-		if err.Error() == "sql: no rows in result set" {
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(404)
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "chirp not found")
 			return
 		}
-		log.Printf("Error deleting chirp: %s", err)
-		w.Header().Add("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write([]byte(`{"error":"Something went wrong"}`))
+		slog.ErrorContext(r.Context(), "Error deleting chirp", "error", err)
+		respondInternalError(w, r)
 		return
 	}
+	cfg.emitEvent(r.Context(), userId, eventChirpDeleted, chirp)
 
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(204)
 }
+
+func (cfg *apiConfig) handleUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	ent, err := cfg.userEntitlements(r.Context(), userId)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading entitlements", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	if !ent.EditChirps {
+		respondError(w, r, 403, codeSubscriptionRequired, "Editing chirps requires Chirpy Red")
+		return
+	}
+
+	chirpUUID, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		respondError(w, r, 404, codeNotFound, "chirp not found")
+		return
+	}
+
+	type messageBody struct {
+		Body string `json:"body"`
+	}
+	mb := messageBody{}
+	if !decodeJSON(w, r, &mb) {
+		return
+	}
+	if len(mb.Body) > ent.MaxChirpLength {
+		respondInvalid(w, r, fieldError{Field: "body", Code: fieldTooLong, Detail: "Chirp is too long"})
+		return
+	}
+
+	chirp, err := cfg.db.GetChirp(r.Context(), chirpUUID)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			respondError(w, r, 404, codeNotFound, "chirp not found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Database error", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	if chirp.UserID.UUID != userId {
+		respondError(w, r, 403, codeForbidden, "Chirp belongs to another user")
+		return
+	}
+
+	chirp, err = cfg.db.UpdateChirp(r.Context(), database.UpdateChirpParams{
+		ID:     chirpUUID,
+		UserID: uuid.NullUUID{UUID: userId, Valid: true},
+		Body:   mb.Body,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating chirp", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+
+	respondJSON(w, r, 200, chirp)
+}
+
+// handleGetScheduledChirps lists the caller's chirps that are not published
+// yet.
+func (cfg *apiConfig) handleGetScheduledChirps(w http.ResponseWriter, r *http.Request) {
+	userId, ok := cfg.authenticate(w, r, scopeChirpsWrite)
+	if !ok {
+		return
+	}
+
+	chirps, err := cfg.db.GetScheduledChirps(r.Context(), uuid.NullUUID{UUID: userId, Valid: true})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting scheduled chirps", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	if chirps == nil {
+		chirps = []database.Chirp{}
+	}
+
+	respondJSON(w, r, 200, chirps)
+}
+
+// publishScheduledChirps sends chirp.created for scheduled chirps whose
+// publish time has passed. Each chirp is marked published as it is claimed,
+// so it is announced once even with several instances running.
+func (cfg *apiConfig) publishScheduledChirps(ctx context.Context) error {
+	chirps, err := cfg.db.PublishDueChirps(ctx, scheduledChirpBatchSize)
+	if err != nil {
+		return err
+	}
+	for _, chirp := range chirps {
+		cfg.emitEvent(ctx, chirp.UserID.UUID, eventChirpCreated, chirp)
+	}
+	return nil
+}
@@ -0,0 +1,1274 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/analytics"
+	"github.com/mattcollier/boot-go-server/internal/auth"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/entitlements"
+	"github.com/mattcollier/boot-go-server/internal/oidc"
+	"github.com/mattcollier/boot-go-server/internal/oidc/oidctest"
+	"github.com/mattcollier/boot-go-server/internal/store"
+	"github.com/mattcollier/boot-go-server/internal/store/memstore"
+	"github.com/mattcollier/boot-go-server/internal/webauthn"
+	"github.com/mattcollier/boot-go-server/internal/webauthn/webauthntest"
+	"github.com/mattcollier/boot-go-server/internal/webhook"
+)
+
+// testServer is the API on an in-memory store, wired as serve wires it
+// minus the logging, tracing and metrics middleware.
+type testServer struct {
+	t       *testing.T
+	api     *apiConfig
+	db      *memstore.Store
+	handler http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	db := memstore.New()
+	api := &apiConfig{
+		db:              db,
+		platform:        "dev",
+		jwtSecret:       "test-secret",
+		accessTokenTTL:  time.Hour,
+		refreshTokenTTL: 24 * time.Hour,
+		entitlements:    entitlements.DefaultCatalog(),
+		pageViews:       analytics.NewCounter(),
+		visitorSalt:     []byte("test-salt"),
+	}
+	mux := http.NewServeMux()
+	api.registerRoutes(mux)
+	return &testServer{t: t, api: api, db: db, handler: api.middlewareAdmin(mux)}
+}
+
+// do sends a request authorized with token, if any. A string body is sent
+// as is; anything else is encoded as JSON.
+func (s *testServer) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := s.request(method, path, body)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return s.send(req)
+}
+
+// request builds a request with a body encoded as do encodes it, for tests
+// that need headers of their own.
+func (s *testServer) request(method, path string, body any) *http.Request {
+	s.t.Helper()
+	var data []byte
+	switch b := body.(type) {
+	case nil:
+	case string:
+		data = []byte(b)
+	default:
+		var err error
+		if data, err = json.Marshal(b); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	return httptest.NewRequest(method, path, bytes.NewReader(data))
+}
+
+func (s *testServer) send(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect fails the test unless rec has the given status, and decodes the
+// body into v if it isn't nil.
+func (s *testServer) expect(rec *httptest.ResponseRecorder, status int, v any) {
+	s.t.Helper()
+	if rec.Code != status {
+		s.t.Fatalf("status %d, want %d: %s", rec.Code, status, rec.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			s.t.Fatalf("decoding %s: %v", rec.Body, err)
+		}
+	}
+}
+
+// expectProblem fails the test unless rec is a problem+json error with the
+// given status and code, and returns it.
+func (s *testServer) expectProblem(rec *httptest.ResponseRecorder, status int, code string) problem {
+	s.t.Helper()
+	var p problem
+	s.expect(rec, status, &p)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		s.t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+	if p.Code != code || p.Status != status || p.Title != http.StatusText(status) {
+		s.t.Errorf("problem = %+v, want status %d and code %s", p, status, code)
+	}
+	return p
+}
+
+type session struct {
+	ID           uuid.UUID `json:"id"`
+	Email        string    `json:"email"`
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	IsChirpyRed  bool      `json:"is_chirpy_red"`
+}
+
+// signUp creates an account with the password "hunter2" and logs in.
+func (s *testServer) signUp(email string) session {
+	s.t.Helper()
+	s.expect(s.do("POST", "/api/users", "", UserPayload{Email: email, Password: "hunter2"}), 201, nil)
+	return s.login(email, "hunter2")
+}
+
+func (s *testServer) login(email, password string) session {
+	s.t.Helper()
+	var sess session
+	s.expect(s.do("POST", "/api/login", "", UserPayload{Email: email, Password: password}), 200, &sess)
+	return sess
+}
+
+// signUpAdmin creates an account and gives it the admin role, as
+// user promote does.
+func (s *testServer) signUpAdmin(email string) session {
+	s.t.Helper()
+	sess := s.signUp(email)
+	_, err := s.db.SetUserRole(context.Background(), database.SetUserRoleParams{ID: sess.ID, Role: roleAdmin})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return sess
+}
+
+// upgrade grants Chirpy Red through the admin API.
+func (s *testServer) upgrade(admin, user session) {
+	s.t.Helper()
+	path := "/admin/users/" + user.ID.String() + "/chirpy-red"
+	s.expect(s.do("PUT", path, admin.Token, map[string]bool{"is_chirpy_red": true}), 200, nil)
+}
+
+func chirpBodies(chirps []database.Chirp) string {
+	var bodies []string
+	for _, c := range chirps {
+		bodies = append(bodies, c.Body)
+	}
+	return strings.Join(bodies, ",")
+}
+
+func TestHealth(t *testing.T) {
+	s := newTestServer(t)
+	for _, path := range []string{"/api/healthz", "/api/livez"} {
+		rec := s.do("GET", path, "", nil)
+		if rec.Code != 200 || rec.Body.String() != "OK" {
+			t.Errorf("GET %s = %d %q", path, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestCreateUser(t *testing.T) {
+	s := newTestServer(t)
+
+	var user database.CreateUserRow
+	s.expect(s.do("POST", "/api/users", "", UserPayload{Email: "alice@example.com", Password: "hunter2"}), 201, &user)
+	if user.Email != "alice@example.com" || user.ID == uuid.Nil || user.IsChirpyRed {
+		t.Errorf("created %+v", user)
+	}
+	if strings.Contains(s.do("POST", "/api/login", "", UserPayload{Email: "alice@example.com", Password: "hunter2"}).Body.String(), "hashed_password") {
+		t.Error("login response includes the password hash")
+	}
+
+	s.expectProblem(s.do("POST", "/api/users", "", UserPayload{Email: "alice@example.com", Password: "other"}), 409, codeEmailTaken)
+
+	p := s.expectProblem(s.do("POST", "/api/users", "", UserPayload{Email: "bob@example.com"}), 400, codeValidation)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "password" || p.Errors[0].Code != fieldRequired {
+		t.Errorf("missing password errors = %+v", p.Errors)
+	}
+	if p.Instance != "/api/users" {
+		t.Errorf("instance = %q", p.Instance)
+	}
+
+	s.expectProblem(s.do("POST", "/api/users", "", `{"email": `), 400, codeInvalidJSON)
+	s.expectProblem(s.do("POST", "/api/users", "", ""), 400, codeInvalidJSON)
+	p = s.expectProblem(s.do("POST", "/api/users", "", `{"email": 7}`), 400, codeValidation)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "email" || p.Errors[0].Code != fieldType {
+		t.Errorf("wrong type errors = %+v", p.Errors)
+	}
+	big := `{"email": "` + strings.Repeat("a", maxRequestBodyBytes) + `"}`
+	s.expectProblem(s.do("POST", "/api/users", "", big), 413, codeBodyTooLarge)
+}
+
+func TestUpdateUser(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+	s.signUp("bob@example.com")
+
+	var updated database.UpdateUserRow
+	s.expect(s.do("PUT", "/api/users", alice.Token, UserPayload{Email: "alice@example.org", Password: "correct horse"}), 200, &updated)
+	if updated.ID != alice.ID || updated.Email != "alice@example.org" {
+		t.Errorf("updated %+v", updated)
+	}
+	s.login("alice@example.org", "correct horse")
+	s.expectProblem(s.do("POST", "/api/login", "", UserPayload{Email: "alice@example.org", Password: "hunter2"}), 401, codeInvalidCredentials)
+
+	s.expectProblem(s.do("PUT", "/api/users", alice.Token, UserPayload{Email: "bob@example.com", Password: "x"}), 409, codeEmailTaken)
+	s.expectProblem(s.do("PUT", "/api/users", "", UserPayload{Email: "a@example.com", Password: "x"}), 401, codeUnauthenticated)
+	s.expectProblem(s.do("PUT", "/api/users", "not-a-jwt", UserPayload{Email: "a@example.com", Password: "x"}), 401, codeInvalidToken)
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+	if alice.Email != "alice@example.com" || alice.Token == "" || alice.RefreshToken == "" {
+		t.Errorf("login = %+v", alice)
+	}
+
+	s.expectProblem(s.do("POST", "/api/login", "", UserPayload{Email: "alice@example.com", Password: "wrong"}), 401, codeInvalidCredentials)
+	s.expectProblem(s.do("POST", "/api/login", "", UserPayload{Email: "nobody@example.com", Password: "hunter2"}), 401, codeInvalidCredentials)
+	// login decodes strictly, so typos aren't silently ignored
+	p := s.expectProblem(s.do("POST", "/api/login", "", `{"email": "alice@example.com", "passwd": "hunter2"}`), 400, codeValidation)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "passwd" || p.Errors[0].Code != fieldUnknown {
+		t.Errorf("unknown field errors = %+v", p.Errors)
+	}
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	s.expect(s.do("POST", "/api/refresh", alice.RefreshToken, nil), 200, &refreshed)
+	s.expect(s.do("GET", "/api/users/me/entitlements", refreshed.Token, nil), 200, nil)
+
+	// access tokens aren't refresh tokens
+	s.expectProblem(s.do("POST", "/api/refresh", alice.Token, nil), 401, codeInvalidToken)
+	s.expectProblem(s.do("POST", "/api/refresh", "", nil), 401, codeUnauthenticated)
+
+	s.expect(s.do("POST", "/api/revoke", alice.RefreshToken, nil), 204, nil)
+	s.expectProblem(s.do("POST", "/api/refresh", alice.RefreshToken, nil), 401, codeInvalidToken)
+	s.expectProblem(s.do("POST", "/api/revoke", "", nil), 401, codeUnauthenticated)
+}
+
+func TestChirps(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+	bob := s.signUp("bob@example.com")
+
+	var first database.Chirp
+	s.expect(s.do("POST", "/api/chirps", alice.Token, map[string]string{"body": "one"}), 201, &first)
+	if first.Body != "one" || first.UserID.UUID != alice.ID {
+		t.Errorf("created %+v", first)
+	}
+	s.expect(s.do("POST", "/api/chirps", bob.Token, map[string]string{"body": "two"}), 201, nil)
+	s.expect(s.do("POST", "/api/chirps", alice.Token, map[string]string{"body": "three"}), 201, nil)
+
+	p := s.expectProblem(s.do("POST", "/api/chirps", alice.Token, map[string]string{"body": strings.Repeat("a", 141)}), 400, codeValidation)
+	if len(p.Errors) != 1 || p.Errors[0].Field != "body" || p.Errors[0].Code != fieldTooLong {
+		t.Errorf("too long errors = %+v", p.Errors)
+	}
+	s.expectProblem(s.do("POST", "/api/chirps", "", map[string]string{"body": "anon"}), 401, codeUnauthenticated)
+
+	for query, want := range map[string]string{
+		"":                                "one,two,three",
+		"?sort=asc":                       "one,two,three",
+		"?sort=desc":                      "three,two,one",
+		"?author_id=" + alice.ID.String(): "one,three",
+		"?author_id=" + bob.ID.String() + "&sort=desc": "two",
+		"?author_id=" + uuid.NewString():               "",
+		"?author_id=not-a-uuid":                        "",
+	} {
+		var chirps []database.Chirp
+		s.expect(s.do("GET", "/api/chirps"+query, "", nil), 200, &chirps)
+		if got := chirpBodies(chirps); got != want {
+			t.Errorf("GET /api/chirps%s = %q, want %q", query, got, want)
+		}
+	}
+
+	var got database.Chirp
+	s.expect(s.do("GET", "/api/chirps/"+first.ID.String(), "", nil), 200, &got)
+	if got.ID != first.ID || got.Body != "one" {
+		t.Errorf("GET chirp = %+v", got)
+	}
+	s.expectProblem(s.do("GET", "/api/chirps/"+uuid.NewString(), "", nil), 404, codeNotFound)
+	s.expectProblem(s.do("GET", "/api/chirps/not-a-uuid", "", nil), 404, codeNotFound)
+
+	s.expectProblem(s.do("DELETE", "/api/chirps/"+first.ID.String(), bob.Token, nil), 403, codeForbidden)
+	s.expectProblem(s.do("DELETE", "/api/chirps/"+uuid.NewString(), alice.Token, nil), 404, codeNotFound)
+	s.expect(s.do("DELETE", "/api/chirps/"+first.ID.String(), alice.Token, nil), 204, nil)
+	s.expectProblem(s.do("GET", "/api/chirps/"+first.ID.String(), "", nil), 404, codeNotFound)
+	s.expectProblem(s.do("DELETE", "/api/chirps/"+first.ID.String(), alice.Token, nil), 404, codeNotFound)
+}
+
+func TestChirpyRedFeatures(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+	alice := s.signUp("alice@example.com")
+	bob := s.signUp("bob@example.com")
+
+	var chirp database.Chirp
+	s.expect(s.do("POST", "/api/chirps", alice.Token, map[string]string{"body": "draft"}), 201, &chirp)
+	chirpPath := "/api/chirps/" + chirp.ID.String()
+	publishAt := time.Now().Add(time.Hour).UTC()
+	scheduled := map[string]any{"body": "later", "publish_at": publishAt}
+
+	var ent entitlements.Entitlements
+	s.expect(s.do("GET", "/api/users/me/entitlements", alice.Token, nil), 200, &ent)
+	if ent.Plan != entitlements.PlanFree || ent.EditChirps || ent.MaxChirpLength != 140 {
+		t.Errorf("free entitlements = %+v", ent)
+	}
+	s.expectProblem(s.do("PUT", chirpPath, alice.Token, map[string]string{"body": "edited"}), 403, codeSubscriptionRequired)
+	s.expectProblem(s.do("POST", "/api/chirps", alice.Token, scheduled), 403, codeSubscriptionRequired)
+
+	s.upgrade(admin, alice)
+	s.expect(s.do("GET", "/api/users/me/entitlements", alice.Token, nil), 200, &ent)
+	if ent.Plan != entitlements.PlanChirpyRed || !ent.EditChirps || !ent.ScheduleChirps {
+		t.Errorf("Chirpy Red entitlements = %+v", ent)
+	}
+
+	var edited database.Chirp
+	s.expect(s.do("PUT", chirpPath, alice.Token, map[string]string{"body": "edited"}), 200, &edited)
+	if edited.ID != chirp.ID || edited.Body != "edited" {
+		t.Errorf("edited %+v", edited)
+	}
+	s.upgrade(admin, bob)
+	s.expectProblem(s.do("PUT", chirpPath, bob.Token, map[string]string{"body": "mine"}), 403, codeForbidden)
+	s.expectProblem(s.do("PUT", "/api/chirps/"+uuid.NewString(), alice.Token, map[string]string{"body": "x"}), 404, codeNotFound)
+
+	s.expectProblem(s.do("POST", "/api/chirps", alice.Token, map[string]any{"body": "past", "publish_at": time.Now().Add(-time.Hour)}), 400, codeValidation)
+	s.expectProblem(s.do("POST", "/api/chirps", alice.Token, `{"body": "x", "publish_at": "tomorrow"}`), 400, codeInvalidJSON)
+
+	var later database.Chirp
+	s.expect(s.do("POST", "/api/chirps", alice.Token, scheduled), 201, &later)
+	if !later.PublishAt.Equal(publishAt) {
+		t.Errorf("publish_at = %v, want %v", later.PublishAt, publishAt)
+	}
+	sooner := map[string]any{"body": "sooner", "publish_at": publishAt.Add(-30 * time.Minute)}
+	s.expect(s.do("POST", "/api/chirps", alice.Token, sooner), 201, nil)
+
+	// scheduled chirps are only visible to their author until published
+	var chirps []database.Chirp
+	s.expect(s.do("GET", "/api/chirps", "", nil), 200, &chirps)
+	if got := chirpBodies(chirps); got != "edited" {
+		t.Errorf("published chirps = %q", got)
+	}
+	s.expectProblem(s.do("GET", "/api/chirps/"+later.ID.String(), "", nil), 404, codeNotFound)
+	s.expect(s.do("GET", "/api/chirps/scheduled", alice.Token, nil), 200, &chirps)
+	if got := chirpBodies(chirps); got != "sooner,later" {
+		t.Errorf("scheduled chirps = %q", got)
+	}
+	s.expect(s.do("GET", "/api/chirps/scheduled", bob.Token, nil), 200, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("bob sees %d scheduled chirps", len(chirps))
+	}
+}
+
+func TestPolkaEventOrdering(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+	ctx := context.Background()
+	now := time.Now()
+	apply := func(id, event string, sentAt time.Time) {
+		t.Helper()
+		payload := PolkaWebookPayload{ID: id, Event: event}
+		payload.Data.UserID = alice.ID.String()
+		if err := s.api.processPolkaEvent(ctx, payload, sql.NullTime{Time: sentAt, Valid: true}); err != nil {
+			t.Fatalf("processPolkaEvent(%s): %v", event, err)
+		}
+	}
+
+	// the upgrade was sent first but arrives after the refund
+	apply("evt_2", "user.refunded", now)
+	apply("evt_1", "user.upgraded", now.Add(-time.Minute))
+	sub, err := s.db.GetSubscription(ctx, alice.ID)
+	if err != nil || sub.Status != "refunded" {
+		t.Errorf("subscription after a late event = %+v, %v", sub, err)
+	}
+	user, _ := s.db.GetUserByID(ctx, alice.ID)
+	if user.IsChirpyRed {
+		t.Error("late upgrade applied")
+	}
+
+	apply("evt_3", "user.upgraded", now.Add(time.Minute))
+	if sub, _ := s.db.GetSubscription(ctx, alice.ID); sub.Status != "active" {
+		t.Errorf("status after a newer event = %q, want active", sub.Status)
+	}
+}
+
+func TestValidWebhookURL(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/hooks":      true,
+		"https://93.184.216.34/hooks":    true,
+		"http://example.com/hooks":       false,
+		"https://localhost/hooks":        false,
+		"https://127.0.0.1:8080/hooks":   false,
+		"https://169.254.169.254/latest": false,
+		"https://[::1]/hooks":            false,
+		"https://[fd00:ec2::254]/latest": false,
+		"/hooks":                         false,
+	}
+	for raw, want := range tests {
+		if got := validWebhookURL(raw); got != want {
+			t.Errorf("validWebhookURL(%q) = %v, want %v", raw, got, want)
+		}
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+	var key apiKeyResponse
+	s.expect(s.do("POST", "/api/keys", alice.Token, map[string]any{"name": "ci"}), 201, &key)
+
+	s.expectProblem(s.do("DELETE", "/api/users", alice.Token, map[string]string{"password": "wrong"}), 401, codeInvalidCredentials)
+
+	var scheduled struct {
+		DeletionDueAt time.Time `json:"deletion_due_at"`
+	}
+	s.expect(s.do("DELETE", "/api/users", alice.Token, map[string]string{"password": "hunter2"}), 202, &scheduled)
+	if d := time.Until(scheduled.DeletionDueAt); d < accountDeletionGracePeriod-time.Minute || d > accountDeletionGracePeriod {
+		t.Errorf("deletion scheduled in %v", d)
+	}
+	s.expectProblem(s.do("POST", "/api/refresh", alice.RefreshToken, nil), 401, codeInvalidToken)
+	// access tokens are revoked along with the sessions, but API keys
+	// survive that and are turned away until the deletion is cancelled
+	req := s.request("POST", "/api/chirps", map[string]string{"body": "still here"})
+	req.Header.Set("Authorization", "ApiKey "+key.Key)
+	s.expectProblem(s.send(req), 403, codeAccountPendingDeletion)
+
+	// signing back in during the grace period keeps the account
+	s.login("alice@example.com", "hunter2")
+	user, err := s.db.GetUserByID(context.Background(), alice.ID)
+	if err != nil || user.DeletionDueAt.Valid {
+		t.Errorf("deletion not cancelled: %+v, %v", user, err)
+	}
+}
+
+func TestAdminRequiresAdmin(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+
+	s.expectProblem(s.do("GET", "/admin/users", "", nil), 401, codeUnauthenticated)
+	s.expectProblem(s.do("GET", "/admin/users", alice.Token, nil), 403, codeAdminRequired)
+	s.expectProblem(s.do("POST", "/admin/reset", alice.Token, nil), 403, codeAdminRequired)
+
+	// the role is checked on every request, so promotion needs no new token
+	s.db.SetUserRole(context.Background(), database.SetUserRoleParams{ID: alice.ID, Role: roleAdmin})
+	s.expect(s.do("GET", "/admin/users", alice.Token, nil), 200, nil)
+}
+
+func TestAdminUsers(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+	alice := s.signUp("alice@example.com")
+	s.signUp("bob@example.org")
+	userPath := "/admin/users/" + alice.ID.String()
+
+	var users []adminUserResponse
+	s.expect(s.do("GET", "/admin/users", admin.Token, nil), 200, &users)
+	if len(users) != 3 || users[0].Email != "admin@example.com" || users[0].Role != roleAdmin || !users[0].HasPassword {
+		t.Errorf("users = %+v", users)
+	}
+	for query, want := range map[string]int{
+		"?q=EXAMPLE.COM":      2,
+		"?q=bob":              1,
+		"?limit=2":            2,
+		"?limit=2&offset=2":   1,
+		"?q=nobody":           0,
+		"?q=%25":              0,
+		"?q=_":                0,
+		"?offset=10&limit=10": 0,
+	} {
+		s.expect(s.do("GET", "/admin/users"+query, admin.Token, nil), 200, &users)
+		if len(users) != want {
+			t.Errorf("GET /admin/users%s returned %d users, want %d", query, len(users), want)
+		}
+	}
+	s.expectProblem(s.do("GET", "/admin/users?limit=0", admin.Token, nil), 400, codeValidation)
+	s.expectProblem(s.do("GET", "/admin/users?offset=-1", admin.Token, nil), 400, codeValidation)
+
+	var user adminUserResponse
+	s.expect(s.do("GET", userPath, admin.Token, nil), 200, &user)
+	if user.ID != alice.ID || user.Role != roleUser || user.SuspendedAt != nil {
+		t.Errorf("user = %+v", user)
+	}
+	s.expectProblem(s.do("GET", "/admin/users/"+uuid.NewString(), admin.Token, nil), 404, codeNotFound)
+	s.expectProblem(s.do("GET", "/admin/users/not-a-uuid", admin.Token, nil), 404, codeNotFound)
+
+	s.upgrade(admin, alice)
+	s.expect(s.do("POST", "/api/chirps", alice.Token, map[string]any{"body": "later", "publish_at": time.Now().Add(time.Hour)}), 201, nil)
+	s.expect(s.do("POST", "/api/chirps", alice.Token, map[string]string{"body": "now"}), 201, nil)
+	var chirps []database.Chirp
+	s.expect(s.do("GET", userPath+"/chirps", admin.Token, nil), 200, &chirps)
+	if got := chirpBodies(chirps); got != "now,later" {
+		t.Errorf("admin chirps = %q", got)
+	}
+
+	s.login("alice@example.com", "hunter2")
+	var sessions []struct {
+		RevokedAt *time.Time `json:"revoked_at"`
+		Token     string     `json:"token"`
+	}
+	s.expect(s.do("GET", userPath+"/sessions", admin.Token, nil), 200, &sessions)
+	if len(sessions) != 2 || sessions[0].RevokedAt != nil || sessions[0].Token != "" {
+		t.Errorf("sessions = %+v", sessions)
+	}
+
+	s.expect(s.do("PUT", userPath+"/chirpy-red", admin.Token, map[string]bool{"is_chirpy_red": false}), 200, &user)
+	if user.IsChirpyRed {
+		t.Error("Chirpy Red not removed")
+	}
+	s.expectProblem(s.do("PUT", userPath+"/chirpy-red", admin.Token, map[string]string{}), 400, codeValidation)
+}
+
+func TestAdminSuspendAndLogout(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+	alice := s.signUp("alice@example.com")
+	userPath := "/admin/users/" + alice.ID.String()
+
+	var user adminUserResponse
+	s.expect(s.do("POST", userPath+"/suspend", admin.Token, nil), 200, &user)
+	if user.SuspendedAt == nil || user.SessionsRevokedAt == nil {
+		t.Errorf("suspended user = %+v", user)
+	}
+	s.expectProblem(s.do("GET", "/api/users/me/entitlements", alice.Token, nil), 403, codeAccountSuspended)
+	s.expectProblem(s.do("POST", "/api/login", "", UserPayload{Email: "alice@example.com", Password: "hunter2"}), 403, codeAccountSuspended)
+	s.expectProblem(s.do("POST", "/api/refresh", alice.RefreshToken, nil), 401, codeInvalidToken)
+
+	s.expect(s.do("POST", userPath+"/unsuspend", admin.Token, nil), 200, &user)
+	if user.SuspendedAt != nil {
+		t.Errorf("unsuspended user = %+v", user)
+	}
+	alice = s.login("alice@example.com", "hunter2")
+
+	s.expect(s.do("POST", userPath+"/logout", admin.Token, nil), 200, &user)
+	if user.SessionsRevokedAt == nil {
+		t.Errorf("logged out user = %+v", user)
+	}
+	s.expectProblem(s.do("POST", "/api/refresh", alice.RefreshToken, nil), 401, codeInvalidToken)
+
+	s.expectProblem(s.do("POST", "/admin/users/"+uuid.NewString()+"/suspend", admin.Token, nil), 404, codeNotFound)
+}
+
+func TestAdminAuditLog(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+	alice := s.signUp("alice@example.com")
+
+	s.do("POST", "/admin/users/"+alice.ID.String()+"/suspend", admin.Token, nil)
+	s.do("POST", "/admin/users/"+uuid.NewString()+"/logout", admin.Token, nil)
+	s.do("GET", "/admin/users", admin.Token, nil)
+	// the metrics dashboard isn't audited
+	s.do("GET", "/admin/metrics", admin.Token, nil)
+
+	var entries []database.AdminAuditLog
+	s.expect(s.do("GET", "/admin/audit-log", admin.Token, nil), 200, &entries)
+	if len(entries) != 3 {
+		t.Fatalf("audit log = %+v", entries)
+	}
+	if e := entries[0]; e.Method != "GET" || e.Path != "/admin/users" || e.StatusCode != 200 {
+		t.Errorf("newest entry = %+v", e)
+	}
+	if e := entries[1]; !strings.HasSuffix(e.Path, "/logout") || e.StatusCode != 404 || e.ActorID.UUID != admin.ID {
+		t.Errorf("second entry = %+v", e)
+	}
+	if e := entries[2]; e.Method != "POST" || !strings.HasSuffix(e.Path, "/suspend") || e.StatusCode != 200 {
+		t.Errorf("oldest entry = %+v", e)
+	}
+	// reading the audit log is itself audited
+	s.expect(s.do("GET", "/admin/audit-log", admin.Token, nil), 200, &entries)
+	if len(entries) != 4 || entries[0].Path != "/admin/audit-log" {
+		t.Errorf("audit log after read = %+v", entries)
+	}
+}
+
+func TestAdminReset(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+	alice := s.signUp("alice@example.com")
+	s.expect(s.do("POST", "/api/chirps", alice.Token, map[string]string{"body": "hello"}), 201, nil)
+
+	rec := s.do("POST", "/admin/reset", admin.Token, nil)
+	if rec.Code != 200 || rec.Body.String() != "Hits: 0" {
+		t.Errorf("reset = %d %q", rec.Code, rec.Body)
+	}
+	var chirps []database.Chirp
+	s.expect(s.do("GET", "/api/chirps", "", nil), 200, &chirps)
+	if len(chirps) != 0 {
+		t.Errorf("%d chirps survived the reset", len(chirps))
+	}
+	s.expectProblem(s.do("POST", "/api/login", "", UserPayload{Email: "alice@example.com", Password: "hunter2"}), 401, codeInvalidCredentials)
+	s.expectProblem(s.do("GET", "/api/users/me/entitlements", alice.Token, nil), 401, codeInvalidToken)
+}
+
+// TestAuthenticationRequired checks that every route behind authentication
+// turns away a caller without credentials.
+func TestAuthenticationRequired(t *testing.T) {
+	s := newTestServer(t)
+	id := uuid.NewString()
+	for _, route := range []string{
+		"POST /api/chirps",
+		"GET /api/chirps/scheduled",
+		"PUT /api/chirps/" + id,
+		"DELETE /api/chirps/" + id,
+		"PUT /api/users",
+		"DELETE /api/users",
+		"GET /api/users/export",
+		"GET /api/users/me/entitlements",
+		"POST /api/passkeys/register/begin",
+		"POST /api/passkeys/register/finish",
+		"POST /api/oauth/clients",
+		"POST /api/oauth/authorize",
+		"GET /api/oauth/consents",
+		"DELETE /api/oauth/consents/client",
+		"POST /api/keys",
+		"GET /api/keys",
+		"DELETE /api/keys/" + id,
+		"POST /api/webhooks/endpoints",
+		"GET /api/webhooks/endpoints",
+		"DELETE /api/webhooks/endpoints/" + id,
+		"POST /api/webhooks/endpoints/" + id + "/enable",
+		"GET /api/webhooks/endpoints/" + id + "/deliveries",
+		"GET /admin/metrics",
+		"POST /admin/reset",
+		"GET /admin/audit-log",
+		"GET /admin/analytics/page-views",
+		"GET /admin/users",
+		"GET /admin/users/" + id,
+		"GET /admin/users/" + id + "/chirps",
+		"GET /admin/users/" + id + "/sessions",
+		"POST /admin/users/" + id + "/suspend",
+		"POST /admin/users/" + id + "/unsuspend",
+		"POST /admin/users/" + id + "/logout",
+		"PUT /admin/users/" + id + "/chirpy-red",
+		"GET /admin/webhooks/events",
+		"POST /admin/webhooks/events/polka/" + id + "/replay",
+		"POST /admin/webhooks/endpoints",
+		"GET /admin/webhooks/endpoints",
+		"DELETE /admin/webhooks/endpoints/" + id,
+		"POST /admin/webhooks/endpoints/" + id + "/enable",
+		"GET /admin/webhooks/endpoints/" + id + "/deliveries",
+	} {
+		method, path, _ := strings.Cut(route, " ")
+		rec := s.do(method, path, "", "{}")
+		if rec.Code != 401 || rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("%s = %d %s, want 401", route, rec.Code, rec.Body)
+		}
+	}
+
+	rec := s.do("POST", "/api/polka/webhooks", "", `{"event": "user.upgraded"}`)
+	if rec.Code != 401 {
+		t.Errorf("unsigned Polka webhook = %d %s, want 401", rec.Code, rec.Body)
+	}
+	s.expectProblem(s.do("GET", "/api/auth/nowhere/start", "", nil), 404, codeNotFound)
+	s.expectProblem(s.do("GET", "/api/auth/nowhere/callback", "", nil), 404, codeNotFound)
+
+	// the token endpoint answers in RFC 6749's format, not problem+json
+	req := s.request("POST", "/api/oauth/token", "grant_type=password")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = s.send(req)
+	var oauthErr struct {
+		Error string `json:"error"`
+	}
+	s.expect(rec, 400, &oauthErr)
+	if oauthErr.Error != "unsupported_grant_type" {
+		t.Errorf("token error = %q", oauthErr.Error)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+
+	var created apiKeyResponse
+	s.expect(s.do("POST", "/api/keys", alice.Token, map[string]any{"name": "ci", "scopes": []string{scopeChirpsWrite}}), 201, &created)
+	if created.Key == "" || created.Name != "ci" || len(created.Scopes) != 1 {
+		t.Errorf("created %+v", created)
+	}
+	var keys []apiKeyResponse
+	s.expect(s.do("GET", "/api/keys", alice.Token, nil), 200, &keys)
+	if len(keys) != 1 || keys[0].ID != created.ID || keys[0].Key != "" {
+		t.Errorf("listed %+v", keys)
+	}
+
+	withKey := func(method, path string, body any) *httptest.ResponseRecorder {
+		req := s.request(method, path, body)
+		req.Header.Set("Authorization", "ApiKey "+created.Key)
+		return s.send(req)
+	}
+	var chirp database.Chirp
+	s.expect(withKey("POST", "/api/chirps", map[string]string{"body": "from ci"}), 201, &chirp)
+	if chirp.UserID.UUID != alice.ID {
+		t.Errorf("chirp posted for %v, want %v", chirp.UserID.UUID, alice.ID)
+	}
+	// keys can't manage credentials, including themselves
+	s.expectProblem(withKey("GET", "/api/keys", nil), 403, codeInsufficientScope)
+	s.expectProblem(withKey("DELETE", "/api/keys/"+created.ID.String(), nil), 403, codeInsufficientScope)
+
+	s.expect(s.do("DELETE", "/api/keys/"+created.ID.String(), alice.Token, nil), 204, nil)
+	s.expectProblem(withKey("POST", "/api/chirps", map[string]string{"body": "again"}), 401, codeInvalidAPIKey)
+}
+
+func TestOAuthFlow(t *testing.T) {
+	s := newTestServer(t)
+	dev := s.signUp("dev@example.com")
+	alice := s.signUp("alice@example.com")
+
+	var client oauthClientResponse
+	s.expect(s.do("POST", "/api/oauth/clients", dev.Token, map[string]any{
+		"name":         "Scheduler",
+		"redirect_uri": "https://scheduler.example.com/callback",
+		"scopes":       []string{scopeChirpsWrite},
+		"confidential": true,
+	}), 201, &client)
+	if client.ClientID == "" || client.ClientSecret == "" {
+		t.Fatalf("created %+v", client)
+	}
+	var public oauthClientResponse
+	s.expect(s.do("GET", "/api/oauth/clients/"+client.ClientID, "", nil), 200, &public)
+	if public.Name != "Scheduler" || public.ClientSecret != "" {
+		t.Errorf("client info %+v", public)
+	}
+
+	verifier, _ := auth.MakePKCEVerifier()
+	authorize := func() string {
+		t.Helper()
+		var approved struct {
+			RedirectTo string `json:"redirect_to"`
+		}
+		s.expect(s.do("POST", "/api/oauth/authorize", alice.Token, map[string]any{
+			"response_type":         "code",
+			"client_id":             client.ClientID,
+			"redirect_uri":          client.RedirectURI,
+			"scope":                 scopeChirpsWrite,
+			"state":                 "xyz",
+			"code_challenge":        auth.PKCEChallenge(verifier),
+			"code_challenge_method": "S256",
+			"approve":               true,
+		}), 200, &approved)
+		redirect, err := url.Parse(approved.RedirectTo)
+		if err != nil || redirect.Query().Get("state") != "xyz" || redirect.Query().Get("code") == "" {
+			t.Fatalf("redirected to %q", approved.RedirectTo)
+		}
+		return redirect.Query().Get("code")
+	}
+
+	exchange := func(code string) *httptest.ResponseRecorder {
+		req := s.request("POST", "/api/oauth/token", url.Values{
+			"grant_type":    {"authorization_code"},
+			"client_id":     {client.ClientID},
+			"client_secret": {client.ClientSecret},
+			"code":          {code},
+			"redirect_uri":  {client.RedirectURI},
+			"code_verifier": {verifier},
+		}.Encode())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return s.send(req)
+	}
+	type tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		Scope       string `json:"scope"`
+	}
+	var token tokenResponse
+	code := authorize()
+	s.expect(exchange(code), 200, &token)
+	if token.TokenType != "Bearer" || token.Scope != scopeChirpsWrite {
+		t.Errorf("token %+v", token)
+	}
+	// codes are single use
+	s.expect(exchange(code), 400, nil)
+
+	var chirp database.Chirp
+	s.expect(s.do("POST", "/api/chirps", token.AccessToken, map[string]string{"body": "scheduled hello"}), 201, &chirp)
+	if chirp.UserID.UUID != alice.ID {
+		t.Errorf("chirp posted for %v, want %v", chirp.UserID.UUID, alice.ID)
+	}
+	s.expectProblem(s.do("GET", "/api/oauth/consents", token.AccessToken, nil), 403, codeInsufficientScope)
+
+	var consents []struct {
+		ClientID string   `json:"client_id"`
+		Scopes   []string `json:"scopes"`
+	}
+	s.expect(s.do("GET", "/api/oauth/consents", alice.Token, nil), 200, &consents)
+	if len(consents) != 1 || consents[0].ClientID != client.ClientID {
+		t.Errorf("consents %+v", consents)
+	}
+
+	// JWT timestamps have second precision, so revoke in a later second than
+	// the token was issued in
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	s.expect(s.do("DELETE", "/api/oauth/consents/"+client.ClientID, alice.Token, nil), 204, nil)
+	s.expectProblem(s.do("POST", "/api/chirps", token.AccessToken, map[string]string{"body": "revoked"}), 401, codeInvalidToken)
+
+	// consenting again issues new tokens without reviving the old one
+	var renewed tokenResponse
+	s.expect(exchange(authorize()), 200, &renewed)
+	s.expect(s.do("POST", "/api/chirps", renewed.AccessToken, map[string]string{"body": "welcome back"}), 201, nil)
+	s.expectProblem(s.do("POST", "/api/chirps", token.AccessToken, map[string]string{"body": "revived"}), 401, codeInvalidToken)
+}
+
+func TestIntersectScopes(t *testing.T) {
+	tests := []struct {
+		granted, allowed, want string
+	}{
+		{"chirps:write", "chirps:write", "chirps:write"},
+		{"chirps:write profile:read", "chirps:write", "chirps:write"},
+		{"chirps:write", "profile:read", ""},
+		{"", "chirps:write", ""},
+	}
+	for _, tt := range tests {
+		if got := intersectScopes(tt.granted, tt.allowed); got != tt.want {
+			t.Errorf("intersectScopes(%q, %q) = %q, want %q", tt.granted, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestPasskeys(t *testing.T) {
+	s := newTestServer(t)
+	s.api.webauthnRP = webauthn.RelyingParty{ID: "localhost", Name: "Chirpy", Origin: "http://localhost:8080"}
+	alice := s.signUp("alice@example.com")
+	authenticator, err := webauthntest.New("localhost", "http://localhost:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var options struct {
+		Challenge string `json:"challenge"`
+	}
+	// the credential ID sent alongside must be the one attested to
+	s.expect(s.do("POST", "/api/passkeys/register/begin", alice.Token, nil), 200, &options)
+	clientData, attestation := authenticator.Create(options.Challenge)
+	s.expectProblem(s.do("POST", "/api/passkeys/register/finish", alice.Token, map[string]any{
+		"rawId": base64URL("someone else's credential"),
+		"type":  passkeyCredentialType,
+		"response": map[string]base64URL{
+			"clientDataJSON":    clientData,
+			"attestationObject": attestation,
+		},
+	}), 400, codePasskeyFailed)
+
+	s.expect(s.do("POST", "/api/passkeys/register/begin", alice.Token, nil), 200, &options)
+	clientData, attestation = authenticator.Create(options.Challenge)
+	var passkey struct {
+		ID           uuid.UUID `json:"id"`
+		CredentialID base64URL `json:"credential_id"`
+	}
+	s.expect(s.do("POST", "/api/passkeys/register/finish", alice.Token, map[string]any{
+		"rawId": base64URL(authenticator.CredentialID),
+		"type":  passkeyCredentialType,
+		"response": map[string]base64URL{
+			"clientDataJSON":    clientData,
+			"attestationObject": attestation,
+		},
+	}), 201, &passkey)
+	if !bytes.Equal(passkey.CredentialID, authenticator.CredentialID) {
+		t.Errorf("registered credential %x, want %x", passkey.CredentialID, authenticator.CredentialID)
+	}
+
+	s.expect(s.do("POST", "/api/passkeys/login/begin", "", nil), 200, &options)
+	clientData, authData, signature := authenticator.Get(options.Challenge)
+	assertion := map[string]any{
+		"rawId": base64URL(authenticator.CredentialID),
+		"type":  passkeyCredentialType,
+		"response": map[string]base64URL{
+			"clientDataJSON":    clientData,
+			"authenticatorData": authData,
+			"signature":         signature,
+		},
+	}
+	var sess session
+	s.expect(s.do("POST", "/api/passkeys/login/finish", "", assertion), 200, &sess)
+	if sess.ID != alice.ID || sess.Token == "" || sess.RefreshToken == "" {
+		t.Errorf("signed in as %+v", sess)
+	}
+	// the challenge was consumed, so the assertion can't be replayed
+	s.expectProblem(s.do("POST", "/api/passkeys/login/finish", "", assertion), 400, codePasskeyFailed)
+}
+
+// addOIDCProvider starts a fake identity provider and configures it as
+// "fake".
+func (s *testServer) addOIDCProvider() *oidctest.Issuer {
+	s.t.Helper()
+	issuer, err := oidctest.New()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(issuer.Close)
+	s.api.oidcProviders = map[string]*oidc.Provider{
+		"fake": oidc.NewProvider(oidc.Config{
+			Issuer:      issuer.URL,
+			ClientID:    "chirpy",
+			RedirectURL: "http://localhost:8080/api/auth/fake/callback",
+		}, issuer.Client()),
+	}
+	return issuer
+}
+
+// oidcCallback starts a sign in with the fake provider and returns the
+// callback the provider redirects back to.
+func (s *testServer) oidcCallback(issuer *oidctest.Issuer) string {
+	s.t.Helper()
+	rec := s.do("GET", "/api/auth/fake/start", "", nil)
+	if rec.Code != 302 {
+		s.t.Fatalf("start = %d %s, want 302", rec.Code, rec.Body)
+	}
+	code, state, err := issuer.Authorize(rec.Header().Get("Location"))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return "/api/auth/fake/callback?" + url.Values{"code": {code}, "state": {state}}.Encode()
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	s := newTestServer(t)
+	issuer := s.addOIDCProvider()
+	alice := s.signUp(issuer.Email)
+
+	// a verified email links the identity to the existing account
+	var sess session
+	s.expect(s.do("GET", s.oidcCallback(issuer), "", nil), 200, &sess)
+	if sess.ID != alice.ID || sess.Token == "" {
+		t.Errorf("first sign in as %+v, want %v", sess, alice.ID)
+	}
+	callback := s.oidcCallback(issuer)
+	s.expect(s.do("GET", callback, "", nil), 200, &sess)
+	if sess.ID != alice.ID {
+		t.Errorf("second sign in as %+v, want %v", sess, alice.ID)
+	}
+	s.expectProblem(s.do("GET", callback, "", nil), 400, codeInvalidState)
+}
+
+func TestOIDCUnverifiedEmail(t *testing.T) {
+	s := newTestServer(t)
+	issuer := s.addOIDCProvider()
+	issuer.EmailVerified = false
+
+	// an unverified email neither claims the address nor links to it
+	s.expectProblem(s.do("GET", s.oidcCallback(issuer), "", nil), 400, codeIdentityProviderError)
+	alice := s.signUp(issuer.Email)
+	s.expectProblem(s.do("GET", s.oidcCallback(issuer), "", nil), 409, codeEmailTaken)
+
+	// once the provider vouches for the email, the owner's account is used
+	issuer.EmailVerified = true
+	var sess session
+	s.expect(s.do("GET", s.oidcCallback(issuer), "", nil), 200, &sess)
+	if sess.ID != alice.ID {
+		t.Errorf("signed in as %+v, want %v", sess, alice.ID)
+	}
+}
+
+func TestPurgeExpiredLoginStates(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	for state, expiresAt := range map[string]time.Time{
+		"abandoned": time.Now().Add(-time.Minute),
+		"pending":   time.Now().Add(oidcLoginStateTTL),
+	} {
+		err := s.db.CreateOIDCLoginState(ctx, database.CreateOIDCLoginStateParams{State: state, ExpiresAt: expiresAt, Provider: "fake"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.api.purgeExpiredLoginStates(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.ConsumeOIDCLoginState(ctx, "abandoned"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("expired state: %v, want ErrNotFound", err)
+	}
+	if _, err := s.db.ConsumeOIDCLoginState(ctx, "pending"); err != nil {
+		t.Errorf("pending state: %v", err)
+	}
+}
+
+func TestExportUser(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.signUp("alice@example.com")
+	s.expect(s.do("POST", "/api/chirps", alice.Token, map[string]string{"body": "hello"}), 201, nil)
+	s.expect(s.do("POST", "/api/keys", alice.Token, map[string]any{"name": "ci"}), 201, nil)
+
+	rec := s.do("GET", "/api/users/export", alice.Token, nil)
+	s.expect(rec, 200, nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = string(data)
+	}
+	for name, want := range map[string]string{
+		"profile.json":        "alice@example.com",
+		"chirps.json":         "hello",
+		"sessions.json":       "expires_at",
+		"identities.json":     "[]",
+		"api_keys.json":       `"ci"`,
+		"passkeys.json":       "[]",
+		"oauth_consents.json": "[]",
+		"subscription.json":   "null",
+	} {
+		if !strings.Contains(files[name], want) {
+			t.Errorf("%s = %q, want it to contain %s", name, files[name], want)
+		}
+	}
+	if strings.Contains(files["chirps.json"], "user_id") {
+		t.Errorf("chirps.json = %s, want the export format rather than database rows", files["chirps.json"])
+	}
+	if strings.Contains(files["sessions.json"], alice.RefreshToken) {
+		t.Error("export includes a refresh token")
+	}
+}
+
+// roundTripFunc lets a function stand in for a webhook receiver.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+	alice := s.signUp("alice@example.com")
+	var received []string
+	s.api.webhookSender = webhook.Sender{
+		Prefix: "Chirpy",
+		Client: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			received = append(received, r.URL.String()+" "+r.Header.Get("Chirpy-Event"))
+			return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("thanks")), Request: r}, nil
+		})},
+	}
+
+	var endpoint, adminEndpoint webhookEndpointResponse
+	s.expect(s.do("POST", "/api/webhooks/endpoints", alice.Token, map[string]any{
+		"url":    "https://hooks.example.com/alice",
+		"events": []string{eventChirpCreated},
+	}), 201, &endpoint)
+	if endpoint.Secret == "" {
+		t.Errorf("created %+v", endpoint)
+	}
+	s.expect(s.do("POST", "/admin/webhooks/endpoints", admin.Token, map[string]any{
+		"url":    "https://hooks.example.com/admin",
+		"events": []string{eventChirpCreated, eventChirpDeleted},
+	}), 201, &adminEndpoint)
+
+	var endpoints []webhookEndpointResponse
+	s.expect(s.do("GET", "/api/webhooks/endpoints", alice.Token, nil), 200, &endpoints)
+	if len(endpoints) != 1 || endpoints[0].ID != endpoint.ID || endpoints[0].Secret != "" {
+		t.Errorf("alice's endpoints %+v", endpoints)
+	}
+	s.expect(s.do("GET", "/admin/webhooks/endpoints", admin.Token, nil), 200, &endpoints)
+	if len(endpoints) != 1 || endpoints[0].ID != adminEndpoint.ID {
+		t.Errorf("admin endpoints %+v", endpoints)
+	}
+	// admin endpoints aren't reachable through the user routes
+	s.expectProblem(s.do("GET", "/api/webhooks/endpoints/"+adminEndpoint.ID.String()+"/deliveries", admin.Token, nil), 404, codeNotFound)
+
+	s.expect(s.do("POST", "/api/chirps", alice.Token, map[string]string{"body": "hello"}), 201, nil)
+	if err := s.api.deliverWebhooks(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(received)
+	want := []string{
+		"https://hooks.example.com/admin " + eventChirpCreated,
+		"https://hooks.example.com/alice " + eventChirpCreated,
+	}
+	if !slices.Equal(received, want) {
+		t.Errorf("received %q, want %q", received, want)
+	}
+
+	var deliveries []webhookDeliveryResponse
+	s.expect(s.do("GET", "/api/webhooks/endpoints/"+endpoint.ID.String()+"/deliveries", alice.Token, nil), 200, &deliveries)
+	if len(deliveries) != 1 || deliveries[0].Status != "succeeded" || deliveries[0].LastStatusCode != 200 || deliveries[0].LastResponse != "thanks" {
+		t.Errorf("alice's deliveries %+v", deliveries)
+	}
+	s.expect(s.do("GET", "/admin/webhooks/endpoints/"+adminEndpoint.ID.String()+"/deliveries", admin.Token, nil), 200, &deliveries)
+	if len(deliveries) != 1 || deliveries[0].Event != eventChirpCreated {
+		t.Errorf("admin deliveries %+v", deliveries)
+	}
+
+	s.expect(s.do("POST", "/api/webhooks/endpoints/"+endpoint.ID.String()+"/enable", alice.Token, nil), 200, nil)
+	s.expect(s.do("POST", "/admin/webhooks/endpoints/"+adminEndpoint.ID.String()+"/enable", admin.Token, nil), 200, nil)
+	s.expect(s.do("DELETE", "/api/webhooks/endpoints/"+endpoint.ID.String(), alice.Token, nil), 204, nil)
+	s.expect(s.do("DELETE", "/admin/webhooks/endpoints/"+adminEndpoint.ID.String(), admin.Token, nil), 204, nil)
+	s.expect(s.do("GET", "/api/webhooks/endpoints", alice.Token, nil), 200, &endpoints)
+	if len(endpoints) != 0 {
+		t.Errorf("endpoints after delete %+v", endpoints)
+	}
+}
+
+func TestAdminWebhookEvents(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+	secret := []byte("polka-secret")
+	s.api.polkaWebhookSecrets = [][]byte{secret}
+
+	// an upgrade for a user Chirpy doesn't know can never be applied
+	body := `{"id": "evt_1", "event": "user.upgraded", "data": {"user_id": "` + uuid.NewString() + `"}}`
+	now := time.Now()
+	req := s.request("POST", "/api/polka/webhooks", body)
+	req.Header.Set("Polka-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set("Polka-Signature", webhook.Sign(secret, now, []byte(body)))
+	s.expect(s.send(req), 204, nil)
+	if err := s.api.processWebhookInbox(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []webhookEventResponse
+	s.expect(s.do("GET", "/admin/webhooks/events", admin.Token, nil), 200, &events)
+	if len(events) != 1 || events[0].ID != "evt_1" || events[0].Source != polkaSource || events[0].LastError == "" {
+		t.Fatalf("dead events %+v", events)
+	}
+
+	replay := "/admin/webhooks/events/" + polkaSource + "/evt_1/replay"
+	var replayed webhookEventResponse
+	s.expect(s.do("POST", replay, admin.Token, nil), 202, &replayed)
+	if replayed.Status != "pending" || replayed.Attempts != 0 {
+		t.Errorf("replayed %+v", replayed)
+	}
+	s.expect(s.do("GET", "/admin/webhooks/events?status=pending", admin.Token, nil), 200, &events)
+	if len(events) != 1 || events[0].ID != "evt_1" {
+		t.Errorf("pending events %+v", events)
+	}
+	s.expectProblem(s.do("POST", replay, admin.Token, nil), 404, codeNotFound)
+	s.expectProblem(s.do("GET", "/admin/webhooks/events?limit=0", admin.Token, nil), 400, codeValidation)
+}
+
+func TestPageViewStats(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+
+	s.api.trustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	page := s.api.middlewareMetricsInc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, visitor := range []struct{ remoteAddr, forwardedFor string }{
+		{"192.0.2.1:1234", ""},
+		{"192.0.2.1:5678", ""},
+		{"192.0.2.2:1234", ""},
+		// two visitors behind the same load balancer
+		{"10.0.0.1:1234", "198.51.100.1"},
+		{"10.0.0.1:1234", "198.51.100.2"},
+	} {
+		req := httptest.NewRequest("GET", "/app/", nil)
+		req.RemoteAddr = visitor.remoteAddr
+		if visitor.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", visitor.forwardedFor)
+		}
+		page.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest("GET", "/app/about", nil)
+	page.ServeHTTP(httptest.NewRecorder(), req)
+	if err := s.api.flushPageViews(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	type stats struct {
+		Path        string `json:"path"`
+		Views       int64  `json:"views"`
+		VisitorDays uint64 `json:"visitor_days"`
+		Days        []struct {
+			Day            string `json:"day"`
+			Views          int64  `json:"views"`
+			UniqueVisitors uint64 `json:"unique_visitors"`
+		} `json:"days"`
+	}
+	var all, app stats
+	s.expect(s.do("GET", "/admin/analytics/page-views", admin.Token, nil), 200, &all)
+	if all.Views != 6 || len(all.Days) != 1 || all.Days[0].Day != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("all pages %+v", all)
+	}
+	s.expect(s.do("GET", "/admin/analytics/page-views?days=7&path=/app/", admin.Token, nil), 200, &app)
+	if app.Path != "/app/" || app.Views != 5 || app.VisitorDays != 4 || len(app.Days) != 1 || app.Days[0].UniqueVisitors != 4 {
+		t.Errorf("/app/ %+v", app)
+	}
+	s.expectProblem(s.do("GET", "/admin/analytics/page-views?days=0", admin.Token, nil), 400, codeValidation)
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.0.2.7/32")}
+	for _, tc := range []struct {
+		remoteAddr, forwardedFor, want string
+	}{
+		{"198.51.100.1:1234", "", "198.51.100.1"},
+		// an untrusted peer can't claim to be someone else
+		{"198.51.100.1:1234", "203.0.113.9", "198.51.100.1"},
+		{"10.0.0.1:1234", "203.0.113.9", "203.0.113.9"},
+		// only the hops added by trusted proxies are followed
+		{"10.0.0.1:1234", "203.0.113.9, 198.51.100.1, 192.0.2.7", "198.51.100.1"},
+		{"10.0.0.1:1234", "10.0.0.2, 10.0.0.3", "10.0.0.2"},
+		{"[::ffff:10.0.0.1]:1234", "203.0.113.9", "203.0.113.9"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+		if got := clientIP(req, trusted); got != tc.want {
+			t.Errorf("clientIP(%s, %q) = %s, want %s", tc.remoteAddr, tc.forwardedFor, got, tc.want)
+		}
+	}
+}
+
+func TestLoadVisitorSalt(t *testing.T) {
+	db := memstore.New()
+	ctx := context.Background()
+
+	configured, err := loadVisitorSalt(ctx, db, "configured")
+	if err != nil || string(configured) != "configured" {
+		t.Fatalf("configured salt = %q, %v", configured, err)
+	}
+	// without a configured salt every instance shares the stored one
+	first, err := loadVisitorSalt(ctx, db, "")
+	if err != nil || len(first) != 32 {
+		t.Fatalf("generated salt = %x, %v", first, err)
+	}
+	second, err := loadVisitorSalt(ctx, db, "")
+	if err != nil || !bytes.Equal(first, second) {
+		t.Errorf("second salt = %x, %v, want %x", second, err, first)
+	}
+}
+
+func TestAdminMetrics(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.signUpAdmin("admin@example.com")
+	s.expect(s.do("POST", "/api/chirps", admin.Token, map[string]string{"body": "hello"}), 201, nil)
+
+	rec := s.do("GET", "/admin/metrics", admin.Token, nil)
+	s.expect(rec, 200, nil)
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "admin@example.com") {
+		t.Error("dashboard doesn't list the top author")
+	}
+}
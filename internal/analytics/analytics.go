@@ -0,0 +1,83 @@
+// Package analytics counts page views in memory so they can be written to
+// the database in periodic batches instead of on every request.
+package analytics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/hll"
+)
+
+// Bucket is the views of one path on one UTC day.
+type Bucket struct {
+	Path     string
+	Day      time.Time
+	Views    int64
+	Visitors *hll.Sketch
+}
+
+type key struct {
+	path string
+	day  time.Time
+}
+
+// Counter accumulates page views until they are drained.
+type Counter struct {
+	mu      sync.Mutex
+	buckets map[key]*Bucket
+}
+
+func NewCounter() *Counter {
+	return &Counter{buckets: map[key]*Bucket{}}
+}
+
+// Day truncates t to the start of its UTC day.
+func Day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Record counts a view of path at t by the visitor identified by
+// visitorHash.
+func (c *Counter) Record(path string, visitorHash uint64, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b := c.bucket(path, Day(t))
+	b.Views++
+	b.Visitors.Add(visitorHash)
+}
+
+func (c *Counter) bucket(path string, day time.Time) *Bucket {
+	k := key{path, day}
+	b, ok := c.buckets[k]
+	if !ok {
+		b = &Bucket{Path: path, Day: day, Visitors: hll.New()}
+		c.buckets[k] = b
+	}
+	return b
+}
+
+// Drain returns everything counted since the last Drain and resets the
+// counter.
+func (c *Counter) Drain() []Bucket {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drained := make([]Bucket, 0, len(c.buckets))
+	for _, b := range c.buckets {
+		drained = append(drained, *b)
+	}
+	c.buckets = map[key]*Bucket{}
+	return drained
+}
+
+// Restore adds buckets back, typically ones that failed to flush, so they
+// are included in the next Drain.
+func (c *Counter) Restore(buckets []Bucket) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range buckets {
+		b := c.bucket(r.Path, r.Day)
+		b.Views += r.Views
+		b.Visitors.Merge(r.Visitors)
+	}
+}
@@ -0,0 +1,61 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+)
+
+// visitor hashes must look random for the sketch to tell them apart
+const (
+	visitorA = 0x9e3779b97f4a7c15
+	visitorB = 0x2545f4914f6cdd1d
+)
+
+func TestRecordAndDrain(t *testing.T) {
+	c := NewCounter()
+	day1 := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+
+	c.Record("/app/", visitorA, day1)
+	c.Record("/app/", visitorA, day1.Add(time.Hour))
+	c.Record("/app/", visitorB, day1.Add(2*time.Hour))
+	c.Record("/app/", visitorA, day2)
+	c.Record("/app/logo.png", visitorA, day1)
+
+	got := map[string]Bucket{}
+	for _, b := range c.Drain() {
+		got[b.Path+" "+b.Day.Format("2006-01-02")] = b
+	}
+	if len(got) != 3 {
+		t.Fatalf("Drain() returned %d buckets, want 3", len(got))
+	}
+	b := got["/app/ 2026-10-19"]
+	if b.Views != 3 || b.Visitors.Count() != 2 {
+		t.Errorf("/app/ day 1: views=%d visitors=%d, want 3 and 2", b.Views, b.Visitors.Count())
+	}
+	if b := got["/app/ 2026-10-20"]; b.Views != 1 {
+		t.Errorf("/app/ day 2: views=%d, want 1", b.Views)
+	}
+
+	if rest := c.Drain(); len(rest) != 0 {
+		t.Errorf("second Drain() returned %d buckets, want 0", len(rest))
+	}
+}
+
+func TestRestore(t *testing.T) {
+	c := NewCounter()
+	now := time.Now()
+	c.Record("/app/", visitorA, now)
+	failed := c.Drain()
+
+	c.Record("/app/", visitorB, now)
+	c.Restore(failed)
+
+	drained := c.Drain()
+	if len(drained) != 1 {
+		t.Fatalf("Drain() returned %d buckets, want 1", len(drained))
+	}
+	if drained[0].Views != 2 || drained[0].Visitors.Count() != 2 {
+		t.Errorf("views=%d visitors=%d, want 2 and 2", drained[0].Views, drained[0].Visitors.Count())
+	}
+}
@@ -1,11 +1,19 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"net/http"
 	"strings"
 )
 
+// personal API keys look like chirpy_<prefix>_<secret>; the
+// "chirpy_<prefix>" part is stored in clear so users can tell keys apart
+const apiKeyPrefix = "chirpy_"
+
 func GetAPIKey(headers http.Header) (string, error) {
 	a := headers.Get("Authorization")
 
@@ -17,3 +25,38 @@ func GetAPIKey(headers http.Header) (string, error) {
 
 	return parts[1], nil
 }
+
+// MakeAPIKey returns a new personal API key and its visible prefix.
+func MakeAPIKey() (key, prefix string, err error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = apiKeyPrefix + hex.EncodeToString(id)
+	return prefix + "_" + hex.EncodeToString(secret), prefix, nil
+}
+
+// APIKeyPrefix returns the visible prefix of a personal API key.
+func APIKeyPrefix(key string) (string, error) {
+	i := strings.LastIndex(key, "_")
+	if !strings.HasPrefix(key, apiKeyPrefix) || i <= len(apiKeyPrefix) {
+		return "", fmt.Errorf("invalid api key")
+	}
+	return key[:i], nil
+}
+
+// HashAPIKey returns the value stored for a personal API key. Keys are
+// high-entropy, so a fast hash is enough and keeps lookups cheap.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKeyHash reports whether key matches a hash from HashAPIKey.
+func CheckAPIKeyHash(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetAPIKey_Success(t *testing.T) {
+	headers := make(http.Header)
+	headers.Add("Authorization", "ApiKey foo")
+
+	result, err := GetAPIKey(headers)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result != "foo" {
+		t.Fatalf("expected %s, got %s", "foo", result)
+	}
+}
+
+func TestMakeAPIKey_PrefixRoundTrip(t *testing.T) {
+	key, prefix, err := MakeAPIKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(key, prefix+"_") {
+		t.Fatalf("key %q does not start with prefix %q", key, prefix)
+	}
+
+	got, err := APIKeyPrefix(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got != prefix {
+		t.Fatalf("APIKeyPrefix = %q, want %q", got, prefix)
+	}
+}
+
+func TestAPIKeyPrefix_Invalid(t *testing.T) {
+	for _, key := range []string{"", "foo", "chirpy_", "chirpy_abc", "other_abc_def"} {
+		if _, err := APIKeyPrefix(key); err == nil {
+			t.Errorf("APIKeyPrefix(%q): expected error, got nil", key)
+		}
+	}
+}
+
+func TestCheckAPIKeyHash(t *testing.T) {
+	key, _, err := MakeAPIKey()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	hash := HashAPIKey(key)
+
+	if !CheckAPIKeyHash(key, hash) {
+		t.Fatalf("expected key to match its hash")
+	}
+	if CheckAPIKeyHash(key+"0", hash) {
+		t.Fatalf("expected a different key not to match")
+	}
+}
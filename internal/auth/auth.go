@@ -1,17 +1,28 @@
 package auth
 
-import "github.com/alexedwards/argon2id"
+import (
+	"context"
 
-func HashPassword(password string) (string, error) {
+	"github.com/alexedwards/argon2id"
+	"github.com/mattcollier/boot-go-server/internal/tracing"
+)
+
+// HashPassword hashes with argon2id. It is deliberately slow, so it gets a
+// span of its own.
+func HashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracing.Start(ctx, "argon2id.CreateHash")
 	hash, err := argon2id.CreateHash(password, argon2id.DefaultParams)
+	tracing.End(span, err)
 	if err != nil {
 		return "", err
 	}
 	return hash, nil
 }
 
-func CheckPasswordHash(password, hash string) (bool, error) {
+func CheckPasswordHash(ctx context.Context, password, hash string) (bool, error) {
+	_, span := tracing.Start(ctx, "argon2id.ComparePasswordAndHash")
 	match, err := argon2id.ComparePasswordAndHash(password, hash)
+	tracing.End(span, err)
 	if err != nil {
 		return false, err
 	}
@@ -2,7 +2,6 @@ package auth
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -11,28 +10,76 @@ import (
 	"github.com/google/uuid"
 )
 
+// Claims are the claims carried by Chirpy access tokens. Scope and ClientID
+// are only set on tokens issued to third-party OAuth clients.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+
+	// UserID is the parsed subject, set by ParseJWT
+	UserID uuid.UUID `json:"-"`
+}
+
+// HasScope reports whether the token grants scope. First-party tokens carry
+// no scope claim and are allowed to do everything the user can.
+func (c *Claims) HasScope(scope string) bool {
+	if c.ClientID == "" {
+		return true
+	}
+	return ScopeIncludes(c.Scope, scope)
+}
+
+// ScopeIncludes reports whether the space separated list granted contains
+// scope.
+func ScopeIncludes(granted, scope string) bool {
+	for _, s := range strings.Fields(granted) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	return MakeScopedJWT(userID, tokenSecret, expiresIn, "", nil)
+}
+
+// MakeScopedJWT mints an access token on behalf of userID for a third-party
+// client, limited to scopes.
+func MakeScopedJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration, clientID string, scopes []string) (string, error) {
 	now := time.Now()
-	claims := jwt.RegisteredClaims{
-		// Also fixed dates can be used for the NumericDate
-		IssuedAt:  jwt.NewNumericDate(now),
-		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
-		Issuer:    "chirpy",
-		Subject:   userID.String(),
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			// Also fixed dates can be used for the NumericDate
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			Issuer:    "chirpy",
+			Subject:   userID.String(),
+		},
+		Scope:    strings.Join(scopes, " "),
+		ClientID: clientID,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	ss, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
-		log.Printf("Error signing jwt: %s", err)
-		return "", err
+		return "", fmt.Errorf("signing jwt: %w", err)
 	}
-	// fmt.Println(ss, err)
 	return ss, nil
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims := &jwt.RegisteredClaims{}
+	claims, err := ParseJWT(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID, nil
+}
+
+// ParseJWT validates an access token and returns its claims.
+func ParseJWT(tokenString, tokenSecret string) (*Claims, error) {
+	claims := &Claims{}
 	keyfunc := func(token *jwt.Token) (interface{}, error) {
 		// Enforce HS256
 		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
@@ -42,26 +89,25 @@ func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 	}
 	parsedToken, err := jwt.ParseWithClaims(tokenString, claims, keyfunc)
 	if err != nil {
-		log.Printf("ParseWithClaims error: %v", err)
-		return uuid.Nil, err
+		return nil, err
 	}
 	if !parsedToken.Valid {
-		log.Printf("parsed token is not valid")
-		return uuid.Nil, fmt.Errorf("parsed token is not valid")
+		return nil, fmt.Errorf("parsed token is not valid")
 	}
 
 	// Static claims
 	if claims.Issuer != "chirpy" {
-		log.Printf("Issuer = %q, want %q", claims.Issuer, "chirpy")
-		return uuid.Nil, fmt.Errorf("issuer = %q, want %q", claims.Issuer, "chirpy")
+		return nil, fmt.Errorf("issuer = %q, want %q", claims.Issuer, "chirpy")
 	}
 
 	userID, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid subject")
+		return nil, fmt.Errorf("invalid subject")
 	}
 
-	return userID, nil
+	claims.UserID = userID
+
+	return claims, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
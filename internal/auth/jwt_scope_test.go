@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMakeScopedJWT_RoundTrip(t *testing.T) {
+	userID := uuid.New()
+	secret := "secret"
+
+	tokenStr, err := MakeScopedJWT(userID, secret, time.Hour, "client-1", []string{"chirps:write"})
+	if err != nil {
+		t.Fatalf("MakeScopedJWT returned error: %v", err)
+	}
+
+	claims, err := ParseJWT(tokenStr, secret)
+	if err != nil {
+		t.Fatalf("ParseJWT returned error: %v", err)
+	}
+	if claims.UserID != userID {
+		t.Errorf("UserID = %v, want %v", claims.UserID, userID)
+	}
+	if claims.ClientID != "client-1" {
+		t.Errorf("ClientID = %q, want %q", claims.ClientID, "client-1")
+	}
+	if !claims.HasScope("chirps:write") {
+		t.Errorf("expected chirps:write scope")
+	}
+	if claims.HasScope("users:write") {
+		t.Errorf("did not expect users:write scope")
+	}
+
+	// scoped tokens are still accepted by ValidateJWT
+	got, err := ValidateJWT(tokenStr, secret)
+	if err != nil {
+		t.Fatalf("ValidateJWT returned error: %v", err)
+	}
+	if got != userID {
+		t.Fatalf("ValidateJWT userID = %v, want %v", got, userID)
+	}
+}
+
+func TestParseJWT_FirstPartyHasAllScopes(t *testing.T) {
+	tokenStr, err := MakeJWT(uuid.New(), "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT returned error: %v", err)
+	}
+
+	claims, err := ParseJWT(tokenStr, "secret")
+	if err != nil {
+		t.Fatalf("ParseJWT returned error: %v", err)
+	}
+	if !claims.HasScope("users:write") {
+		t.Fatalf("expected first-party token to have every scope")
+	}
+}
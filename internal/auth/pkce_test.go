@@ -0,0 +1,46 @@
+package auth
+
+import "testing"
+
+func TestPKCEChallenge_RFC7636Example(t *testing.T) {
+	// Appendix B of RFC 7636
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if got := PKCEChallenge(verifier); got != want {
+		t.Fatalf("PKCEChallenge = %q, want %q", got, want)
+	}
+}
+
+func TestMakePKCEVerifier_Unique(t *testing.T) {
+	a, err := MakePKCEVerifier()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	b, err := MakePKCEVerifier()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if a == b {
+		t.Fatalf("expected distinct verifiers, got %q twice", a)
+	}
+	// 32 random bytes encode to 43 unpadded base64url characters
+	if len(a) != 43 {
+		t.Fatalf("verifier length = %d, want 43", len(a))
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier, err := MakePKCEVerifier()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	challenge := PKCEChallenge(verifier)
+
+	if !VerifyPKCE(verifier, challenge) {
+		t.Fatalf("expected verifier to match its own challenge")
+	}
+	if VerifyPKCE(verifier+"x", challenge) {
+		t.Fatalf("expected a different verifier not to match")
+	}
+}
@@ -0,0 +1,553 @@
+// Package config loads Chirpy's settings. Each setting can come from a
+// config file, an environment variable or a command-line flag; flags win
+// over the environment, which wins over the file, which wins over the
+// defaults.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is every setting the server needs.
+type Config struct {
+	Port         int
+	FilepathRoot string
+	Platform     string
+	DBURL        string
+	// apply pending migrations at startup instead of refusing to serve
+	AutoMigrate bool
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// how long in-flight requests get to finish once shutdown starts
+	ShutdownTimeout time.Duration
+	// how long readiness fails before the listener closes, so load
+	// balancers can stop routing here first
+	ShutdownDelay time.Duration
+	// proxies whose X-Forwarded-For header is believed; empty means clients
+	// connect directly
+	TrustedProxies []netip.Prefix
+
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// any of these may sign Polka webhooks; two are active during rotation
+	PolkaWebhookSecrets []string
+
+	MaxChirpLength          int
+	ChirpyRedMaxChirpLength int
+
+	WebAuthnRPID     string
+	WebAuthnRPOrigin string
+
+	// keys the visitor hashes behind unique page view counts; when empty a
+	// salt is generated and kept in the database
+	AnalyticsVisitorSalt string
+
+	// single sign-on providers, in the order oidc.providers lists them
+	OIDCProviders []OIDCProvider
+
+	LogLevel  string
+	LogFormat string
+
+	// none, stdout or otlp
+	TracingExporter string
+	// OTLP/HTTP collector URL; empty uses the OTEL_EXPORTER_OTLP_* variables
+	TracingOTLPEndpoint string
+	// fraction of traces started here that are recorded
+	TracingSampleRatio float64
+}
+
+// OIDCProvider is an OpenID Connect provider users can sign in with. Its
+// settings live under oidc.<name>, e.g. oidc.google.issuer or
+// OIDC_GOOGLE_ISSUER.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+// Default returns the settings used when nothing overrides them. Required
+// settings such as the database URL have no default.
+func Default() Config {
+	return Config{
+		Port:                    8080,
+		FilepathRoot:            ".",
+		ReadHeaderTimeout:       5 * time.Second,
+		ReadTimeout:             15 * time.Second,
+		WriteTimeout:            30 * time.Second,
+		IdleTimeout:             2 * time.Minute,
+		MaxHeaderBytes:          1 << 20,
+		ShutdownTimeout:         30 * time.Second,
+		AccessTokenTTL:          time.Hour,
+		RefreshTokenTTL:         60 * 24 * time.Hour,
+		MaxChirpLength:          140,
+		ChirpyRedMaxChirpLength: 1000,
+		WebAuthnRPID:            "localhost",
+		LogLevel:                "info",
+		LogFormat:               "json",
+		TracingExporter:         "none",
+		TracingSampleRatio:      1,
+	}
+}
+
+// setting describes where one Config field can be set from. An empty flag
+// means the setting can't be passed on the command line, which is the case
+// for secrets since arguments are visible to other users of the machine.
+type setting struct {
+	key   string
+	env   []string // checked in order
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{"port", []string{"PORT"}, "port", "port to listen on", setInt(func(c *Config) *int { return &c.Port })},
+	{"filepath_root", []string{"FILEPATH_ROOT"}, "filepath-root", "directory served under /app/", setString(func(c *Config) *string { return &c.FilepathRoot })},
+	{"platform", []string{"PLATFORM"}, "platform", `"dev" enables destructive admin endpoints`, setString(func(c *Config) *string { return &c.Platform })},
+	{"server.read_header_timeout", []string{"HTTP_READ_HEADER_TIMEOUT"}, "read-header-timeout", "time allowed to read request headers", setDuration(func(c *Config) *time.Duration { return &c.ReadHeaderTimeout })},
+	{"server.read_timeout", []string{"HTTP_READ_TIMEOUT"}, "read-timeout", "time allowed to read a whole request", setDuration(func(c *Config) *time.Duration { return &c.ReadTimeout })},
+	{"server.write_timeout", []string{"HTTP_WRITE_TIMEOUT"}, "write-timeout", "time allowed to write a response", setDuration(func(c *Config) *time.Duration { return &c.WriteTimeout })},
+	{"server.idle_timeout", []string{"HTTP_IDLE_TIMEOUT"}, "idle-timeout", "how long idle keep-alive connections stay open", setDuration(func(c *Config) *time.Duration { return &c.IdleTimeout })},
+	{"server.max_header_bytes", []string{"HTTP_MAX_HEADER_BYTES"}, "max-header-bytes", "largest request header accepted", setInt(func(c *Config) *int { return &c.MaxHeaderBytes })},
+	{"server.shutdown_timeout", []string{"SHUTDOWN_TIMEOUT"}, "shutdown-timeout", "how long to drain requests on shutdown", setDuration(func(c *Config) *time.Duration { return &c.ShutdownTimeout })},
+	{"server.shutdown_delay", []string{"SHUTDOWN_DELAY"}, "shutdown-delay", "how long to fail readiness before draining", setDuration(func(c *Config) *time.Duration { return &c.ShutdownDelay })},
+	{"server.trusted_proxies", []string{"TRUSTED_PROXIES"}, "trusted-proxies", "comma separated addresses or CIDR ranges of proxies whose X-Forwarded-For is trusted", setPrefixes(func(c *Config) *[]netip.Prefix { return &c.TrustedProxies })},
+	{"database.url", []string{"DB_URL"}, "db-url", "Postgres connection URL", setString(func(c *Config) *string { return &c.DBURL })},
+	{"database.auto_migrate", []string{"AUTO_MIGRATE"}, "auto-migrate", "apply pending migrations at startup", setBool(func(c *Config) *bool { return &c.AutoMigrate })},
+	{"auth.jwt_secret", []string{"JWT_TOKEN_SECRET"}, "", "", setString(func(c *Config) *string { return &c.JWTSecret })},
+	{"auth.access_token_ttl", []string{"ACCESS_TOKEN_TTL"}, "access-token-ttl", "lifetime of access tokens", setDuration(func(c *Config) *time.Duration { return &c.AccessTokenTTL })},
+	{"auth.refresh_token_ttl", []string{"REFRESH_TOKEN_TTL"}, "refresh-token-ttl", "lifetime of refresh tokens", setDuration(func(c *Config) *time.Duration { return &c.RefreshTokenTTL })},
+	// POLKA_KEY is still accepted as a single secret for older deployments
+	{"polka.webhook_secrets", []string{"POLKA_WEBHOOK_SECRETS", "POLKA_KEY"}, "", "", setList(func(c *Config) *[]string { return &c.PolkaWebhookSecrets })},
+	{"chirps.max_length", []string{"MAX_CHIRP_LENGTH"}, "max-chirp-length", "longest chirp on the free plan", setInt(func(c *Config) *int { return &c.MaxChirpLength })},
+	{"chirps.chirpy_red_max_length", []string{"CHIRPY_RED_MAX_CHIRP_LENGTH"}, "chirpy-red-max-chirp-length", "longest chirp with Chirpy Red", setInt(func(c *Config) *int { return &c.ChirpyRedMaxChirpLength })},
+	{"webauthn.rp_id", []string{"WEBAUTHN_RP_ID"}, "webauthn-rp-id", "passkey relying party ID (the site's domain)", setString(func(c *Config) *string { return &c.WebAuthnRPID })},
+	{"log.level", []string{"LOG_LEVEL"}, "log-level", "debug, info, warn or error", setString(func(c *Config) *string { return &c.LogLevel })},
+	{"log.format", []string{"LOG_FORMAT"}, "log-format", "json or text", setString(func(c *Config) *string { return &c.LogFormat })},
+	{"tracing.exporter", []string{"TRACING_EXPORTER"}, "tracing-exporter", "none, stdout or otlp", setString(func(c *Config) *string { return &c.TracingExporter })},
+	{"tracing.otlp_endpoint", []string{"TRACING_OTLP_ENDPOINT"}, "tracing-otlp-endpoint", "OTLP/HTTP collector URL", setString(func(c *Config) *string { return &c.TracingOTLPEndpoint })},
+	{"tracing.sample_ratio", []string{"TRACING_SAMPLE_RATIO"}, "tracing-sample-ratio", "fraction of new traces to record, 0 to 1", setFloat(func(c *Config) *float64 { return &c.TracingSampleRatio })},
+	{"webauthn.rp_origin", []string{"WEBAUTHN_RP_ORIGIN"}, "webauthn-rp-origin", "origin passkeys are used from", setString(func(c *Config) *string { return &c.WebAuthnRPOrigin })},
+	// optional; a salt stored in the database is used when it's unset
+	{"analytics.visitor_salt", []string{"ANALYTICS_VISITOR_SALT"}, "", "", setString(func(c *Config) *string { return &c.AnalyticsVisitorSalt })},
+	{"oidc.providers", []string{"OIDC_PROVIDERS"}, "oidc-providers", "comma separated names of OIDC sign-in providers", setOIDCProviders},
+}
+
+// oidcSettings are the settings each OIDC provider has. Their flags are
+// repeated once per provider with a name=value argument, e.g.
+// -oidc-issuer google=https://accounts.google.com.
+var oidcSettings = []struct {
+	key   string
+	flag  string
+	usage string
+	field func(p *OIDCProvider) *string
+}{
+	{"issuer", "oidc-issuer", "provider=issuer URL", func(p *OIDCProvider) *string { return &p.Issuer }},
+	{"client_id", "oidc-client-id", "provider=client ID", func(p *OIDCProvider) *string { return &p.ClientID }},
+	{"client_secret", "", "", func(p *OIDCProvider) *string { return &p.ClientSecret }},
+	{"redirect_url", "oidc-redirect-url", "provider=callback URL", func(p *OIDCProvider) *string { return &p.RedirectURL }},
+}
+
+// providerFlag collects repeated name=value flags.
+type providerFlag map[string]string
+
+func (f providerFlag) String() string { return "" }
+
+func (f providerFlag) Set(v string) error {
+	name, value, ok := strings.Cut(v, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("%q is not provider=value", v)
+	}
+	f[strings.ToLower(name)] = value
+	return nil
+}
+
+// Load builds the configuration from args (without the program name), the
+// environment and the config file named by -config or CHIRPY_CONFIG. Every
+// problem found is reported, not just the first.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg, errs, err := load(args, getenv)
+	if err != nil {
+		return Config{}, err
+	}
+	errs = append(errs, cfg.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// Parse reads the configuration like Load but doesn't validate it, for
+// commands that only need some of the settings. Values that can't be
+// parsed are still errors.
+func Parse(args []string, getenv func(string) string) (Config, error) {
+	cfg, errs, err := load(args, getenv)
+	if err != nil {
+		return Config{}, err
+	}
+	if err := errors.Join(errs...); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// load returns the merged configuration and the problems with individual
+// values, or an error if it couldn't get that far.
+func load(args []string, getenv func(string) string) (Config, []error, error) {
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	configPath := fs.String("config", getenv("CHIRPY_CONFIG"), "path to a TOML config file")
+	flagValues := map[string]*string{}
+	for _, s := range settings {
+		if s.flag != "" {
+			flagValues[s.key] = fs.String(s.flag, "", s.usage)
+		}
+	}
+	oidcFlags := map[string]providerFlag{}
+	for _, s := range oidcSettings {
+		if s.flag != "" {
+			oidcFlags[s.key] = providerFlag{}
+			fs.Var(oidcFlags[s.key], s.flag, s.usage)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, nil, fmt.Errorf("config: unexpected arguments %q", fs.Args())
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var fileValues map[string]string
+	if *configPath != "" {
+		f, err := os.Open(*configPath)
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("config: %w", err)
+		}
+		fileValues, err = parseFile(f)
+		f.Close()
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("config: %s: %w", *configPath, err)
+		}
+	}
+
+	cfg := Default()
+	var errs []error
+
+	for _, s := range settings {
+		value, source, ok := lookup(s, fileValues, getenv, flagValues, explicit)
+		if !ok {
+			continue
+		}
+		if err := s.set(&cfg, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s (from %s): %w", s.key, source, err))
+		}
+	}
+
+	// provider settings are looked up once the provider names are known
+	known := map[string]bool{}
+	for _, s := range settings {
+		known[s.key] = true
+	}
+	listed := map[string]bool{}
+	for i := range cfg.OIDCProviders {
+		p := &cfg.OIDCProviders[i]
+		listed[p.Name] = true
+		for _, s := range oidcSettings {
+			key := "oidc." + p.Name + "." + s.key
+			known[key] = true
+			env := "OIDC_" + strings.ToUpper(p.Name) + "_" + strings.ToUpper(s.key)
+			if v, ok := oidcFlags[s.key][p.Name]; ok {
+				*s.field(p) = v
+			} else if v := getenv(env); v != "" {
+				*s.field(p) = v
+			} else if v, ok := fileValues[key]; ok {
+				*s.field(p) = v
+			}
+		}
+	}
+	for _, s := range oidcSettings {
+		var names []string
+		for name := range oidcFlags[s.key] {
+			if !listed[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			errs = append(errs, fmt.Errorf("-%s: %s is not in oidc.providers", s.flag, name))
+		}
+	}
+
+	var unknown []string
+	for key := range fileValues {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown setting %s", *configPath, key))
+	}
+
+	// the origin defaults to the local port, so it depends on another setting
+	if cfg.WebAuthnRPOrigin == "" {
+		cfg.WebAuthnRPOrigin = "http://localhost:" + strconv.Itoa(cfg.Port)
+	}
+
+	return cfg, errs, nil
+}
+
+// lookup returns the highest-precedence value for s and where it came from.
+func lookup(s setting, file map[string]string, getenv func(string) string, flags map[string]*string, explicit map[string]bool) (string, string, bool) {
+	if s.flag != "" && explicit[s.flag] {
+		return *flags[s.key], "-" + s.flag, true
+	}
+	for _, env := range s.env {
+		if v := getenv(env); v != "" {
+			return v, env, true
+		}
+	}
+	if v, ok := file[s.key]; ok {
+		return v, "config file", true
+	}
+	return "", "", false
+}
+
+// Validate checks the settings make sense together and reports every
+// problem at once.
+func (c Config) Validate() error {
+	var errs []error
+	required := func(value, key string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s must be set", key))
+		}
+	}
+	required(c.DBURL, "database.url (DB_URL)")
+	required(c.Platform, "platform (PLATFORM)")
+	required(c.JWTSecret, "auth.jwt_secret (JWT_TOKEN_SECRET)")
+	if c.AnalyticsVisitorSalt != "" && c.AnalyticsVisitorSalt == c.JWTSecret {
+		errs = append(errs, errors.New("analytics.visitor_salt must differ from auth.jwt_secret"))
+	}
+	if len(c.PolkaWebhookSecrets) == 0 {
+		errs = append(errs, errors.New("polka.webhook_secrets (POLKA_WEBHOOK_SECRETS) must be set"))
+	}
+
+	if c.Port < 1 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", c.Port))
+	}
+	for _, t := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_header_timeout", c.ReadHeaderTimeout},
+		{"server.read_timeout", c.ReadTimeout},
+		{"server.write_timeout", c.WriteTimeout},
+		{"server.idle_timeout", c.IdleTimeout},
+		{"server.shutdown_timeout", c.ShutdownTimeout},
+	} {
+		if t.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", t.key))
+		}
+	}
+	if c.ShutdownDelay < 0 {
+		errs = append(errs, errors.New("server.shutdown_delay must not be negative"))
+	}
+	if c.MaxHeaderBytes < 4096 {
+		errs = append(errs, errors.New("server.max_header_bytes must be at least 4096"))
+	}
+	if c.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.access_token_ttl must be positive"))
+	}
+	if c.RefreshTokenTTL <= c.AccessTokenTTL {
+		errs = append(errs, errors.New("auth.refresh_token_ttl must be longer than auth.access_token_ttl"))
+	}
+	if c.MaxChirpLength < 1 {
+		errs = append(errs, errors.New("chirps.max_length must be positive"))
+	}
+	if c.ChirpyRedMaxChirpLength < c.MaxChirpLength {
+		errs = append(errs, errors.New("chirps.chirpy_red_max_length must be at least chirps.max_length"))
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level %q is not debug, info, warn or error", c.LogLevel))
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		errs = append(errs, fmt.Errorf("log.format %q is not json or text", c.LogFormat))
+	}
+	switch c.TracingExporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter %q is not none, stdout or otlp", c.TracingExporter))
+	}
+	if c.TracingOTLPEndpoint != "" {
+		if u, err := url.Parse(c.TracingOTLPEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.otlp_endpoint %q is not an absolute URL", c.TracingOTLPEndpoint))
+		}
+	}
+	if !(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1) {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio %v is not between 0 and 1", c.TracingSampleRatio))
+	}
+	if u, err := url.Parse(c.WebAuthnRPOrigin); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("webauthn.rp_origin %q is not an absolute URL", c.WebAuthnRPOrigin))
+	}
+	for _, p := range c.OIDCProviders {
+		prefix, env := "oidc."+p.Name+".", "OIDC_"+strings.ToUpper(p.Name)+"_"
+		required(p.Issuer, prefix+"issuer ("+env+"ISSUER)")
+		required(p.ClientID, prefix+"client_id ("+env+"CLIENT_ID)")
+		required(p.RedirectURL, prefix+"redirect_url ("+env+"REDIRECT_URL)")
+		for _, u := range []struct{ key, value string }{{"issuer", p.Issuer}, {"redirect_url", p.RedirectURL}} {
+			if parsed, err := url.Parse(u.value); u.value != "" && (err != nil || parsed.Scheme == "" || parsed.Host == "") {
+				errs = append(errs, fmt.Errorf("%s%s %q is not an absolute URL", prefix, u.key, u.value))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func setString(field func(*Config) *string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}
+}
+
+func setInt(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", v)
+		}
+		*field(c) = n
+		return nil
+	}
+}
+
+func setFloat(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", v)
+		}
+		*field(c) = f
+		return nil
+	}
+}
+
+func setBool(field func(*Config) *bool) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", v)
+		}
+		*field(c) = b
+		return nil
+	}
+}
+
+func setDuration(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		d, err := parseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = d
+		return nil
+	}
+}
+
+// setList splits a comma separated value, ignoring empty items.
+func setList(field func(*Config) *[]string) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		*field(c) = items
+		return nil
+	}
+}
+
+// setPrefixes parses a comma separated list of IP addresses and CIDR
+// ranges. An address is a range holding just that address.
+func setPrefixes(field func(*Config) *[]netip.Prefix) func(*Config, string) error {
+	return func(c *Config, v string) error {
+		var prefixes []netip.Prefix
+		for _, item := range strings.Split(v, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if addr, err := netip.ParseAddr(item); err == nil {
+				prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+				continue
+			}
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return fmt.Errorf("%q is not an IP address or CIDR range", item)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+		}
+		*field(c) = prefixes
+		return nil
+	}
+}
+
+// setOIDCProviders starts a provider for each listed name. Names become
+// part of setting and environment variable names, so they are limited to
+// letters, digits and underscores.
+func setOIDCProviders(c *Config, v string) error {
+	c.OIDCProviders = nil
+	seen := map[string]bool{}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789_") != "" {
+			return fmt.Errorf("%q is not a valid provider name", name)
+		}
+		seen[name] = true
+		c.OIDCProviders = append(c.OIDCProviders, OIDCProvider{Name: name})
+	}
+	return nil
+}
+
+// parseDuration accepts anything time.ParseDuration does plus a plain
+// number of days such as "60d".
+func parseDuration(v string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a duration", v)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,267 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(k string) string { return values[k] }
+}
+
+var requiredEnv = map[string]string{
+	"DB_URL":                 "postgres://localhost/chirpy",
+	"PLATFORM":               "dev",
+	"JWT_TOKEN_SECRET":       "secret",
+	"ANALYTICS_VISITOR_SALT": "salt",
+	"POLKA_WEBHOOK_SECRETS":  "a, b",
+}
+
+func writeFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chirpy.toml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(nil, env(requiredEnv))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Port != 8080 || cfg.AccessTokenTTL != time.Hour || cfg.RefreshTokenTTL != 60*24*time.Hour || cfg.MaxChirpLength != 140 {
+		t.Errorf("unexpected defaults: %+v", cfg)
+	}
+	if got := strings.Join(cfg.PolkaWebhookSecrets, "|"); got != "a|b" {
+		t.Errorf("PolkaWebhookSecrets = %q, want a|b", got)
+	}
+	if cfg.ReadHeaderTimeout != 5*time.Second || cfg.WriteTimeout != 30*time.Second || cfg.MaxHeaderBytes != 1<<20 || cfg.ShutdownTimeout != 30*time.Second {
+		t.Errorf("unexpected server defaults: %+v", cfg)
+	}
+	if cfg.WebAuthnRPOrigin != "http://localhost:8080" {
+		t.Errorf("WebAuthnRPOrigin = %q", cfg.WebAuthnRPOrigin)
+	}
+	if cfg.TracingExporter != "none" || cfg.TracingSampleRatio != 1 {
+		t.Errorf("unexpected tracing defaults: %+v", cfg)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, `
+# file sets everything it can
+port = 9000
+platform = "prod"
+
+[auth]
+access_token_ttl = "30m"
+refresh_token_ttl = "7d"
+
+[database]
+auto_migrate = true
+
+[chirps]
+max_length = 200
+chirpy_red_max_length = 2000
+
+[polka]
+webhook_secrets = ["file-secret"]
+`)
+	e := map[string]string{}
+	for k, v := range requiredEnv {
+		e[k] = v
+	}
+	e["CHIRPY_CONFIG"] = path
+	e["PORT"] = "9100"
+	e["TRUSTED_PROXIES"] = "10.0.0.0/8, 192.0.2.1"
+	delete(e, "POLKA_WEBHOOK_SECRETS")
+	delete(e, "PLATFORM")
+
+	cfg, err := Load([]string{"-port", "9200"}, env(e))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Port != 9200 {
+		t.Errorf("Port = %d, want the flag's 9200", cfg.Port)
+	}
+	if !cfg.AutoMigrate {
+		t.Error("AutoMigrate = false, want the file's true")
+	}
+	if cfg.Platform != "prod" {
+		t.Errorf("Platform = %q, want the file's prod", cfg.Platform)
+	}
+	if cfg.AccessTokenTTL != 30*time.Minute || cfg.RefreshTokenTTL != 7*24*time.Hour {
+		t.Errorf("TTLs = %s, %s", cfg.AccessTokenTTL, cfg.RefreshTokenTTL)
+	}
+	if cfg.MaxChirpLength != 200 || cfg.ChirpyRedMaxChirpLength != 2000 {
+		t.Errorf("chirp lengths = %d, %d", cfg.MaxChirpLength, cfg.ChirpyRedMaxChirpLength)
+	}
+	if len(cfg.PolkaWebhookSecrets) != 1 || cfg.PolkaWebhookSecrets[0] != "file-secret" {
+		t.Errorf("PolkaWebhookSecrets = %q", cfg.PolkaWebhookSecrets)
+	}
+
+	cfg, err = Load(nil, env(e))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Port != 9100 {
+		t.Errorf("Port = %d, want the environment's 9100", cfg.Port)
+	}
+	if len(cfg.TrustedProxies) != 2 || cfg.TrustedProxies[0].String() != "10.0.0.0/8" || cfg.TrustedProxies[1].String() != "192.0.2.1/32" {
+		t.Errorf("TrustedProxies = %v", cfg.TrustedProxies)
+	}
+}
+
+func TestLoadPolkaKeyFallback(t *testing.T) {
+	e := map[string]string{}
+	for k, v := range requiredEnv {
+		e[k] = v
+	}
+	delete(e, "POLKA_WEBHOOK_SECRETS")
+	e["POLKA_KEY"] = "legacy"
+
+	cfg, err := Load(nil, env(e))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.PolkaWebhookSecrets) != 1 || cfg.PolkaWebhookSecrets[0] != "legacy" {
+		t.Errorf("PolkaWebhookSecrets = %q", cfg.PolkaWebhookSecrets)
+	}
+}
+
+func TestLoadAggregatesErrors(t *testing.T) {
+	path := writeFile(t, `
+port = 0
+colour = "red"
+
+[auth]
+access_token_ttl = "soon"
+`)
+	_, err := Load([]string{"-config", path, "-max-chirp-length", "0", "-write-timeout", "0s", "-tracing-exporter", "jaeger", "-tracing-sample-ratio", "1.5", "-trusted-proxies", "10.0.0.0/33"}, env(nil))
+	if err == nil {
+		t.Fatal("Load() error = nil")
+	}
+	for _, want := range []string{
+		"unknown setting colour",
+		"auth.access_token_ttl (from config file)",
+		"database.url (DB_URL) must be set",
+		"auth.jwt_secret (JWT_TOKEN_SECRET) must be set",
+		"port 0 is out of range",
+		"chirps.max_length must be positive",
+		"server.write_timeout must be positive",
+		`tracing.exporter "jaeger" is not none, stdout or otlp`,
+		"tracing.sample_ratio 1.5 is not between 0 and 1",
+		`server.trusted_proxies (from -trusted-proxies): "10.0.0.0/33" is not an IP address or CIDR range`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%s", want, err)
+		}
+	}
+}
+
+func TestLoadOIDCProviders(t *testing.T) {
+	path := writeFile(t, `
+[oidc]
+providers = ["google", "GitLab"]
+
+[oidc.google]
+issuer = "https://accounts.google.com"
+client_id = "from-file"
+redirect_url = "https://chirpy.example.com/api/auth/google/callback"
+
+[oidc.gitlab]
+issuer = "https://gitlab.com"
+`)
+	vars := map[string]string{"OIDC_GOOGLE_CLIENT_SECRET": "shh", "OIDC_GOOGLE_CLIENT_ID": "from-env"}
+	for k, v := range requiredEnv {
+		vars[k] = v
+	}
+	args := []string{"-config", path,
+		"-oidc-client-id", "google=from-flag",
+		"-oidc-client-id", "gitlab=gl",
+		"-oidc-redirect-url", "gitlab=https://chirpy.example.com/api/auth/gitlab/callback",
+	}
+	cfg, err := Load(args, env(vars))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []OIDCProvider{
+		{Name: "google", Issuer: "https://accounts.google.com", ClientID: "from-flag", ClientSecret: "shh", RedirectURL: "https://chirpy.example.com/api/auth/google/callback"},
+		{Name: "gitlab", Issuer: "https://gitlab.com", ClientID: "gl", RedirectURL: "https://chirpy.example.com/api/auth/gitlab/callback"},
+	}
+	if len(cfg.OIDCProviders) != len(want) {
+		t.Fatalf("OIDCProviders = %+v", cfg.OIDCProviders)
+	}
+	for i := range want {
+		if cfg.OIDCProviders[i] != want[i] {
+			t.Errorf("OIDCProviders[%d] = %+v, want %+v", i, cfg.OIDCProviders[i], want[i])
+		}
+	}
+
+	_, err = Load([]string{"-config", path, "-oidc-issuer", "github=https://github.com", "-oidc-issuer", "gitlab=gitlab.com"}, env(requiredEnv))
+	if err == nil {
+		t.Fatal("Load() error = nil")
+	}
+	for _, want := range []string{
+		"-oidc-issuer: github is not in oidc.providers",
+		"oidc.gitlab.client_id (OIDC_GITLAB_CLIENT_ID) must be set",
+		`oidc.gitlab.issuer "gitlab.com" is not an absolute URL`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error missing %q:\n%s", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "unknown setting") {
+		t.Errorf("provider settings reported as unknown:\n%s", err)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	values, err := parseFile(strings.NewReader(`
+top = "a # not a comment" # a comment
+[section]
+list = ["x", "y,z", ]
+flag = true
+n = 42
+ratio = 0.25
+`))
+	if err != nil {
+		t.Fatalf("parseFile() error = %v", err)
+	}
+	want := map[string]string{
+		"top":           "a # not a comment",
+		"section.list":  "x,y,z",
+		"section.flag":  "true",
+		"section.n":     "42",
+		"section.ratio": "0.25",
+	}
+	for k, v := range want {
+		if values[k] != v {
+			t.Errorf("%s = %q, want %q", k, values[k], v)
+		}
+	}
+
+	for _, bad := range []string{"[section", "novalue", "k = bare", `k = ["a", 1]`, "k = 1\nk = 2"} {
+		if _, err := parseFile(strings.NewReader(bad)); err == nil {
+			t.Errorf("parseFile(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestParseSkipsValidation(t *testing.T) {
+	cfg, err := Parse(nil, env(map[string]string{"DB_URL": "postgres://localhost/chirpy"}))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if cfg.DBURL != "postgres://localhost/chirpy" {
+		t.Errorf("DBURL = %q", cfg.DBURL)
+	}
+
+	if _, err := Parse(nil, env(map[string]string{"PORT": "eighty"})); err == nil {
+		t.Error("Parse() with an unparseable port error = nil")
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// parseFile reads the subset of TOML Chirpy's config file uses: [section]
+// headers, key = value pairs, # comments, and values that are strings,
+// numbers, booleans or arrays of strings. It returns the values keyed by
+// "section.key" in their string form, with arrays joined by commas.
+func parseFile(r io.Reader) (map[string]string, error) {
+	values := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section header", lineNo)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == "" {
+				return nil, fmt.Errorf("line %d: empty section name", lineNo)
+			}
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key := strings.TrimSpace(k)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", lineNo)
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is set twice", lineNo, key)
+		}
+
+		value, err := parseValue(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineNo, key, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseValue(v string) (string, error) {
+	switch {
+	case v == "":
+		return "", fmt.Errorf("missing value")
+	case strings.HasPrefix(v, `"`):
+		return strconv.Unquote(v)
+	case strings.HasPrefix(v, "["):
+		if !strings.HasSuffix(v, "]") {
+			return "", fmt.Errorf("unterminated array")
+		}
+		inner := strings.TrimSpace(v[1 : len(v)-1])
+		if inner == "" {
+			return "", nil
+		}
+		var items []string
+		for _, item := range splitArray(inner) {
+			s, err := strconv.Unquote(strings.TrimSpace(item))
+			if err != nil {
+				return "", fmt.Errorf("arrays may only contain strings")
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case v == "true" || v == "false":
+		return v, nil
+	}
+	if _, err := strconv.ParseFloat(v, 64); err != nil {
+		return "", fmt.Errorf("unsupported value %s", v)
+	}
+	return v, nil
+}
+
+// splitArray splits on commas outside quoted strings, allowing a trailing
+// comma.
+func splitArray(s string) []string {
+	var items []string
+	inString, escaped := false, false
+	start := 0
+	for i, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inString:
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case c == ',' && !inString:
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// stripComment removes a # comment that isn't inside a string.
+func stripComment(line string) string {
+	inString, escaped := false, false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inString:
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case c == '#' && !inString:
+			return line[:i]
+		}
+	}
+	return line
+}
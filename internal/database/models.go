@@ -11,12 +11,91 @@ import (
 	"github.com/google/uuid"
 )
 
+type AdminAuditLog struct {
+	ID         uuid.UUID     `json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	ActorID    uuid.NullUUID `json:"actor_id"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	StatusCode int32         `json:"status_code"`
+}
+
+type AnalyticsVisitorSalt struct {
+	ID        bool      `json:"id"`
+	Salt      []byte    `json:"salt"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ApiKey struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	HashedKey  string       `json:"hashed_key"`
+	Scope      string       `json:"scope"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
 type Chirp struct {
 	ID        uuid.UUID     `json:"id"`
 	CreatedAt time.Time     `json:"created_at"`
 	UpdatedAt time.Time     `json:"updated_at"`
 	Body      string        `json:"body"`
 	UserID    uuid.NullUUID `json:"user_id"`
+	PublishAt time.Time     `json:"publish_at"`
+	Published bool          `json:"published"`
+}
+
+type OauthAuthorizationCode struct {
+	Code          string    `json:"code"`
+	CreatedAt     time.Time `json:"created_at"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	ClientID      string    `json:"client_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	RedirectUri   string    `json:"redirect_uri"`
+	Scope         string    `json:"scope"`
+	CodeChallenge string    `json:"code_challenge"`
+}
+
+type OauthClient struct {
+	ID           string         `json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	Name         string         `json:"name"`
+	HashedSecret sql.NullString `json:"hashed_secret"`
+	RedirectUri  string         `json:"redirect_uri"`
+	Scope        string         `json:"scope"`
+	UserID       uuid.UUID      `json:"user_id"`
+}
+
+type OauthConsent struct {
+	UserID    uuid.UUID    `json:"user_id"`
+	ClientID  string       `json:"client_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Scope     string       `json:"scope"`
+	GrantedAt time.Time    `json:"granted_at"`
+	RevokedAt sql.NullTime `json:"revoked_at"`
+}
+
+type OidcLoginState struct {
+	State        string    `json:"state"`
+	CreatedAt    time.Time `json:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+}
+
+type PageView struct {
+	Path     string    `json:"path"`
+	Day      time.Time `json:"day"`
+	Views    int64     `json:"views"`
+	Visitors []byte    `json:"visitors"`
 }
 
 type RefreshToken struct {
@@ -28,11 +107,96 @@ type RefreshToken struct {
 	UserID    uuid.NullUUID `json:"user_id"`
 }
 
+type Subscription struct {
+	UserID           uuid.UUID    `json:"user_id"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	Status           string       `json:"status"`
+	CurrentPeriodEnd sql.NullTime `json:"current_period_end"`
+	LastEventAt      sql.NullTime `json:"last_event_at"`
+}
+
 type User struct {
+	ID                uuid.UUID      `json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	Email             string         `json:"email"`
+	HashedPassword    sql.NullString `json:"hashed_password"`
+	IsChirpyRed       bool           `json:"is_chirpy_red"`
+	DeletionDueAt     sql.NullTime   `json:"deletion_due_at"`
+	Role              string         `json:"role"`
+	SuspendedAt       sql.NullTime   `json:"suspended_at"`
+	SessionsRevokedAt sql.NullTime   `json:"sessions_revoked_at"`
+}
+
+type UserIdentity struct {
+	ID        uuid.UUID      `json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	UserID    uuid.UUID      `json:"user_id"`
+	Provider  string         `json:"provider"`
+	Subject   string         `json:"subject"`
+	Email     sql.NullString `json:"email"`
+}
+
+type WebauthnChallenge struct {
+	Challenge string        `json:"challenge"`
+	CreatedAt time.Time     `json:"created_at"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	Ceremony  string        `json:"ceremony"`
+	UserID    uuid.NullUUID `json:"user_id"`
+}
+
+type WebauthnCredential struct {
+	ID           uuid.UUID    `json:"id"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	UserID       uuid.UUID    `json:"user_id"`
+	CredentialID []byte       `json:"credential_id"`
+	PublicKey    []byte       `json:"public_key"`
+	SignCount    int64        `json:"sign_count"`
+	Aaguid       []byte       `json:"aaguid"`
+	LastUsedAt   sql.NullTime `json:"last_used_at"`
+}
+
+type WebhookDelivery struct {
 	ID             uuid.UUID      `json:"id"`
 	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	Email          string         `json:"email"`
-	HashedPassword sql.NullString `json:"hashed_password"`
-	IsChirpyRed    bool           `json:"is_chirpy_red"`
+	EndpointID     uuid.UUID      `json:"endpoint_id"`
+	Event          string         `json:"event"`
+	Payload        []byte         `json:"payload"`
+	Status         string         `json:"status"`
+	Attempts       int32          `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	LastStatusCode int32          `json:"last_status_code"`
+	LastError      sql.NullString `json:"last_error"`
+	LastResponse   sql.NullString `json:"last_response"`
+	DeliveredAt    sql.NullTime   `json:"delivered_at"`
+}
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID     `json:"id"`
+	CreatedAt           time.Time     `json:"created_at"`
+	UpdatedAt           time.Time     `json:"updated_at"`
+	UserID              uuid.NullUUID `json:"user_id"`
+	Url                 string        `json:"url"`
+	Secret              string        `json:"secret"`
+	Events              string        `json:"events"`
+	ConsecutiveFailures int32         `json:"consecutive_failures"`
+	DisabledAt          sql.NullTime  `json:"disabled_at"`
+}
+
+type WebhookEvent struct {
+	Source        string         `json:"source"`
+	ID            string         `json:"id"`
+	ReceivedAt    time.Time      `json:"received_at"`
+	Event         string         `json:"event"`
+	Deliveries    int32          `json:"deliveries"`
+	ProcessedAt   sql.NullTime   `json:"processed_at"`
+	Payload       []byte         `json:"payload"`
+	Status        string         `json:"status"`
+	Attempts      int32          `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastError     sql.NullString `json:"last_error"`
+	SentAt        sql.NullTime   `json:"sent_at"`
 }
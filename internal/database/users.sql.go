@@ -13,6 +13,17 @@ import (
 	"github.com/google/uuid"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_due_at = NULL, updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -60,8 +71,43 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 	return err
 }
 
+const deleteUsersPastGracePeriod = `-- name: DeleteUsersPastGracePeriod :execrows
+DELETE FROM users
+WHERE deletion_due_at <= NOW()
+`
+
+func (q *Queries) DeleteUsersPastGracePeriod(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUsersPastGracePeriod)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getUserAuthState = `-- name: GetUserAuthState :one
+SELECT suspended_at, sessions_revoked_at, deletion_due_at FROM users
+WHERE id = $1
+`
+
+type GetUserAuthStateRow struct {
+	SuspendedAt       sql.NullTime `json:"suspended_at"`
+	SessionsRevokedAt sql.NullTime `json:"sessions_revoked_at"`
+	DeletionDueAt     sql.NullTime `json:"deletion_due_at"`
+}
+
+func (q *Queries) GetUserAuthState(ctx context.Context, id uuid.UUID) (GetUserAuthStateRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserAuthState, id)
+	var i GetUserAuthStateRow
+	err := row.Scan(
+		&i.SuspendedAt,
+		&i.SessionsRevokedAt,
+		&i.DeletionDueAt,
+	)
+	return i, err
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_due_at, role, suspended_at, sessions_revoked_at FROM users
 WHERE email = $1
 `
 
@@ -75,27 +121,206 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletionDueAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SessionsRevokedAt,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_due_at, role, suspended_at, sessions_revoked_at FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionDueAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SessionsRevokedAt,
 	)
 	return i, err
 }
 
-const updateIsChirpyRed = `-- name: UpdateIsChirpyRed :one
+const revokeUserSessions = `-- name: RevokeUserSessions :one
 UPDATE users
-SET is_chirpy_red = $2
+SET sessions_revoked_at = NOW(), updated_at = NOW()
 WHERE id = $1
-RETURNING id
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_due_at, role, suspended_at, sessions_revoked_at
 `
 
-type UpdateIsChirpyRedParams struct {
-	ID          uuid.UUID `json:"id"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+func (q *Queries) RevokeUserSessions(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, revokeUserSessions, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionDueAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SessionsRevokedAt,
+	)
+	return i, err
 }
 
-func (q *Queries) UpdateIsChirpyRed(ctx context.Context, arg UpdateIsChirpyRedParams) (uuid.UUID, error) {
-	row := q.db.QueryRowContext(ctx, updateIsChirpyRed, arg.ID, arg.IsChirpyRed)
-	var id uuid.UUID
-	err := row.Scan(&id)
-	return id, err
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :one
+UPDATE users
+SET deletion_due_at = $2, sessions_revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING deletion_due_at
+`
+
+type ScheduleUserDeletionParams struct {
+	ID            uuid.UUID    `json:"id"`
+	DeletionDueAt sql.NullTime `json:"deletion_due_at"`
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, scheduleUserDeletion, arg.ID, arg.DeletionDueAt)
+	var deletion_due_at sql.NullTime
+	err := row.Scan(&deletion_due_at)
+	return deletion_due_at, err
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_due_at, role, suspended_at, sessions_revoked_at FROM users
+WHERE position(lower($1::text) IN lower(email)) > 0
+ORDER BY created_at ASC
+LIMIT $2 OFFSET $3
+`
+
+type SearchUsersParams struct {
+	Query  string `json:"query"`
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, searchUsers, arg.Query, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Email,
+			&i.HashedPassword,
+			&i.IsChirpyRed,
+			&i.DeletionDueAt,
+			&i.Role,
+			&i.SuspendedAt,
+			&i.SessionsRevokedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_due_at, role, suspended_at, sessions_revoked_at
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID `json:"id"`
+	Role string    `json:"role"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionDueAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SessionsRevokedAt,
+	)
+	return i, err
+}
+
+const suspendUser = `-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = NOW(), sessions_revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_due_at, role, suspended_at, sessions_revoked_at
+`
+
+func (q *Queries) SuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, suspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionDueAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SessionsRevokedAt,
+	)
+	return i, err
+}
+
+const unsuspendUser = `-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_due_at, role, suspended_at, sessions_revoked_at
+`
+
+func (q *Queries) UnsuspendUser(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, unsuspendUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionDueAt,
+		&i.Role,
+		&i.SuspendedAt,
+		&i.SessionsRevokedAt,
+	)
+	return i, err
 }
 
 const updateUser = `-- name: UpdateUser :one
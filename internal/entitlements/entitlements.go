@@ -0,0 +1,61 @@
+// Package entitlements defines what each plan allows a user to do. Handlers
+// ask for a user's Entitlements instead of checking is_chirpy_red directly,
+// so plan changes only need to be made here.
+package entitlements
+
+// Plan is a tier of service.
+type Plan string
+
+const (
+	PlanFree      Plan = "free"
+	PlanChirpyRed Plan = "chirpy_red"
+)
+
+// Entitlements lists the capabilities granted by a plan.
+type Entitlements struct {
+	Plan           Plan `json:"plan"`
+	MaxChirpLength int  `json:"max_chirp_length"`
+	EditChirps     bool `json:"edit_chirps"`
+	ScheduleChirps bool `json:"schedule_chirps"`
+}
+
+// Catalog maps each plan to what it grants. Chirp length limits are
+// configurable, so the catalog is built at startup rather than fixed.
+type Catalog map[Plan]Entitlements
+
+// NewCatalog returns the standard plans with the given chirp length limits.
+func NewCatalog(freeMaxChirpLength, chirpyRedMaxChirpLength int) Catalog {
+	return Catalog{
+		PlanFree: {
+			Plan:           PlanFree,
+			MaxChirpLength: freeMaxChirpLength,
+		},
+		PlanChirpyRed: {
+			Plan:           PlanChirpyRed,
+			MaxChirpLength: chirpyRedMaxChirpLength,
+			EditChirps:     true,
+			ScheduleChirps: true,
+		},
+	}
+}
+
+// DefaultCatalog returns the standard plans with the default limits.
+func DefaultCatalog() Catalog {
+	return NewCatalog(140, 1000)
+}
+
+// For returns the entitlements of plan. Unknown plans get the free tier.
+func (c Catalog) For(plan Plan) Entitlements {
+	if e, ok := c[plan]; ok {
+		return e
+	}
+	return c[PlanFree]
+}
+
+// ForUser returns the entitlements of a user given their Chirpy Red status.
+func (c Catalog) ForUser(isChirpyRed bool) Entitlements {
+	if isChirpyRed {
+		return c.For(PlanChirpyRed)
+	}
+	return c.For(PlanFree)
+}
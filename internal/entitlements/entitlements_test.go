@@ -0,0 +1,33 @@
+package entitlements
+
+import "testing"
+
+func TestForUser(t *testing.T) {
+	catalog := DefaultCatalog()
+	free := catalog.ForUser(false)
+	if free.Plan != PlanFree || free.MaxChirpLength != 140 || free.EditChirps || free.ScheduleChirps {
+		t.Errorf("ForUser(false) = %+v", free)
+	}
+
+	red := catalog.ForUser(true)
+	if red.Plan != PlanChirpyRed || red.MaxChirpLength <= free.MaxChirpLength || !red.EditChirps || !red.ScheduleChirps {
+		t.Errorf("ForUser(true) = %+v", red)
+	}
+}
+
+func TestForUnknownPlan(t *testing.T) {
+	catalog := DefaultCatalog()
+	if got := catalog.For("platinum"); got != catalog.For(PlanFree) {
+		t.Errorf("For(unknown) = %+v, want free plan", got)
+	}
+}
+
+func TestNewCatalogLimits(t *testing.T) {
+	catalog := NewCatalog(200, 2000)
+	if got := catalog.ForUser(false).MaxChirpLength; got != 200 {
+		t.Errorf("free MaxChirpLength = %d, want 200", got)
+	}
+	if got := catalog.ForUser(true).MaxChirpLength; got != 2000 {
+		t.Errorf("Chirpy Red MaxChirpLength = %d, want 2000", got)
+	}
+}
@@ -0,0 +1,235 @@
+// Package health runs the dependency checks behind the readiness endpoint.
+// Liveness only says the process is up; readiness says it can usefully
+// serve traffic, which needs the database, the expected schema and working
+// background jobs. Readiness is public and only reports which checks pass;
+// the full report with errors is for operators.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusShuttingDown = "shutting_down"
+)
+
+// CheckFunc probes one dependency. The detail it returns, if any, is
+// included in the report whether or not the check passed.
+type CheckFunc func(ctx context.Context) (detail any, err error)
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	Detail     any    `json:"detail,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of every check.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Summary is the part of a report that is safe to show anyone: the status
+// of each check, without errors or details that describe the
+// infrastructure behind it.
+type Summary struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func (r Report) Summary() Summary {
+	s := Summary{Status: r.Status, Checks: make(map[string]string, len(r.Checks))}
+	for name, result := range r.Checks {
+		s.Checks[name] = result.Status
+	}
+	return s
+}
+
+// Registry holds the checks run for readiness.
+type Registry struct {
+	// each check gets this long before it counts as failed
+	Timeout time.Duration
+
+	mu           sync.Mutex
+	checks       map[string]CheckFunc
+	shuttingDown atomic.Bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		Timeout: 2 * time.Second,
+		checks:  map[string]CheckFunc{},
+	}
+}
+
+// Register adds a check, replacing any existing check with the same name.
+func (r *Registry) Register(name string, check CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks[name] = check
+}
+
+// SetShuttingDown makes readiness fail from now on so load balancers stop
+// sending new requests while in-flight ones drain.
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Run runs every check concurrently.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.checks))
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	checks := make([]CheckFunc, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		checks[i] = r.checks[name]
+	}
+	r.mu.Unlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Go(func() {
+			results[i] = r.run(ctx, check)
+		})
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(names))}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	if r.shuttingDown.Load() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, check CheckFunc) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	detail, err := check(ctx)
+	result := Result{
+		Status:     StatusOK,
+		Detail:     detail,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ReadyHandler serves the report's summary, with a 503 unless everything
+// passed. It is meant to be public, so failing checks are logged rather
+// than described in the response.
+func (r *Registry) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+		for name, result := range report.Checks {
+			if result.Status != StatusOK {
+				slog.WarnContext(req.Context(), "Readiness check failed", "check", name, "error", result.Error)
+			}
+		}
+		serveReport(w, report.Status, report.Summary())
+	})
+}
+
+// ReportHandler serves the full report, errors and details included, with
+// a 503 unless everything passed. Serve it only to operators.
+func (r *Registry) ReportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+		serveReport(w, report.Status, report)
+	})
+}
+
+func serveReport(w http.ResponseWriter, reportStatus string, v any) {
+	status := http.StatusOK
+	if reportStatus != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// Heartbeat tracks a periodic background job. The check fails when the job
+// hasn't finished a run for longer than MaxAge, which catches workers that
+// have hung or exited.
+type Heartbeat struct {
+	MaxAge time.Duration
+
+	mu      sync.Mutex
+	started time.Time
+	last    time.Time
+	lastErr error
+}
+
+func NewHeartbeat(maxAge time.Duration) *Heartbeat {
+	return &Heartbeat{MaxAge: maxAge, started: time.Now()}
+}
+
+// Beat records a finished run and its error, if any.
+func (h *Heartbeat) Beat(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last = time.Now()
+	h.lastErr = err
+}
+
+// Check is a CheckFunc. A job that fails each run still counts as alive;
+// the dependency it needs has a check of its own.
+func (h *Heartbeat) Check(ctx context.Context) (any, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	type heartbeatDetail struct {
+		LastRun   *time.Time `json:"last_run"`
+		LastError string     `json:"last_error,omitempty"`
+	}
+	detail := heartbeatDetail{}
+	if h.lastErr != nil {
+		detail.LastError = h.lastErr.Error()
+	}
+
+	if h.last.IsZero() {
+		if time.Since(h.started) > h.MaxAge {
+			return detail, errors.New("no run has finished yet")
+		}
+		return detail, nil
+	}
+	last := h.last
+	detail.LastRun = &last
+	if time.Since(h.last) > h.MaxAge {
+		return detail, errors.New("last run is overdue")
+	}
+	return detail, nil
+}
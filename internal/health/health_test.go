@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunReportsEachCheck(t *testing.T) {
+	r := NewRegistry()
+	r.Register("good", func(ctx context.Context) (any, error) { return 42, nil })
+	r.Register("bad", func(ctx context.Context) (any, error) { return nil, errors.New("down") })
+
+	report := r.Run(context.Background())
+	if report.Status != StatusFailing {
+		t.Errorf("Status = %q, want %q", report.Status, StatusFailing)
+	}
+	if got := report.Checks["good"]; got.Status != StatusOK || got.Detail != 42 {
+		t.Errorf("good = %+v", got)
+	}
+	if got := report.Checks["bad"]; got.Status != StatusFailing || got.Error != "down" {
+		t.Errorf("bad = %+v", got)
+	}
+}
+
+func TestRunTimesOutSlowChecks(t *testing.T) {
+	r := NewRegistry()
+	r.Timeout = 10 * time.Millisecond
+	r.Register("slow", func(ctx context.Context) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	if got := r.Run(context.Background()).Checks["slow"]; got.Status != StatusFailing {
+		t.Errorf("slow = %+v, want failing", got)
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	r := NewRegistry()
+	r.Register("db", func(ctx context.Context) (any, error) { return nil, nil })
+
+	rec := httptest.NewRecorder()
+	r.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+
+	// failures are reported without the error, which can name internal hosts
+	r.Register("cache", func(ctx context.Context) (any, error) {
+		return "10.0.0.5:6379", errors.New("dial tcp 10.0.0.5:6379: connection refused")
+	})
+	rec = httptest.NewRecorder()
+	r.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable || strings.Contains(rec.Body.String(), "10.0.0.5") {
+		t.Errorf("failing readiness = %d %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	r.ReportHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/admin/health", nil))
+	var full Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &full); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || full.Checks["cache"].Error == "" {
+		t.Errorf("full report = %d %s", rec.Code, rec.Body)
+	}
+	r.Register("cache", func(ctx context.Context) (any, error) { return nil, nil })
+
+	r.SetShuttingDown()
+	rec = httptest.NewRecorder()
+	r.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/api/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 while shutting down", rec.Code)
+	}
+	var summary Summary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Status != StatusShuttingDown || summary.Checks["db"] != StatusOK {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	h := NewHeartbeat(time.Hour)
+	if _, err := h.Check(context.Background()); err != nil {
+		t.Errorf("new heartbeat failed: %v", err)
+	}
+
+	h.Beat(errors.New("db down"))
+	detail, err := h.Check(context.Background())
+	if err != nil {
+		t.Errorf("Check() after a failed run = %v, want nil", err)
+	}
+	if data, _ := json.Marshal(detail); !json.Valid(data) || string(data) == "{}" {
+		t.Errorf("detail = %s", data)
+	}
+
+	h.mu.Lock()
+	h.last = time.Now().Add(-2 * time.Hour)
+	h.mu.Unlock()
+	if _, err := h.Check(context.Background()); err == nil {
+		t.Error("overdue heartbeat passed")
+	}
+
+	never := NewHeartbeat(time.Minute)
+	never.started = time.Now().Add(-time.Hour)
+	if _, err := never.Check(context.Background()); err == nil {
+		t.Error("heartbeat that never ran passed")
+	}
+}
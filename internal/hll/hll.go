@@ -0,0 +1,86 @@
+// Package hll implements HyperLogLog, which estimates how many distinct
+// items have been seen using a few kilobytes regardless of how many there
+// are. Callers add 64-bit hashes, so the items themselves are never stored.
+package hll
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+const (
+	precision = 12
+	registers = 1 << precision
+
+	// first byte of the binary encoding, so the format can change later
+	encodingVersion = 1
+)
+
+// Sketch estimates the number of distinct hashes added to it. The standard
+// error is about 1.6%.
+type Sketch struct {
+	registers [registers]uint8
+}
+
+func New() *Sketch {
+	return &Sketch{}
+}
+
+// Add records a hash. Hashes must be uniformly distributed, such as the
+// output of a cryptographic hash.
+func (s *Sketch) Add(hash uint64) {
+	idx := hash >> (64 - precision)
+	// position of the first set bit in the remaining bits, capped when
+	// they are all zero
+	rank := uint8(bits.LeadingZeros64(hash<<precision|1<<(precision-1))) + 1
+	if rank > s.registers[idx] {
+		s.registers[idx] = rank
+	}
+}
+
+// Merge folds other into s, so s estimates the union of both.
+func (s *Sketch) Merge(other *Sketch) {
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+}
+
+// Count returns the estimated number of distinct hashes added.
+func (s *Sketch) Count() uint64 {
+	const m = float64(registers)
+	alpha := 0.7213 / (1 + 1.079/m)
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := alpha * m * m / sum
+
+	// linear counting is more accurate while many registers are empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+func (s *Sketch) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 1+registers)
+	b[0] = encodingVersion
+	copy(b[1:], s.registers[:])
+	return b, nil
+}
+
+func (s *Sketch) UnmarshalBinary(b []byte) error {
+	if len(b) != 1+registers || b[0] != encodingVersion {
+		return errors.New("hll: invalid sketch encoding")
+	}
+	copy(s.registers[:], b[1:])
+	return nil
+}
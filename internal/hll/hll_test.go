@@ -0,0 +1,74 @@
+package hll
+
+import (
+	"math"
+	"testing"
+)
+
+// splitmix64 turns sequential integers into well-distributed test hashes.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+func assertClose(t *testing.T, got, want uint64, tolerance float64) {
+	t.Helper()
+	if diff := math.Abs(float64(got)-float64(want)) / float64(want); diff > tolerance {
+		t.Errorf("Count() = %d, want %d ± %.0f%%", got, want, tolerance*100)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	if got := New().Count(); got != 0 {
+		t.Errorf("Count() = %d, want 0", got)
+	}
+}
+
+func TestCount(t *testing.T) {
+	for _, n := range []uint64{10, 1000, 100000} {
+		s := New()
+		for i := uint64(0); i < n; i++ {
+			s.Add(splitmix64(i))
+			// duplicates must not change the estimate
+			s.Add(splitmix64(i))
+		}
+		assertClose(t, s.Count(), n, 0.05)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a, b := New(), New()
+	for i := uint64(0); i < 6000; i++ {
+		a.Add(splitmix64(i))
+	}
+	for i := uint64(4000); i < 10000; i++ {
+		b.Add(splitmix64(i))
+	}
+	a.Merge(b)
+	assertClose(t, a.Count(), 10000, 0.05)
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	s := New()
+	for i := uint64(0); i < 500; i++ {
+		s.Add(splitmix64(i))
+	}
+	b, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := New()
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if got.Count() != s.Count() {
+		t.Errorf("Count() after round trip = %d, want %d", got.Count(), s.Count())
+	}
+
+	if err := got.UnmarshalBinary(b[:10]); err == nil {
+		t.Error("UnmarshalBinary(truncated) error = nil")
+	}
+}
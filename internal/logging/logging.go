@@ -0,0 +1,143 @@
+// Package logging sets up Chirpy's structured logs. Records logged with a
+// request's context carry its request ID and, once authenticated, the user
+// ID, and attributes that look like credentials are redacted.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of any attribute whose key looks sensitive.
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are matched against attribute keys after lowercasing, so
+// "refresh_token" and "Authorization" are both caught.
+var sensitiveKeys = []string{
+	"password",
+	"secret",
+	"token",
+	"authorization",
+	"api_key",
+	"apikey",
+	"cookie",
+}
+
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLevel accepts debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", s)
+	}
+	return level, nil
+}
+
+// New returns a logger writing to w in format "json" or "text".
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if isSensitive(a.Key) {
+				return slog.String(a.Key, Redacted)
+			}
+			return a
+		},
+	}
+	var h slog.Handler
+	if format == "text" {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// request is the mutable per-request state behind the context. Handlers
+// learn the user ID partway through, after the context has been created.
+type request struct {
+	id string
+
+	mu     sync.Mutex
+	userID string
+}
+
+type requestKey struct{}
+
+// WithRequestID starts tracking a request on ctx.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{id: id})
+}
+
+// RequestID returns the ID of the request on ctx, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// SetUserID records who the request on ctx is authenticated as. Records
+// logged with ctx from then on, including the access log, carry it.
+func SetUserID(ctx context.Context, userID string) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.mu.Lock()
+		req.userID = userID
+		req.mu.Unlock()
+	}
+}
+
+// UserID returns the user set with SetUserID, or "".
+func UserID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.mu.Lock()
+		defer req.mu.Unlock()
+		return req.userID
+	}
+	return ""
+}
+
+// contextHandler adds the request attributes found on the record's context,
+// and the active trace so logs and spans can be matched up.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if userID := UserID(ctx); userID != "" {
+		r.AddAttrs(slog.String("user_id", userID))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	record := map[string]any{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("log line %q is not JSON: %v", buf.String(), err)
+	}
+	return record
+}
+
+func TestRedaction(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := New(buf, slog.LevelInfo, "json")
+	logger.Info("login", "email", "a@example.com", "password", "hunter2", "refresh_token", "abc", "Authorization", "Bearer x")
+
+	record := decode(t, buf)
+	if record["email"] != "a@example.com" {
+		t.Errorf("email = %v", record["email"])
+	}
+	for _, key := range []string{"password", "refresh_token", "Authorization"} {
+		if record[key] != Redacted {
+			t.Errorf("%s = %v, want %s", key, record[key], Redacted)
+		}
+	}
+}
+
+func TestRequestAttributes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := New(buf, slog.LevelInfo, "json")
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	logger.InfoContext(ctx, "before auth")
+	record := decode(t, buf)
+	if record["request_id"] != "req-1" {
+		t.Errorf("request_id = %v", record["request_id"])
+	}
+	if _, ok := record["user_id"]; ok {
+		t.Errorf("user_id set before authentication: %v", record["user_id"])
+	}
+
+	SetUserID(ctx, "user-1")
+	buf.Reset()
+	logger.With("component", "test").InfoContext(ctx, "after auth")
+	record = decode(t, buf)
+	if record["user_id"] != "user-1" || record["component"] != "test" {
+		t.Errorf("record = %v", record)
+	}
+}
+
+func TestTraceAttributes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := New(buf, slog.LevelInfo, "json")
+
+	logger.InfoContext(context.Background(), "untraced")
+	if _, ok := decode(t, buf)["trace_id"]; ok {
+		t.Error("trace_id set without a span")
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	buf.Reset()
+	logger.InfoContext(trace.ContextWithSpanContext(context.Background(), sc), "traced")
+	record := decode(t, buf)
+	if record["trace_id"] != sc.TraceID().String() || record["span_id"] != sc.SpanID().String() {
+		t.Errorf("record = %v", record)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	level, err := ParseLevel("warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := New(buf, level, "json")
+	logger.Info("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("info logged at warn level: %s", buf)
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel(loud) error = nil")
+	}
+}
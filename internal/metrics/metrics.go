@@ -0,0 +1,337 @@
+// Package metrics implements the small part of the Prometheus data model
+// Chirpy needs (counters, gauges and histograms with labels) and renders it
+// in the text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are latency buckets in seconds suitable for HTTP handlers and
+// database queries.
+var DefBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+// Registry holds metrics and serves them to Prometheus.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Write renders every registered metric in registration order.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// vec tracks one value per combination of label values.
+type vec[T any] struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*T
+	keys   map[string][]string
+	newT   func() *T
+}
+
+func (v *vec[T]) get(labelValues []string) *T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	t, ok := v.values[key]
+	if !ok {
+		t = v.newT()
+		v.values[key] = t
+		v.keys[key] = append([]string(nil), labelValues...)
+	}
+	return t
+}
+
+// sorted returns the label values and series in a stable order.
+func (v *vec[T]) sorted() ([][]string, []*T) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	labelValues := make([][]string, len(keys))
+	series := make([]*T, len(keys))
+	for i, k := range keys {
+		labelValues[i] = v.keys[k]
+		series[i] = v.values[k]
+	}
+	return labelValues, series
+}
+
+func (v *vec[T]) header(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+	return err
+}
+
+func newVec[T any](name, help, kind string, labels []string, newT func() *T) *vec[T] {
+	return &vec[T]{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: map[string]*T{},
+		keys:   map[string][]string{},
+		newT:   newT,
+	}
+}
+
+type value struct {
+	mu sync.Mutex
+	v  float64
+}
+
+func (v *value) add(d float64) {
+	v.mu.Lock()
+	v.v += d
+	v.mu.Unlock()
+}
+
+func (v *value) set(x float64) {
+	v.mu.Lock()
+	v.v = x
+	v.mu.Unlock()
+}
+
+func (v *value) load() float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.v
+}
+
+// CounterVec is a monotonically increasing value per label combination.
+type CounterVec struct {
+	*vec[value]
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels, func() *value { return &value{} })}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter by d, which must not be negative.
+func (c *CounterVec) Add(d float64, labelValues ...string) {
+	if d < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.get(labelValues).add(d)
+}
+
+func (c *CounterVec) write(w io.Writer) error {
+	if err := c.header(w); err != nil {
+		return err
+	}
+	labelValues, series := c.sorted()
+	for i, s := range series {
+		if err := writeSample(w, c.name, c.labels, labelValues[i], s.load()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GaugeVec is a value per label combination that can go up and down.
+type GaugeVec struct {
+	*vec[value]
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labels, func() *value { return &value{} })}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeVec) Inc(labelValues ...string) { g.get(labelValues).add(1) }
+func (g *GaugeVec) Dec(labelValues ...string) { g.get(labelValues).add(-1) }
+
+func (g *GaugeVec) Set(x float64, labelValues ...string) {
+	g.get(labelValues).set(x)
+}
+
+func (g *GaugeVec) write(w io.Writer) error {
+	if err := g.header(w); err != nil {
+		return err
+	}
+	labelValues, series := g.sorted()
+	for i, s := range series {
+		if err := writeSample(w, g.name, g.labels, labelValues[i], s.load()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type histogram struct {
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec counts observations into buckets per label combination.
+type HistogramVec struct {
+	*vec[histogram]
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram. buckets are upper bounds in
+// increasing order; the +Inf bucket is implied.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	h := &HistogramVec{
+		vec: newVec(name, help, "histogram", labels, func() *histogram {
+			return &histogram{counts: make([]uint64, len(buckets))}
+		}),
+		buckets: buckets,
+	}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	s := h.get(labelValues)
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	if err := h.header(w); err != nil {
+		return err
+	}
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	labelValues, series := h.sorted()
+	for i, s := range series {
+		s.mu.Lock()
+		counts := append([]uint64(nil), s.counts...)
+		sum, count := s.sum, s.count
+		s.mu.Unlock()
+
+		var cumulative uint64
+		for j, upper := range h.buckets {
+			cumulative += counts[j]
+			lv := append(append([]string(nil), labelValues[i]...), formatFloat(upper))
+			if err := writeSample(w, h.name+"_bucket", bucketLabels, lv, float64(cumulative)); err != nil {
+				return err
+			}
+		}
+		lv := append(append([]string(nil), labelValues[i]...), "+Inf")
+		if err := writeSample(w, h.name+"_bucket", bucketLabels, lv, float64(count)); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_sum", h.labels, labelValues[i], sum); err != nil {
+			return err
+		}
+		if err := writeSample(w, h.name+"_count", h.labels, labelValues[i], float64(count)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSample(w io.Writer, name string, labels, labelValues []string, v float64) error {
+	b := strings.Builder{}
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(l)
+			b.WriteString(`="`)
+			b.WriteString(escapeLabelValue(labelValues[i]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatFloat(v))
+	b.WriteByte('\n')
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,327 @@
+// Package migrate applies the SQL migrations in sql/schema. It reads goose's
+// file format and keeps goose's goose_db_version table, so databases that
+// were migrated by hand with the goose CLI carry on where they left off.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockID is the Postgres advisory lock held while migrating so that
+// instances starting together don't apply the same migration twice.
+const lockID = 7_265_411_059
+
+// Migration is one goose SQL file.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	// set by "-- +goose NO TRANSACTION", for statements such as
+	// CREATE INDEX CONCURRENTLY that can't run in a transaction
+	NoTransaction bool
+}
+
+// Parse reads a goose migration. The version is the number before the first
+// underscore in the file name.
+func Parse(name string, data string) (Migration, error) {
+	prefix, _, ok := strings.Cut(path.Base(name), "_")
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if !ok || err != nil || version < 1 {
+		return Migration{}, fmt.Errorf("migrate: %s has no version", name)
+	}
+	m := Migration{Version: version, Name: path.Base(name)}
+
+	var up, down strings.Builder
+	var section *strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose "); ok {
+			switch strings.TrimSpace(annotation) {
+			case "Up":
+				section = &up
+			case "Down":
+				section = &down
+			case "NO TRANSACTION":
+				m.NoTransaction = true
+			case "StatementBegin", "StatementEnd":
+				// each section is sent as one multi-statement query, so
+				// statement boundaries don't need marking
+			default:
+				return Migration{}, fmt.Errorf("migrate: %s: unsupported annotation %q", name, annotation)
+			}
+			continue
+		}
+		if section == nil {
+			if strings.TrimSpace(line) != "" && !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				return Migration{}, fmt.Errorf("migrate: %s: SQL before -- +goose Up", name)
+			}
+			continue
+		}
+		section.WriteString(line)
+		section.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return Migration{}, fmt.Errorf("migrate: %s: %w", name, err)
+	}
+	m.Up = strings.TrimSpace(up.String())
+	m.Down = strings.TrimSpace(down.String())
+	if m.Up == "" {
+		return Migration{}, fmt.Errorf("migrate: %s has no Up section", name)
+	}
+	return m, nil
+}
+
+// Load parses every .sql file in dir, ordered by version.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	names, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(names))
+	seen := map[int64]string{}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		m, err := Parse(name, string(data))
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[m.Version]; ok {
+			return nil, fmt.Errorf("migrate: %s and %s share version %d", other, m.Name, m.Version)
+		}
+		seen[m.Version] = m.Name
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Status is whether one migration has been applied.
+type Status struct {
+	Migration Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// Latest is the version the database will be at once every migration is
+// applied.
+func (m *Migrator) Latest() int64 {
+	if len(m.Migrations) == 0 {
+		return 0
+	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
+
+// Status lists every migration with when it was applied, if it was.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := appliedVersions(ctx, m.DB)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, 0, len(m.Migrations))
+	for _, migration := range m.Migrations {
+		s := Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Pending lists the migrations that haven't been applied.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.UpTo(ctx, m.Latest())
+}
+
+// UpTo applies the pending migrations up to and including version, in
+// version order, and returns the ones it applied.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range pendingUpTo(m.Migrations, applied, version) {
+			err := apply(ctx, conn, migration, migration.Up, `INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, TRUE)`)
+			if err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// pendingUpTo returns the migrations not in applied whose version is at
+// most version.
+func pendingUpTo(migrations []Migration, applied map[int64]time.Time, version int64) []Migration {
+	var pending []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > version {
+			continue
+		}
+		pending = append(pending, migration)
+	}
+	return pending
+}
+
+// Down rolls back the most recently applied migration and returns it, or
+// returns false when nothing has been applied.
+func (m *Migrator) Down(ctx context.Context) (Migration, bool, error) {
+	var rolledBack Migration
+	var found bool
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			migration := m.Migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			rolledBack, found = migration, true
+			return apply(ctx, conn, migration, migration.Down, `DELETE FROM goose_db_version WHERE version_id = $1`)
+		}
+		return nil
+	})
+	return rolledBack, found, err
+}
+
+// locked runs fn on a single connection holding the migration lock. The
+// lock belongs to the session, so everything must use that connection.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID); err != nil {
+		return fmt.Errorf("migrate: taking lock: %w", err)
+	}
+	// unlock even if ctx has been cancelled
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockID)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS goose_db_version (
+			id SERIAL PRIMARY KEY,
+			version_id BIGINT NOT NULL,
+			is_applied BOOLEAN NOT NULL,
+			tstamp TIMESTAMP DEFAULT NOW()
+		)`)
+	if err != nil {
+		return fmt.Errorf("migrate: creating version table: %w", err)
+	}
+	return fn(conn)
+}
+
+// apply runs one section of a migration and records it with record, in a
+// single transaction unless the migration opts out.
+func apply(ctx context.Context, conn *sql.Conn, migration Migration, query, record string) error {
+	if migration.NoTransaction {
+		if query != "" {
+			if _, err := conn.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("migrate: %s: %w", migration.Name, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, record, migration.Version); err != nil {
+			return fmt.Errorf("migrate: recording %s: %w", migration.Name, err)
+		}
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if query != "" {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("migrate: %s: %w", migration.Name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, migration.Version); err != nil {
+		return fmt.Errorf("migrate: recording %s: %w", migration.Name, err)
+	}
+	return tx.Commit()
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// appliedVersions returns when each applied version was applied. Goose
+// appends a row per change, so a version counts as applied if its latest
+// row says so. A missing table means nothing has been applied.
+func appliedVersions(ctx context.Context, db querier) (map[int64]time.Time, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT to_regclass('goose_db_version') IS NOT NULL`).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	applied := map[int64]time.Time{}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT version_id, tstamp FROM (
+			SELECT DISTINCT ON (version_id) version_id, is_applied, tstamp
+			FROM goose_db_version
+			ORDER BY version_id, id DESC
+		) v
+		WHERE is_applied AND version_id > 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var at sql.NullTime
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at.Time
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,100 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	m, err := Parse("sql/schema/20251007201018_add_field.sql", `-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN hashed_password TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN hashed_password;
+`)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if m.Version != 20251007201018 || m.Name != "20251007201018_add_field.sql" {
+		t.Errorf("Version, Name = %d, %q", m.Version, m.Name)
+	}
+	if m.Up != "ALTER TABLE users ADD COLUMN hashed_password TEXT;" {
+		t.Errorf("Up = %q", m.Up)
+	}
+	if m.Down != "ALTER TABLE users DROP COLUMN hashed_password;" {
+		t.Errorf("Down = %q", m.Down)
+	}
+	if m.NoTransaction {
+		t.Error("NoTransaction = true")
+	}
+}
+
+func TestParseNoTransaction(t *testing.T) {
+	m, err := Parse("3_index.sql", "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY i ON t (c);\n")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !m.NoTransaction || m.Down != "" {
+		t.Errorf("m = %+v", m)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"users.sql":     "-- +goose Up\nSELECT 1;",
+		"1_empty.sql":   "-- +goose Down\nSELECT 1;",
+		"2_early.sql":   "SELECT 1;\n-- +goose Up\nSELECT 1;",
+		"3_unknown.sql": "-- +goose Up\n-- +goose envsub on\nSELECT 1;",
+	} {
+		if _, err := Parse(name, data); err == nil {
+			t.Errorf("Parse(%q) error = nil", name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"schema/002_chirps.sql":                       {Data: []byte("-- +goose Up\nCREATE TABLE chirps ();")},
+		"schema/001_users.sql":                        {Data: []byte("-- +goose Up\nCREATE TABLE users ();")},
+		"schema/20261019090000_create_identities.sql": {Data: []byte("-- +goose Up\nCREATE TABLE user_identities ();")},
+		"schema/README.md":                            {Data: []byte("not a migration")},
+	}
+	migrations, err := Load(fsys, "schema")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	var versions []int64
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	if len(versions) != 3 || versions[0] != 1 || versions[1] != 2 || versions[2] != 20261019090000 {
+		t.Errorf("versions = %v", versions)
+	}
+	if got := (&Migrator{Migrations: migrations}).Latest(); got != 20261019090000 {
+		t.Errorf("Latest() = %d", got)
+	}
+
+	fsys["schema/01_dupe.sql"] = &fstest.MapFile{Data: []byte("-- +goose Up\nSELECT 1;")}
+	if _, err := Load(fsys, "schema"); err == nil {
+		t.Error("Load() with duplicate versions error = nil")
+	}
+}
+
+func TestPendingUpTo(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}, {Version: 4}}
+	applied := map[int64]time.Time{1: time.Now()}
+
+	var versions []int64
+	for _, m := range pendingUpTo(migrations, applied, 3) {
+		versions = append(versions, m.Version)
+	}
+	if len(versions) != 2 || versions[0] != 2 || versions[1] != 3 {
+		t.Errorf("pendingUpTo(3) = %v, want [2 3]", versions)
+	}
+	if pending := pendingUpTo(migrations, applied, 1); len(pending) != 0 {
+		t.Errorf("pendingUpTo(1) = %v, want none", pending)
+	}
+}
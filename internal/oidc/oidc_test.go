@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/auth"
+	"github.com/mattcollier/boot-go-server/internal/oidc/oidctest"
+)
+
+func newFakeIssuer(t *testing.T) *oidctest.Issuer {
+	t.Helper()
+	f, err := oidctest.New()
+	if err != nil {
+		t.Fatalf("failed to start issuer: %v", err)
+	}
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newTestProvider(f *oidctest.Issuer) *Provider {
+	return NewProvider(Config{
+		Issuer:      f.URL,
+		ClientID:    "chirpy",
+		RedirectURL: "http://localhost:8080/api/auth/fake/callback",
+	}, f.Client())
+}
+
+// authorize follows the provider's authorization endpoint and returns the
+// code and state it redirects back with.
+func authorize(t *testing.T, f *oidctest.Issuer, p *Provider, state, nonce, challenge string) (string, string) {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+
+	code, state, err := f.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("authorize request failed: %v", err)
+	}
+	return code, state
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := newTestProvider(f)
+	ctx := context.Background()
+
+	verifier, _ := auth.MakePKCEVerifier()
+	code, state := authorize(t, f, p, "state-1", "nonce-1", auth.PKCEChallenge(verifier))
+	if state != "state-1" {
+		t.Fatalf("state = %q, want %q", state, "state-1")
+	}
+
+	rawIDToken, err := p.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, "nonce-1")
+	if err != nil {
+		t.Fatalf("VerifyIDToken returned error: %v", err)
+	}
+	if claims.Subject != f.Subject {
+		t.Errorf("Subject = %q, want %q", claims.Subject, f.Subject)
+	}
+	if claims.Email != f.Email || !claims.EmailVerified {
+		t.Errorf("Email = %q (verified=%v), want %q verified", claims.Email, claims.EmailVerified, f.Email)
+	}
+}
+
+func TestProvider_ExchangeWrongVerifier(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := newTestProvider(f)
+
+	verifier, _ := auth.MakePKCEVerifier()
+	code, _ := authorize(t, f, p, "state", "nonce", auth.PKCEChallenge(verifier))
+
+	other, _ := auth.MakePKCEVerifier()
+	_, err := p.Exchange(context.Background(), code, other)
+	if err == nil {
+		t.Fatalf("expected error for mismatched code verifier, got nil")
+	}
+	if !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvider_VerifyIDToken_NonceMismatch(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := newTestProvider(f)
+
+	raw := f.SignIDToken("chirpy", "expected", time.Hour)
+	_, err := p.VerifyIDToken(context.Background(), raw, "other")
+	if err == nil {
+		t.Fatalf("expected nonce error, got nil")
+	}
+	if !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestProvider_VerifyIDToken_WrongAudience(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := newTestProvider(f)
+
+	raw := f.SignIDToken("someone-else", "nonce", time.Hour)
+	_, err := p.VerifyIDToken(context.Background(), raw, "nonce")
+	if err == nil {
+		t.Fatalf("expected audience error, got nil")
+	}
+}
+
+func TestProvider_VerifyIDToken_Expired(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := newTestProvider(f)
+
+	raw := f.SignIDToken("chirpy", "nonce", -time.Minute)
+	_, err := p.VerifyIDToken(context.Background(), raw, "nonce")
+	if err == nil {
+		t.Fatalf("expected expiration error, got nil")
+	}
+}
+
+func TestProvider_VerifyIDToken_ForeignKey(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := newTestProvider(f)
+
+	// sign with a key the provider never published
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	f.Key, other = other, f.Key
+	raw := f.SignIDToken("chirpy", "nonce", time.Hour)
+	f.Key = other
+
+	_, err = p.VerifyIDToken(context.Background(), raw, "nonce")
+	if err == nil {
+		t.Fatalf("expected signature error, got nil")
+	}
+}
+
+func TestProvider_VerifyIDToken_UnknownKidRefetchLimited(t *testing.T) {
+	f := newFakeIssuer(t)
+	p := newTestProvider(f)
+
+	if _, err := p.VerifyIDToken(context.Background(), f.SignIDToken("chirpy", "nonce", time.Hour), "nonce"); err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	f.KID = "rotated"
+	raw := f.SignIDToken("chirpy", "nonce", time.Hour)
+	for range 5 {
+		if _, err := p.VerifyIDToken(context.Background(), raw, "nonce"); err == nil {
+			t.Fatalf("expected unknown key error, got nil")
+		}
+	}
+
+	if n := f.JWKSServed(); n != 1 {
+		t.Errorf("expected 1 jwks fetch, got %d", n)
+	}
+}
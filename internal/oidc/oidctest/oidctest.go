@@ -0,0 +1,199 @@
+// Package oidctest provides an in-process OpenID Connect provider for
+// exercising sign-in flows in tests.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mattcollier/boot-go-server/internal/auth"
+)
+
+// Issuer is a minimal OIDC provider. Its authorization endpoint approves
+// every request immediately for Subject.
+type Issuer struct {
+	URL           string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Key signs ID tokens and is published under KID. Tests swap them to
+	// rotate keys or forge tokens.
+	Key *rsa.PrivateKey
+	KID string
+
+	server     *httptest.Server
+	mu         sync.Mutex
+	codes      map[string]pendingCode
+	jwksServed int
+}
+
+type pendingCode struct {
+	challenge string
+	nonce     string
+	clientID  string
+}
+
+// New starts an issuer. Close it when done.
+func New() (*Issuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	i := &Issuer{
+		Subject:       "user-123",
+		Email:         "gopher@example.com",
+		EmailVerified: true,
+		Key:           key,
+		KID:           "test-key",
+		codes:         map[string]pendingCode{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", i.handleDiscovery)
+	mux.HandleFunc("GET /jwks", i.handleJWKS)
+	mux.HandleFunc("GET /authorize", i.handleAuthorize)
+	mux.HandleFunc("POST /token", i.handleToken)
+	i.server = httptest.NewServer(mux)
+	i.URL = i.server.URL
+	return i, nil
+}
+
+func (i *Issuer) Close() {
+	i.server.Close()
+}
+
+// Client returns an HTTP client for talking to the issuer.
+func (i *Issuer) Client() *http.Client {
+	return i.server.Client()
+}
+
+// JWKSServed reports how many times the key set has been fetched.
+func (i *Issuer) JWKSServed() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.jwksServed
+}
+
+// Authorize follows an authorization URL and returns the code and state
+// the issuer redirects back with.
+func (i *Issuer) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil {
+		return "", "", err
+	}
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+// SignIDToken issues an ID token for Subject. It panics if signing fails.
+func (i *Issuer) SignIDToken(audience, nonce string, ttl time.Duration) string {
+	now := time.Now()
+	claims := struct {
+		jwt.RegisteredClaims
+		Nonce         string `json:"nonce"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+	}{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    i.URL,
+			Subject:   i.Subject,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Nonce:         nonce,
+		Email:         i.Email,
+		EmailVerified: i.EmailVerified,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = i.KID
+	signed, err := token.SignedString(i.Key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (i *Issuer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 i.URL,
+		"authorization_endpoint": i.URL + "/authorize",
+		"token_endpoint":         i.URL + "/token",
+		"jwks_uri":               i.URL + "/jwks",
+	})
+}
+
+func (i *Issuer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	i.mu.Lock()
+	i.jwksServed++
+	i.mu.Unlock()
+	pub := i.Key.PublicKey
+	json.NewEncoder(w).Encode(map[string][]map[string]string{
+		"keys": {{
+			"kid": i.KID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (i *Issuer) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE required", http.StatusBadRequest)
+		return
+	}
+	code, _ := auth.MakeNonce()
+	i.mu.Lock()
+	i.codes[code] = pendingCode{
+		challenge: q.Get("code_challenge"),
+		nonce:     q.Get("nonce"),
+		clientID:  q.Get("client_id"),
+	}
+	i.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	rq := redirect.Query()
+	rq.Set("code", code)
+	rq.Set("state", q.Get("state"))
+	redirect.RawQuery = rq.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (i *Issuer) handleToken(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	i.mu.Lock()
+	pending, ok := i.codes[r.PostForm.Get("code")]
+	delete(i.codes, r.PostForm.Get("code"))
+	i.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !ok || auth.PKCEChallenge(r.PostForm.Get("code_verifier")) != pending.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"id_token": i.SignIDToken(pending.clientID, pending.nonce, time.Hour),
+	})
+}
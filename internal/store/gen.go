@@ -0,0 +1,232 @@
+//go:build ignore
+
+// gen writes queries.go from the sqlc generated database.Queries: an
+// interface per query file, the Store interface combining them, and a
+// Postgres method for every query with its error translated. Run go
+// generate after regenerating the sqlc code.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const databasePath = "github.com/mattcollier/boot-go-server/internal/database"
+
+// interfaceNames names the interface for each query file. A new query file
+// needs an entry here.
+var interfaceNames = map[string]string{
+	"api_keys.sql.go":           "APIKeyQueries",
+	"audit_log.sql.go":          "AuditLogQueries",
+	"chirps.sql.go":             "ChirpQueries",
+	"oauth.sql.go":              "OAuthQueries",
+	"oidc_login_states.sql.go":  "OIDCLoginStateQueries",
+	"page_views.sql.go":         "PageViewQueries",
+	"refresh_tokens.sql.go":     "RefreshTokenQueries",
+	"stats.sql.go":              "StatsQueries",
+	"subscriptions.sql.go":      "SubscriptionQueries",
+	"user_identities.sql.go":    "UserIdentityQueries",
+	"users.sql.go":              "UserQueries",
+	"webauthn.sql.go":           "WebauthnQueries",
+	"webhook_deliveries.sql.go": "WebhookDeliveryQueries",
+	"webhook_endpoints.sql.go":  "WebhookEndpointQueries",
+	"webhook_events.sql.go":     "WebhookEventQueries",
+}
+
+func main() {
+	fset := token.NewFileSet()
+	files, err := filepath.Glob("../database/*.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// import name to path, from the files the methods come from
+	importPaths := map[string]string{"database": databasePath}
+	var methods []*ast.FuncDecl
+	byInterface := map[string][]*ast.FuncDecl{}
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, imp := range f.Imports {
+			path, _ := strconv.Unquote(imp.Path.Value)
+			importName := filepath.Base(path)
+			if imp.Name != nil {
+				importName = imp.Name.Name
+			}
+			importPaths[importName] = path
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if ok && isQueriesMethod(fn) && fn.Name.IsExported() && fn.Name.Name != "WithTx" {
+				iface, ok := interfaceNames[filepath.Base(name)]
+				if !ok {
+					log.Fatalf("%s has no interface name; add it to interfaceNames", filepath.Base(name))
+				}
+				methods = append(methods, fn)
+				byInterface[iface] = append(byInterface[iface], fn)
+			}
+		}
+	}
+	sortByName(methods)
+	var ifaces []string
+	for iface := range byInterface {
+		ifaces = append(ifaces, iface)
+	}
+	sort.Strings(ifaces)
+
+	used := map[string]bool{}
+	for _, fn := range methods {
+		qualify(fn.Type)
+		collectPackages(fn.Type, used)
+	}
+
+	var body bytes.Buffer
+	body.WriteString("\n// Store runs every query the server uses. Postgres implements it for\n// production.\ntype Store interface {\n")
+	for _, iface := range ifaces {
+		fmt.Fprintf(&body, "\t%s\n", iface)
+	}
+	body.WriteString("}\n\nvar _ Store = (*Postgres)(nil)\n")
+	for _, iface := range ifaces {
+		fns := byInterface[iface]
+		sortByName(fns)
+		fmt.Fprintf(&body, "\ntype %s interface {\n", iface)
+		for _, fn := range fns {
+			fmt.Fprintf(&body, "\t%s%s\n", fn.Name.Name, strings.TrimPrefix(exprString(fset, fn.Type), "func"))
+		}
+		body.WriteString("}\n")
+	}
+	for _, fn := range methods {
+		writeMethod(&body, fset, fn)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by gen.go. DO NOT EDIT.\n\npackage store\n\nimport (\n")
+	// standard library first, as goimports groups them
+	var std, other []string
+	for name := range used {
+		path := importPaths[name]
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			other = append(other, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(other)
+	for _, path := range std {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString("\n")
+	for _, path := range other {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString(")\n")
+	out.Write(body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("formatting output: %v\n%s", err, out.Bytes())
+	}
+	if err := os.WriteFile("queries.go", src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func sortByName(fns []*ast.FuncDecl) {
+	sort.Slice(fns, func(i, j int) bool { return fns[i].Name.Name < fns[j].Name.Name })
+}
+
+func isQueriesMethod(fn *ast.FuncDecl) bool {
+	if fn.Recv == nil || len(fn.Recv.List) != 1 {
+		return false
+	}
+	star, ok := fn.Recv.List[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	ident, ok := star.X.(*ast.Ident)
+	return ok && ident.Name == "Queries"
+}
+
+// qualify rewrites types declared in package database, such as Chirp, to
+// database.Chirp.
+func qualify(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// already qualified; don't descend into the package name
+			return false
+		case *ast.Field:
+			n.Type = qualifyExpr(n.Type)
+		}
+		return true
+	})
+}
+
+func qualifyExpr(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if e.IsExported() {
+			// fresh identifiers, so the printer has no positions to keep
+			return &ast.SelectorExpr{X: ast.NewIdent("database"), Sel: ast.NewIdent(e.Name)}
+		}
+	case *ast.StarExpr:
+		e.X = qualifyExpr(e.X)
+	case *ast.ArrayType:
+		e.Elt = qualifyExpr(e.Elt)
+	case *ast.MapType:
+		e.Key = qualifyExpr(e.Key)
+		e.Value = qualifyExpr(e.Value)
+	}
+	return expr
+}
+
+func collectPackages(node ast.Node, used map[string]bool) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if pkg, ok := sel.X.(*ast.Ident); ok {
+				used[pkg.Name] = true
+			}
+			return false
+		}
+		return true
+	})
+}
+
+func writeMethod(w *bytes.Buffer, fset *token.FileSet, fn *ast.FuncDecl) {
+	var params, args []string
+	for _, field := range fn.Type.Params.List {
+		for _, name := range field.Names {
+			params = append(params, name.Name+" "+exprString(fset, field.Type))
+			args = append(args, name.Name)
+		}
+	}
+	call := fmt.Sprintf("p.q.%s(%s)", fn.Name.Name, strings.Join(args, ", "))
+
+	results := fn.Type.Results.List
+	fmt.Fprintf(w, "\nfunc (p *Postgres) %s(%s) ", fn.Name.Name, strings.Join(params, ", "))
+	if len(results) == 1 {
+		fmt.Fprintf(w, "error {\n\treturn translate(%s)\n}\n", call)
+		return
+	}
+	fmt.Fprintf(w, "(%s, error) {\n\tv, err := %s\n\treturn v, translate(err)\n}\n", exprString(fset, results[0].Type), call)
+}
+
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
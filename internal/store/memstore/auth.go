@@ -0,0 +1,418 @@
+package memstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+)
+
+// take removes and returns the first row match accepts, like DELETE ...
+// RETURNING on a primary key.
+func take[T any](rows *[]T, match func(T) bool) (T, error) {
+	i := slices.IndexFunc(*rows, match)
+	if i < 0 {
+		var zero T
+		return zero, notFound()
+	}
+	row := (*rows)[i]
+	*rows = slices.Delete(*rows, i, i+1)
+	return row, nil
+}
+
+// ownedBy returns the rows match accepts, oldest first.
+func ownedBy[T any](rows []T, match func(T) bool, createdAt func(T) time.Time) []T {
+	var owned []T
+	for _, row := range rows {
+		if match(row) {
+			owned = append(owned, row)
+		}
+	}
+	slices.SortStableFunc(owned, func(a, b T) int { return createdAt(a).Compare(createdAt(b)) })
+	return owned
+}
+
+func (s *Store) CreateAPIKey(ctx context.Context, arg database.CreateAPIKeyParams) (database.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.apiKeys, func(k database.ApiKey) bool { return k.Prefix == arg.Prefix }) {
+		return database.ApiKey{}, conflict("api_keys_prefix_key")
+	}
+	if err := s.checkUser(uuid.NullUUID{UUID: arg.UserID, Valid: true}, "api_keys_user_id_fkey"); err != nil {
+		return database.ApiKey{}, err
+	}
+	now := time.Now()
+	k := database.ApiKey{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		Prefix:    arg.Prefix,
+		HashedKey: arg.HashedKey,
+		Scope:     arg.Scope,
+		ExpiresAt: arg.ExpiresAt,
+	}
+	s.apiKeys = append(s.apiKeys, k)
+	return k, nil
+}
+
+func (s *Store) GetAPIKeyByPrefix(ctx context.Context, prefix string) (database.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.apiKeys, func(k database.ApiKey) bool { return k.Prefix == prefix })
+	if i < 0 {
+		return database.ApiKey{}, notFound()
+	}
+	return s.apiKeys[i], nil
+}
+
+func (s *Store) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ownedBy(s.apiKeys, func(k database.ApiKey) bool {
+		return k.UserID == userID && !k.RevokedAt.Valid
+	}, func(k database.ApiKey) time.Time { return k.CreatedAt }), nil
+}
+
+func (s *Store) RevokeAPIKey(ctx context.Context, arg database.RevokeAPIKeyParams) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.apiKeys, func(k database.ApiKey) bool {
+		return k.ID == arg.ID && k.UserID == arg.UserID && !k.RevokedAt.Valid
+	})
+	if i < 0 {
+		return uuid.UUID{}, notFound()
+	}
+	now := time.Now()
+	s.apiKeys[i].RevokedAt = sql.NullTime{Time: now, Valid: true}
+	s.apiKeys[i].UpdatedAt = now
+	return arg.ID, nil
+}
+
+func (s *Store) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.IndexFunc(s.apiKeys, func(k database.ApiKey) bool { return k.ID == id }); i >= 0 {
+		s.apiKeys[i].LastUsedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	}
+	return nil
+}
+
+// deleteOAuthClients deletes the clients match accepts along with their
+// codes and consents.
+func (s *Store) deleteOAuthClients(match func(c database.OauthClient) bool) {
+	deleted := map[string]bool{}
+	s.oauthClients = slices.DeleteFunc(s.oauthClients, func(c database.OauthClient) bool {
+		if match(c) {
+			deleted[c.ID] = true
+			return true
+		}
+		return false
+	})
+	s.oauthCodes = slices.DeleteFunc(s.oauthCodes, func(c database.OauthAuthorizationCode) bool { return deleted[c.ClientID] })
+	s.oauthConsents = slices.DeleteFunc(s.oauthConsents, func(c database.OauthConsent) bool { return deleted[c.ClientID] })
+}
+
+func (s *Store) oauthClientExists(id string) bool {
+	return slices.ContainsFunc(s.oauthClients, func(c database.OauthClient) bool { return c.ID == id })
+}
+
+func (s *Store) CreateOAuthClient(ctx context.Context, arg database.CreateOAuthClientParams) (database.OauthClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oauthClientExists(arg.ID) {
+		return database.OauthClient{}, conflict("oauth_clients_pkey")
+	}
+	if err := s.checkUser(uuid.NullUUID{UUID: arg.UserID, Valid: true}, "oauth_clients_user_id_fkey"); err != nil {
+		return database.OauthClient{}, err
+	}
+	now := time.Now()
+	c := database.OauthClient{
+		ID:           arg.ID,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Name:         arg.Name,
+		HashedSecret: arg.HashedSecret,
+		RedirectUri:  arg.RedirectUri,
+		Scope:        arg.Scope,
+		UserID:       arg.UserID,
+	}
+	s.oauthClients = append(s.oauthClients, c)
+	return c, nil
+}
+
+func (s *Store) GetOAuthClient(ctx context.Context, id string) (database.OauthClient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.oauthClients, func(c database.OauthClient) bool { return c.ID == id })
+	if i < 0 {
+		return database.OauthClient{}, notFound()
+	}
+	return s.oauthClients[i], nil
+}
+
+func (s *Store) CreateOAuthAuthorizationCode(ctx context.Context, arg database.CreateOAuthAuthorizationCodeParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.oauthCodes, func(c database.OauthAuthorizationCode) bool { return c.Code == arg.Code }) {
+		return conflict("oauth_authorization_codes_pkey")
+	}
+	if !s.oauthClientExists(arg.ClientID) {
+		return conflict("oauth_authorization_codes_client_id_fkey")
+	}
+	if err := s.checkUser(uuid.NullUUID{UUID: arg.UserID, Valid: true}, "oauth_authorization_codes_user_id_fkey"); err != nil {
+		return err
+	}
+	s.oauthCodes = append(s.oauthCodes, database.OauthAuthorizationCode{
+		Code:          arg.Code,
+		CreatedAt:     time.Now(),
+		ExpiresAt:     arg.ExpiresAt,
+		ClientID:      arg.ClientID,
+		UserID:        arg.UserID,
+		RedirectUri:   arg.RedirectUri,
+		Scope:         arg.Scope,
+		CodeChallenge: arg.CodeChallenge,
+	})
+	return nil
+}
+
+func (s *Store) ConsumeOAuthAuthorizationCode(ctx context.Context, code string) (database.OauthAuthorizationCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return take(&s.oauthCodes, func(c database.OauthAuthorizationCode) bool { return c.Code == code })
+}
+
+func (s *Store) UpsertOAuthConsent(ctx context.Context, arg database.UpsertOAuthConsentParams) (database.OauthConsent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	i := slices.IndexFunc(s.oauthConsents, func(c database.OauthConsent) bool {
+		return c.UserID == arg.UserID && c.ClientID == arg.ClientID
+	})
+	if i >= 0 {
+		c := &s.oauthConsents[i]
+		c.Scope = arg.Scope
+		c.UpdatedAt = now
+		if c.RevokedAt.Valid {
+			c.GrantedAt = now
+		}
+		c.RevokedAt = sql.NullTime{}
+		return *c, nil
+	}
+	if err := s.checkUser(uuid.NullUUID{UUID: arg.UserID, Valid: true}, "oauth_consents_user_id_fkey"); err != nil {
+		return database.OauthConsent{}, err
+	}
+	if !s.oauthClientExists(arg.ClientID) {
+		return database.OauthConsent{}, conflict("oauth_consents_client_id_fkey")
+	}
+	c := database.OauthConsent{
+		UserID:    arg.UserID,
+		ClientID:  arg.ClientID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Scope:     arg.Scope,
+		GrantedAt: now,
+	}
+	s.oauthConsents = append(s.oauthConsents, c)
+	return c, nil
+}
+
+func (s *Store) GetOAuthConsent(ctx context.Context, arg database.GetOAuthConsentParams) (database.OauthConsent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.oauthConsents, func(c database.OauthConsent) bool {
+		return c.UserID == arg.UserID && c.ClientID == arg.ClientID
+	})
+	if i < 0 {
+		return database.OauthConsent{}, notFound()
+	}
+	return s.oauthConsents[i], nil
+}
+
+func (s *Store) ListOAuthConsents(ctx context.Context, userID uuid.UUID) ([]database.OauthConsent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ownedBy(s.oauthConsents, func(c database.OauthConsent) bool {
+		return c.UserID == userID && !c.RevokedAt.Valid
+	}, func(c database.OauthConsent) time.Time { return c.CreatedAt }), nil
+}
+
+func (s *Store) RevokeOAuthConsent(ctx context.Context, arg database.RevokeOAuthConsentParams) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.oauthConsents, func(c database.OauthConsent) bool {
+		return c.UserID == arg.UserID && c.ClientID == arg.ClientID && !c.RevokedAt.Valid
+	})
+	if i < 0 {
+		return "", notFound()
+	}
+	now := time.Now()
+	s.oauthConsents[i].RevokedAt = sql.NullTime{Time: now, Valid: true}
+	s.oauthConsents[i].UpdatedAt = now
+	return arg.ClientID, nil
+}
+
+func (s *Store) CreateOIDCLoginState(ctx context.Context, arg database.CreateOIDCLoginStateParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.oidcLoginStates, func(st database.OidcLoginState) bool { return st.State == arg.State }) {
+		return conflict("oidc_login_states_pkey")
+	}
+	s.oidcLoginStates = append(s.oidcLoginStates, database.OidcLoginState{
+		State:        arg.State,
+		CreatedAt:    time.Now(),
+		ExpiresAt:    arg.ExpiresAt,
+		Provider:     arg.Provider,
+		Nonce:        arg.Nonce,
+		CodeVerifier: arg.CodeVerifier,
+	})
+	return nil
+}
+
+func (s *Store) ConsumeOIDCLoginState(ctx context.Context, state string) (database.OidcLoginState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return take(&s.oidcLoginStates, func(st database.OidcLoginState) bool { return st.State == state })
+}
+
+func (s *Store) DeleteExpiredOIDCLoginStates(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.oidcLoginStates)
+	now := time.Now()
+	s.oidcLoginStates = slices.DeleteFunc(s.oidcLoginStates, func(st database.OidcLoginState) bool {
+		return st.ExpiresAt.Before(now)
+	})
+	return int64(before - len(s.oidcLoginStates)), nil
+}
+
+func (s *Store) CreateUserIdentity(ctx context.Context, arg database.CreateUserIdentityParams) (database.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.userIdentities, func(i database.UserIdentity) bool {
+		return i.Provider == arg.Provider && i.Subject == arg.Subject
+	}) {
+		return database.UserIdentity{}, conflict("user_identities_provider_subject_key")
+	}
+	if err := s.checkUser(uuid.NullUUID{UUID: arg.UserID, Valid: true}, "user_identities_user_id_fkey"); err != nil {
+		return database.UserIdentity{}, err
+	}
+	now := time.Now()
+	identity := database.UserIdentity{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		Provider:  arg.Provider,
+		Subject:   arg.Subject,
+		Email:     arg.Email,
+	}
+	s.userIdentities = append(s.userIdentities, identity)
+	return identity, nil
+}
+
+func (s *Store) GetUserIdentity(ctx context.Context, arg database.GetUserIdentityParams) (database.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.userIdentities, func(i database.UserIdentity) bool {
+		return i.Provider == arg.Provider && i.Subject == arg.Subject
+	})
+	if i < 0 {
+		return database.UserIdentity{}, notFound()
+	}
+	return s.userIdentities[i], nil
+}
+
+func (s *Store) ListUserIdentities(ctx context.Context, userID uuid.UUID) ([]database.UserIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ownedBy(s.userIdentities, func(i database.UserIdentity) bool { return i.UserID == userID },
+		func(i database.UserIdentity) time.Time { return i.CreatedAt }), nil
+}
+
+func (s *Store) CreateWebauthnCredential(ctx context.Context, arg database.CreateWebauthnCredentialParams) (database.WebauthnCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.webauthnCredentials, func(c database.WebauthnCredential) bool {
+		return bytes.Equal(c.CredentialID, arg.CredentialID)
+	}) {
+		return database.WebauthnCredential{}, conflict("webauthn_credentials_credential_id_key")
+	}
+	if err := s.checkUser(uuid.NullUUID{UUID: arg.UserID, Valid: true}, "webauthn_credentials_user_id_fkey"); err != nil {
+		return database.WebauthnCredential{}, err
+	}
+	now := time.Now()
+	c := database.WebauthnCredential{
+		ID:           uuid.New(),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		UserID:       arg.UserID,
+		CredentialID: arg.CredentialID,
+		PublicKey:    arg.PublicKey,
+		SignCount:    arg.SignCount,
+		Aaguid:       arg.Aaguid,
+	}
+	s.webauthnCredentials = append(s.webauthnCredentials, c)
+	return c, nil
+}
+
+func (s *Store) GetWebauthnCredential(ctx context.Context, credentialID []byte) (database.WebauthnCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.webauthnCredentials, func(c database.WebauthnCredential) bool {
+		return bytes.Equal(c.CredentialID, credentialID)
+	})
+	if i < 0 {
+		return database.WebauthnCredential{}, notFound()
+	}
+	return s.webauthnCredentials[i], nil
+}
+
+func (s *Store) ListWebauthnCredentials(ctx context.Context, userID uuid.UUID) ([]database.WebauthnCredential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ownedBy(s.webauthnCredentials, func(c database.WebauthnCredential) bool { return c.UserID == userID },
+		func(c database.WebauthnCredential) time.Time { return c.CreatedAt }), nil
+}
+
+func (s *Store) UpdateWebauthnSignCount(ctx context.Context, arg database.UpdateWebauthnSignCountParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.IndexFunc(s.webauthnCredentials, func(c database.WebauthnCredential) bool { return c.ID == arg.ID }); i >= 0 {
+		now := time.Now()
+		s.webauthnCredentials[i].SignCount = arg.SignCount
+		s.webauthnCredentials[i].LastUsedAt = sql.NullTime{Time: now, Valid: true}
+		s.webauthnCredentials[i].UpdatedAt = now
+	}
+	return nil
+}
+
+func (s *Store) CreateWebauthnChallenge(ctx context.Context, arg database.CreateWebauthnChallengeParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.webauthnChallenges, func(c database.WebauthnChallenge) bool { return c.Challenge == arg.Challenge }) {
+		return conflict("webauthn_challenges_pkey")
+	}
+	if err := s.checkUser(arg.UserID, "webauthn_challenges_user_id_fkey"); err != nil {
+		return err
+	}
+	s.webauthnChallenges = append(s.webauthnChallenges, database.WebauthnChallenge{
+		Challenge: arg.Challenge,
+		CreatedAt: time.Now(),
+		ExpiresAt: arg.ExpiresAt,
+		Ceremony:  arg.Ceremony,
+		UserID:    arg.UserID,
+	})
+	return nil
+}
+
+func (s *Store) ConsumeWebauthnChallenge(ctx context.Context, challenge string) (database.WebauthnChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return take(&s.webauthnChallenges, func(c database.WebauthnChallenge) bool { return c.Challenge == challenge })
+}
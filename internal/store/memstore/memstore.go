@@ -0,0 +1,628 @@
+// Package memstore is an in-memory store.Store for tests that shouldn't
+// need Postgres. It implements every query with the same semantics as the
+// SQL: the same ordering, unique keys, foreign keys and cascading deletes,
+// and the same store.ErrNotFound and store.ErrConflict errors.
+//
+// FOR UPDATE SKIP LOCKED has no equivalent; the mutex serializes every
+// query instead.
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+// Store holds every table in memory. Rows are kept in insertion order, so
+// ties in an ORDER BY come back in the order they were written. It is
+// safe for concurrent use.
+type Store struct {
+	mu                  sync.Mutex
+	users               []database.User
+	chirps              []database.Chirp
+	refreshTokens       []database.RefreshToken
+	auditLog            []database.AdminAuditLog
+	subscriptions       []database.Subscription
+	apiKeys             []database.ApiKey
+	oauthClients        []database.OauthClient
+	oauthCodes          []database.OauthAuthorizationCode
+	oauthConsents       []database.OauthConsent
+	oidcLoginStates     []database.OidcLoginState
+	userIdentities      []database.UserIdentity
+	webauthnCredentials []database.WebauthnCredential
+	webauthnChallenges  []database.WebauthnChallenge
+	pageViews           []database.PageView
+	visitorSalt         []byte
+	webhookEndpoints    []database.WebhookEndpoint
+	webhookDeliveries   []database.WebhookDelivery
+	webhookEvents       []database.WebhookEvent
+}
+
+func New() *Store {
+	return &Store{}
+}
+
+func notFound() error {
+	return &store.Error{Kind: store.ErrNotFound, Err: sql.ErrNoRows}
+}
+
+func conflict(constraint string) error {
+	return &store.Error{
+		Kind:       store.ErrConflict,
+		Constraint: constraint,
+		Err:        fmt.Errorf("violates constraint %q", constraint),
+	}
+}
+
+// sameUser is user_id = $1, which never matches NULL.
+func sameUser(a, b uuid.NullUUID) bool {
+	return a.Valid && b.Valid && a.UUID == b.UUID
+}
+
+func (s *Store) userIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.users, func(u database.User) bool { return u.ID == id })
+}
+
+// checkUser enforces a foreign key to users. NULL references are allowed.
+func (s *Store) checkUser(id uuid.NullUUID, constraint string) error {
+	if id.Valid && s.userIndex(id.UUID) < 0 {
+		return conflict(constraint)
+	}
+	return nil
+}
+
+// updateUser applies fn to a user and returns the result.
+func (s *Store) updateUser(id uuid.UUID, fn func(u *database.User)) (database.User, error) {
+	i := s.userIndex(id)
+	if i < 0 {
+		return database.User{}, notFound()
+	}
+	fn(&s.users[i])
+	return s.users[i], nil
+}
+
+// deleteUsers deletes the users keep rejects, cascading as the schema does.
+func (s *Store) deleteUsers(keep func(u database.User) bool) int64 {
+	deleted := map[uuid.UUID]bool{}
+	s.users = slices.DeleteFunc(s.users, func(u database.User) bool {
+		if keep(u) {
+			return false
+		}
+		deleted[u.ID] = true
+		return true
+	})
+	owned := func(id uuid.NullUUID) bool { return id.Valid && deleted[id.UUID] }
+	s.chirps = slices.DeleteFunc(s.chirps, func(c database.Chirp) bool { return owned(c.UserID) })
+	s.refreshTokens = slices.DeleteFunc(s.refreshTokens, func(t database.RefreshToken) bool { return owned(t.UserID) })
+	s.subscriptions = slices.DeleteFunc(s.subscriptions, func(sub database.Subscription) bool { return deleted[sub.UserID] })
+	s.apiKeys = slices.DeleteFunc(s.apiKeys, func(k database.ApiKey) bool { return deleted[k.UserID] })
+	s.userIdentities = slices.DeleteFunc(s.userIdentities, func(i database.UserIdentity) bool { return deleted[i.UserID] })
+	s.webauthnCredentials = slices.DeleteFunc(s.webauthnCredentials, func(c database.WebauthnCredential) bool { return deleted[c.UserID] })
+	s.webauthnChallenges = slices.DeleteFunc(s.webauthnChallenges, func(c database.WebauthnChallenge) bool { return owned(c.UserID) })
+	s.oauthCodes = slices.DeleteFunc(s.oauthCodes, func(c database.OauthAuthorizationCode) bool { return deleted[c.UserID] })
+	s.oauthConsents = slices.DeleteFunc(s.oauthConsents, func(c database.OauthConsent) bool { return deleted[c.UserID] })
+	s.deleteOAuthClients(func(c database.OauthClient) bool { return deleted[c.UserID] })
+	s.deleteWebhookEndpoints(func(e database.WebhookEndpoint) bool { return owned(e.UserID) })
+	// actor_id is ON DELETE SET NULL
+	for i := range s.auditLog {
+		if owned(s.auditLog[i].ActorID) {
+			s.auditLog[i].ActorID = uuid.NullUUID{}
+		}
+	}
+	return int64(len(deleted))
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.CreateUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.users, func(u database.User) bool { return u.Email == arg.Email }) {
+		return database.CreateUserRow{}, conflict("users_email_key")
+	}
+	now := time.Now()
+	u := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		Role:           "user",
+	}
+	s.users = append(s.users, u)
+	return database.CreateUserRow{ID: u.ID, CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt, Email: u.Email, IsChirpyRed: u.IsChirpyRed}, nil
+}
+
+func (s *Store) UpdateUser(ctx context.Context, arg database.UpdateUserParams) (database.UpdateUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.users, func(u database.User) bool { return u.Email == arg.Email && u.ID != arg.ID }) {
+		return database.UpdateUserRow{}, conflict("users_email_key")
+	}
+	u, err := s.updateUser(arg.ID, func(u *database.User) {
+		u.Email = arg.Email
+		u.HashedPassword = arg.HashedPassword
+		u.UpdatedAt = time.Now()
+	})
+	if err != nil {
+		return database.UpdateUserRow{}, err
+	}
+	return database.UpdateUserRow{ID: u.ID, CreatedAt: u.CreatedAt, UpdatedAt: u.UpdatedAt, Email: u.Email, IsChirpyRed: u.IsChirpyRed}, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.users, func(u database.User) bool { return u.Email == email })
+	if i < 0 {
+		return database.User{}, notFound()
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.userIndex(id)
+	if i < 0 {
+		return database.User{}, notFound()
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUserAuthState(ctx context.Context, id uuid.UUID) (database.GetUserAuthStateRow, error) {
+	u, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return database.GetUserAuthStateRow{}, err
+	}
+	return database.GetUserAuthStateRow{
+		SuspendedAt:       u.SuspendedAt,
+		SessionsRevokedAt: u.SessionsRevokedAt,
+		DeletionDueAt:     u.DeletionDueAt,
+	}, nil
+}
+
+// SearchUsers matches emails containing the query literally, ignoring
+// case.
+func (s *Store) SearchUsers(ctx context.Context, arg database.SearchUsersParams) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matched []database.User
+	query := strings.ToLower(arg.Query)
+	for _, u := range s.users {
+		if strings.Contains(strings.ToLower(u.Email), query) {
+			matched = append(matched, u)
+		}
+	}
+	slices.SortStableFunc(matched, func(a, b database.User) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return page(matched, arg.Limit, arg.Offset), nil
+}
+
+func page[T any](rows []T, limit, offset int32) []T {
+	start := min(int(offset), len(rows))
+	end := min(start+int(limit), len(rows))
+	return rows[start:end]
+}
+
+func (s *Store) DeleteUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleteUsers(func(database.User) bool { return false })
+	return nil
+}
+
+func (s *Store) DeleteUsersPastGracePeriod(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	return s.deleteUsers(func(u database.User) bool {
+		return !u.DeletionDueAt.Valid || u.DeletionDueAt.Time.After(now)
+	}), nil
+}
+
+func (s *Store) ScheduleUserDeletion(ctx context.Context, arg database.ScheduleUserDeletionParams) (sql.NullTime, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := s.updateUser(arg.ID, func(u *database.User) {
+		u.DeletionDueAt = arg.DeletionDueAt
+		u.SessionsRevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		u.UpdatedAt = time.Now()
+	})
+	return u.DeletionDueAt, err
+}
+
+func (s *Store) CancelUserDeletion(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// :exec, so updating no rows isn't an error
+	s.updateUser(id, func(u *database.User) {
+		u.DeletionDueAt = sql.NullTime{}
+		u.UpdatedAt = time.Now()
+	})
+	return nil
+}
+
+func (s *Store) SetUserRole(ctx context.Context, arg database.SetUserRoleParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateUser(arg.ID, func(u *database.User) {
+		u.Role = arg.Role
+		u.UpdatedAt = time.Now()
+	})
+}
+
+func (s *Store) SuspendUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateUser(id, func(u *database.User) {
+		now := time.Now()
+		u.SuspendedAt = sql.NullTime{Time: now, Valid: true}
+		u.SessionsRevokedAt = sql.NullTime{Time: now, Valid: true}
+		u.UpdatedAt = now
+	})
+}
+
+func (s *Store) UnsuspendUser(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateUser(id, func(u *database.User) {
+		u.SuspendedAt = sql.NullTime{}
+		u.UpdatedAt = time.Now()
+	})
+}
+
+func (s *Store) RevokeUserSessions(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateUser(id, func(u *database.User) {
+		now := time.Now()
+		u.SessionsRevokedAt = sql.NullTime{Time: now, Valid: true}
+		u.UpdatedAt = now
+	})
+}
+
+func (s *Store) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkUser(arg.UserID, "chirps_user_id_fkey"); err != nil {
+		return database.Chirp{}, err
+	}
+	now := time.Now()
+	c := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Body:      arg.Body,
+		UserID:    arg.UserID,
+		PublishAt: now,
+		Published: !arg.PublishAt.Valid,
+	}
+	if arg.PublishAt.Valid {
+		c.PublishAt = arg.PublishAt.Time
+	}
+	s.chirps = append(s.chirps, c)
+	return c, nil
+}
+
+func (s *Store) ImportChirp(ctx context.Context, arg database.ImportChirpParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.chirps, func(c database.Chirp) bool { return c.ID == arg.ID }) {
+		return 0, nil
+	}
+	if err := s.checkUser(arg.UserID, "chirps_user_id_fkey"); err != nil {
+		return 0, err
+	}
+	c := database.Chirp(arg)
+	c.Published = c.Published || !c.PublishAt.After(time.Now())
+	s.chirps = append(s.chirps, c)
+	return 1, nil
+}
+
+// PublishDueChirps marks up to limit unpublished chirps whose publish time
+// has passed, earliest first.
+func (s *Store) PublishDueChirps(ctx context.Context, limit int32) ([]database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var due []*database.Chirp
+	for i := range s.chirps {
+		if c := &s.chirps[i]; !c.Published && !c.PublishAt.After(now) {
+			due = append(due, c)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *database.Chirp) int { return a.PublishAt.Compare(b.PublishAt) })
+	var published []database.Chirp
+	for _, c := range page(due, limit, 0) {
+		c.Published = true
+		published = append(published, *c)
+	}
+	return published, nil
+}
+
+func (s *Store) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.chirps, func(c database.Chirp) bool { return c.ID == id })
+	if i < 0 {
+		return database.Chirp{}, notFound()
+	}
+	return s.chirps[i], nil
+}
+
+// filterChirps returns the chirps match accepts, ordered by the given
+// timestamp.
+func (s *Store) filterChirps(match func(c database.Chirp) bool, orderBy func(c database.Chirp) time.Time) []database.Chirp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var chirps []database.Chirp
+	for _, c := range s.chirps {
+		if match(c) {
+			chirps = append(chirps, c)
+		}
+	}
+	slices.SortStableFunc(chirps, func(a, b database.Chirp) int { return orderBy(a).Compare(orderBy(b)) })
+	return chirps
+}
+
+func createdAt(c database.Chirp) time.Time { return c.CreatedAt }
+func publishAt(c database.Chirp) time.Time { return c.PublishAt }
+
+func (s *Store) GetAllChirps(ctx context.Context) ([]database.Chirp, error) {
+	now := time.Now()
+	return s.filterChirps(func(c database.Chirp) bool { return !c.PublishAt.After(now) }, createdAt), nil
+}
+
+func (s *Store) GetChirpsByAuthor(ctx context.Context, userID uuid.NullUUID) ([]database.Chirp, error) {
+	now := time.Now()
+	return s.filterChirps(func(c database.Chirp) bool {
+		return sameUser(c.UserID, userID) && !c.PublishAt.After(now)
+	}, createdAt), nil
+}
+
+func (s *Store) GetScheduledChirps(ctx context.Context, userID uuid.NullUUID) ([]database.Chirp, error) {
+	now := time.Now()
+	return s.filterChirps(func(c database.Chirp) bool {
+		return sameUser(c.UserID, userID) && c.PublishAt.After(now)
+	}, publishAt), nil
+}
+
+func (s *Store) ListAllChirps(ctx context.Context) ([]database.Chirp, error) {
+	return s.filterChirps(func(database.Chirp) bool { return true }, createdAt), nil
+}
+
+func (s *Store) UpdateChirp(ctx context.Context, arg database.UpdateChirpParams) (database.Chirp, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.chirps, func(c database.Chirp) bool { return c.ID == arg.ID && sameUser(c.UserID, arg.UserID) })
+	if i < 0 {
+		return database.Chirp{}, notFound()
+	}
+	s.chirps[i].Body = arg.Body
+	s.chirps[i].UpdatedAt = time.Now()
+	return s.chirps[i], nil
+}
+
+func (s *Store) DeleteChirp(ctx context.Context, arg database.DeleteChirpParams) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.chirps, func(c database.Chirp) bool { return c.ID == arg.ID && sameUser(c.UserID, arg.UserID) })
+	if i < 0 {
+		return uuid.UUID{}, notFound()
+	}
+	s.chirps = slices.Delete(s.chirps, i, i+1)
+	return arg.ID, nil
+}
+
+func (s *Store) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.ContainsFunc(s.refreshTokens, func(t database.RefreshToken) bool { return t.Token == arg.Token }) {
+		return database.RefreshToken{}, conflict("refresh_tokens_pkey")
+	}
+	if err := s.checkUser(arg.UserID, "refresh_tokens_user_id_fkey"); err != nil {
+		return database.RefreshToken{}, err
+	}
+	now := time.Now()
+	t := database.RefreshToken{
+		Token:     arg.Token,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ExpiresAt: arg.ExpiresAt,
+		UserID:    arg.UserID,
+	}
+	s.refreshTokens = append(s.refreshTokens, t)
+	return t, nil
+}
+
+func (s *Store) GetRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.refreshTokens, func(t database.RefreshToken) bool { return t.Token == token })
+	if i < 0 {
+		return database.RefreshToken{}, notFound()
+	}
+	return s.refreshTokens[i], nil
+}
+
+// revokeRefreshTokens revokes the tokens match accepts. Like the :exec
+// queries, revoking nothing isn't an error.
+func (s *Store) revokeRefreshTokens(match func(t database.RefreshToken) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for i, t := range s.refreshTokens {
+		if match(t) {
+			s.refreshTokens[i].RevokedAt = sql.NullTime{Time: now, Valid: true}
+			s.refreshTokens[i].UpdatedAt = now
+		}
+	}
+}
+
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string) error {
+	s.revokeRefreshTokens(func(t database.RefreshToken) bool { return t.Token == token })
+	return nil
+}
+
+func (s *Store) RevokeUserRefreshTokens(ctx context.Context, userID uuid.NullUUID) error {
+	s.revokeRefreshTokens(func(t database.RefreshToken) bool {
+		return sameUser(t.UserID, userID) && !t.RevokedAt.Valid
+	})
+	return nil
+}
+
+func (s *Store) ListUserRefreshTokens(ctx context.Context, userID uuid.NullUUID) ([]database.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var tokens []database.RefreshToken
+	for _, t := range s.refreshTokens {
+		if sameUser(t.UserID, userID) {
+			tokens = append(tokens, t)
+		}
+	}
+	slices.SortStableFunc(tokens, func(a, b database.RefreshToken) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return tokens, nil
+}
+
+func (s *Store) DeleteStaleRefreshTokens(ctx context.Context, expiresAt time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	before := len(s.refreshTokens)
+	s.refreshTokens = slices.DeleteFunc(s.refreshTokens, func(t database.RefreshToken) bool {
+		return t.ExpiresAt.Before(expiresAt) || (t.RevokedAt.Valid && t.RevokedAt.Time.Before(expiresAt))
+	})
+	return int64(before - len(s.refreshTokens)), nil
+}
+
+func (s *Store) CreateAuditLogEntry(ctx context.Context, arg database.CreateAuditLogEntryParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkUser(arg.ActorID, "admin_audit_log_actor_id_fkey"); err != nil {
+		return err
+	}
+	s.auditLog = append(s.auditLog, database.AdminAuditLog{
+		ID:         uuid.New(),
+		CreatedAt:  time.Now(),
+		ActorID:    arg.ActorID,
+		Method:     arg.Method,
+		Path:       arg.Path,
+		StatusCode: arg.StatusCode,
+	})
+	return nil
+}
+
+// ListAuditLog returns the newest entries first.
+func (s *Store) ListAuditLog(ctx context.Context, limit int32) ([]database.AdminAuditLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := slices.Clone(s.auditLog)
+	// reversed first, so entries written at the same time are newest first
+	slices.Reverse(entries)
+	slices.SortStableFunc(entries, func(a, b database.AdminAuditLog) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return page(entries, limit, 0), nil
+}
+
+func (s *Store) GetSubscription(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.subscriptions, func(sub database.Subscription) bool { return sub.UserID == userID })
+	if i < 0 {
+		return database.Subscription{}, notFound()
+	}
+	return s.subscriptions[i], nil
+}
+
+// upsertSubscription inserts or updates a user's subscription. When
+// fromEvent is set, a NULL period end keeps the existing one, and an event
+// older than the last one applied leaves the row alone and reports no row.
+func (s *Store) upsertSubscription(userID uuid.UUID, status string, periodEnd, eventAt sql.NullTime, fromEvent bool) (database.Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	i := slices.IndexFunc(s.subscriptions, func(sub database.Subscription) bool { return sub.UserID == userID })
+	if i >= 0 {
+		sub := &s.subscriptions[i]
+		if fromEvent && eventAt.Valid && sub.LastEventAt.Valid && eventAt.Time.Before(sub.LastEventAt.Time) {
+			return database.Subscription{}, notFound()
+		}
+		sub.Status = status
+		if periodEnd.Valid || !fromEvent {
+			sub.CurrentPeriodEnd = periodEnd
+		}
+		if eventAt.Valid {
+			sub.LastEventAt = eventAt
+		}
+		sub.UpdatedAt = now
+		return *sub, nil
+	}
+	if err := s.checkUser(uuid.NullUUID{UUID: userID, Valid: true}, "subscriptions_user_id_fkey"); err != nil {
+		return database.Subscription{}, err
+	}
+	sub := database.Subscription{
+		UserID:           userID,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		Status:           status,
+		CurrentPeriodEnd: periodEnd,
+		LastEventAt:      eventAt,
+	}
+	s.subscriptions = append(s.subscriptions, sub)
+	return sub, nil
+}
+
+func (s *Store) SetSubscription(ctx context.Context, arg database.SetSubscriptionParams) (database.Subscription, error) {
+	return s.upsertSubscription(arg.UserID, arg.Status, arg.CurrentPeriodEnd, sql.NullTime{}, false)
+}
+
+func (s *Store) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (database.Subscription, error) {
+	return s.upsertSubscription(arg.UserID, arg.Status, arg.CurrentPeriodEnd, arg.LastEventAt, true)
+}
+
+// entitled is the subscription check SyncChirpyRed and
+// ExpireLapsedChirpyRed share: active until its period ends, or past due
+// or canceled with time left in the period.
+func (s *Store) entitled(userID uuid.UUID, now time.Time) bool {
+	return slices.ContainsFunc(s.subscriptions, func(sub database.Subscription) bool {
+		if sub.UserID != userID {
+			return false
+		}
+		inPeriod := sub.CurrentPeriodEnd.Valid && sub.CurrentPeriodEnd.Time.After(now)
+		switch sub.Status {
+		case "active":
+			return !sub.CurrentPeriodEnd.Valid || inPeriod
+		case "past_due", "canceled":
+			return inPeriod
+		}
+		return false
+	})
+}
+
+func (s *Store) SyncChirpyRed(ctx context.Context, id uuid.UUID) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entitled := s.entitled(id, time.Now())
+	u, err := s.updateUser(id, func(u *database.User) { u.IsChirpyRed = entitled })
+	return u.IsChirpyRed, err
+}
+
+func (s *Store) ExpireLapsedChirpyRed(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var expired int64
+	for i, u := range s.users {
+		if u.IsChirpyRed && !s.entitled(u.ID, now) {
+			s.users[i].IsChirpyRed = false
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+var _ store.Store = (*Store)(nil)
@@ -0,0 +1,458 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/store"
+)
+
+func createUser(t *testing.T, s *Store, email string) uuid.NullUUID {
+	t.Helper()
+	u, err := s.CreateUser(context.Background(), database.CreateUserParams{Email: email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uuid.NullUUID{UUID: u.ID, Valid: true}
+}
+
+func TestUniqueEmail(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+	createUser(t, s, "bob@example.com")
+
+	_, err := s.CreateUser(ctx, database.CreateUserParams{Email: "alice@example.com"})
+	if !errors.Is(err, store.ErrConflict) || store.Constraint(err) != "users_email_key" {
+		t.Errorf("duplicate CreateUser: %v", err)
+	}
+	_, err = s.UpdateUser(ctx, database.UpdateUserParams{ID: alice.UUID, Email: "bob@example.com"})
+	if !errors.Is(err, store.ErrConflict) {
+		t.Errorf("UpdateUser to a taken email: %v", err)
+	}
+	// keeping your own email isn't a conflict
+	if _, err := s.UpdateUser(ctx, database.UpdateUserParams{ID: alice.UUID, Email: "alice@example.com"}); err != nil {
+		t.Errorf("UpdateUser keeping the email: %v", err)
+	}
+
+	_, err = s.GetUserByEmail(ctx, "carol@example.com")
+	if !errors.Is(err, store.ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUserByEmail of a missing user: %v", err)
+	}
+}
+
+func TestChirpOrdering(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	later := sql.NullTime{Time: time.Now().Add(2 * time.Hour), Valid: true}
+	sooner := sql.NullTime{Time: time.Now().Add(time.Hour), Valid: true}
+	var bodies []string
+	for _, arg := range []database.CreateChirpParams{
+		{Body: "first", UserID: alice},
+		{Body: "later", UserID: alice, PublishAt: later},
+		{Body: "second", UserID: bob},
+		{Body: "sooner", UserID: alice, PublishAt: sooner},
+		{Body: "third", UserID: alice},
+	} {
+		if _, err := s.CreateChirp(ctx, arg); err != nil {
+			t.Fatal(err)
+		}
+		bodies = append(bodies, arg.Body)
+	}
+
+	check := func(name string, chirps []database.Chirp, want ...string) {
+		t.Helper()
+		var got []string
+		for _, c := range chirps {
+			got = append(got, c.Body)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s = %q, want %q", name, got, want)
+			return
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s = %q, want %q", name, got, want)
+				return
+			}
+		}
+	}
+	all, _ := s.GetAllChirps(ctx)
+	check("GetAllChirps", all, "first", "second", "third")
+	byAlice, _ := s.GetChirpsByAuthor(ctx, alice)
+	check("GetChirpsByAuthor", byAlice, "first", "third")
+	scheduled, _ := s.GetScheduledChirps(ctx, alice)
+	check("GetScheduledChirps", scheduled, "sooner", "later")
+	listed, _ := s.ListAllChirps(ctx)
+	check("ListAllChirps", listed, bodies...)
+	none, _ := s.GetChirpsByAuthor(ctx, uuid.NullUUID{})
+	check("GetChirpsByAuthor(NULL)", none)
+}
+
+func TestChirpOwnership(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	chirp, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hello", UserID: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.UpdateChirp(ctx, database.UpdateChirpParams{ID: chirp.ID, UserID: bob, Body: "mine now"})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpdateChirp by another user: %v", err)
+	}
+	_, err = s.DeleteChirp(ctx, database.DeleteChirpParams{ID: chirp.ID, UserID: bob})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("DeleteChirp by another user: %v", err)
+	}
+
+	updated, err := s.UpdateChirp(ctx, database.UpdateChirpParams{ID: chirp.ID, UserID: alice, Body: "edited"})
+	if err != nil || updated.Body != "edited" {
+		t.Errorf("UpdateChirp = %q, %v", updated.Body, err)
+	}
+	if id, err := s.DeleteChirp(ctx, database.DeleteChirpParams{ID: chirp.ID, UserID: alice}); err != nil || id != chirp.ID {
+		t.Errorf("DeleteChirp = %v, %v", id, err)
+	}
+	if _, err := s.GetChirp(ctx, chirp.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetChirp after delete: %v", err)
+	}
+
+	missing := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	_, err = s.CreateChirp(ctx, database.CreateChirpParams{Body: "orphan", UserID: missing})
+	if !errors.Is(err, store.ErrConflict) {
+		t.Errorf("CreateChirp for a missing user: %v", err)
+	}
+
+	n, err := s.ImportChirp(ctx, database.ImportChirpParams(updated))
+	if err != nil || n != 1 {
+		t.Errorf("ImportChirp = %d, %v; want 1", n, err)
+	}
+	if n, _ := s.ImportChirp(ctx, database.ImportChirpParams(updated)); n != 0 {
+		t.Errorf("ImportChirp of an existing ID = %d, want 0", n)
+	}
+}
+
+func TestDeleteUserCascades(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	for _, user := range []uuid.NullUUID{alice, bob} {
+		if _, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "hi", UserID: user}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+			Token:     user.UUID.String(),
+			ExpiresAt: time.Now().Add(time.Hour),
+			UserID:    user,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.SetSubscription(ctx, database.SetSubscriptionParams{UserID: alice.UUID, Status: "active"})
+	s.CreateAPIKey(ctx, database.CreateAPIKeyParams{UserID: alice.UUID, Prefix: "alice"})
+	s.CreateOAuthClient(ctx, database.CreateOAuthClientParams{ID: "alice-app", UserID: alice.UUID})
+	s.UpsertOAuthConsent(ctx, database.UpsertOAuthConsentParams{UserID: bob.UUID, ClientID: "alice-app"})
+	endpoint, _ := s.CreateWebhookEndpoint(ctx, database.CreateWebhookEndpointParams{UserID: alice, Events: "chirp.created"})
+	s.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{UserID: alice.UUID, Event: "chirp.created"})
+	s.CreateAuditLogEntry(ctx, database.CreateAuditLogEntryParams{ActorID: alice, Method: "POST", Path: "/admin/reset", StatusCode: 200})
+
+	_, err := s.ScheduleUserDeletion(ctx, database.ScheduleUserDeletionParams{
+		ID:            alice.UUID,
+		DeletionDueAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.DeleteUsersPastGracePeriod(ctx); err != nil || n != 1 {
+		t.Fatalf("DeleteUsersPastGracePeriod = %d, %v; want 1", n, err)
+	}
+
+	if chirps, _ := s.ListAllChirps(ctx); len(chirps) != 1 || chirps[0].UserID != bob {
+		t.Errorf("chirps after delete: %+v", chirps)
+	}
+	if _, err := s.GetRefreshToken(ctx, alice.UUID.String()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("refresh token survived its user: %v", err)
+	}
+	if _, err := s.GetSubscription(ctx, alice.UUID); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("subscription survived its user: %v", err)
+	}
+	if _, err := s.GetAPIKeyByPrefix(ctx, "alice"); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("API key survived its user: %v", err)
+	}
+	// bob's consent goes with the client alice owned
+	if _, err := s.GetOAuthConsent(ctx, database.GetOAuthConsentParams{UserID: bob.UUID, ClientID: "alice-app"}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("OAuth consent survived its client: %v", err)
+	}
+	if deliveries, _ := s.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{EndpointID: endpoint.ID, Limit: 10}); len(deliveries) != 0 {
+		t.Errorf("webhook deliveries survived their endpoint: %+v", deliveries)
+	}
+	entries, _ := s.ListAuditLog(ctx, 10)
+	if len(entries) != 1 || entries[0].ActorID.Valid {
+		t.Errorf("audit log actor not set to NULL: %+v", entries)
+	}
+
+	if err := s.DeleteUsers(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetRefreshToken(ctx, bob.UUID.String()); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("refresh token survived DeleteUsers: %v", err)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+	now := time.Now()
+	for _, token := range []string{"a", "b", "c"} {
+		_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: token, ExpiresAt: now.Add(time.Hour), UserID: alice})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := s.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{Token: "a", ExpiresAt: now, UserID: alice})
+	if !errors.Is(err, store.ErrConflict) {
+		t.Errorf("duplicate token: %v", err)
+	}
+
+	s.RevokeRefreshToken(ctx, "a")
+	first, _ := s.GetRefreshToken(ctx, "a")
+	s.RevokeUserRefreshTokens(ctx, alice)
+	tokens, _ := s.ListUserRefreshTokens(ctx, alice)
+	if len(tokens) != 3 || tokens[0].Token != "a" || tokens[2].Token != "c" {
+		t.Fatalf("ListUserRefreshTokens = %+v", tokens)
+	}
+	for _, token := range tokens {
+		if !token.RevokedAt.Valid {
+			t.Errorf("token %s not revoked", token.Token)
+		}
+	}
+	if !tokens[0].RevokedAt.Time.Equal(first.RevokedAt.Time) {
+		t.Error("RevokeUserRefreshTokens changed an already revoked token")
+	}
+
+	if n, _ := s.DeleteStaleRefreshTokens(ctx, now.Add(-time.Minute)); n != 0 {
+		t.Errorf("DeleteStaleRefreshTokens before any revocation deleted %d", n)
+	}
+	if n, _ := s.DeleteStaleRefreshTokens(ctx, time.Now().Add(time.Minute)); n != 3 {
+		t.Errorf("DeleteStaleRefreshTokens deleted %d, want 3", n)
+	}
+}
+
+func TestAuditLogNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	for _, path := range []string{"/admin/a", "/admin/b", "/admin/c"} {
+		if err := s.CreateAuditLogEntry(ctx, database.CreateAuditLogEntryParams{Method: "POST", Path: path, StatusCode: 200}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, _ := s.ListAuditLog(ctx, 2)
+	if len(entries) != 2 || entries[0].Path != "/admin/c" || entries[1].Path != "/admin/b" {
+		t.Errorf("ListAuditLog = %+v", entries)
+	}
+}
+
+func TestChirpyRed(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+
+	s.SetSubscription(ctx, database.SetSubscriptionParams{UserID: alice.UUID, Status: "active"})
+	if red, err := s.SyncChirpyRed(ctx, alice.UUID); err != nil || !red {
+		t.Errorf("SyncChirpyRed with an open-ended subscription = %v, %v", red, err)
+	}
+
+	lapsed := sql.NullTime{Time: time.Now().Add(-time.Hour), Valid: true}
+	s.UpsertSubscription(ctx, database.UpsertSubscriptionParams{UserID: alice.UUID, Status: "canceled", CurrentPeriodEnd: lapsed})
+	if n, _ := s.ExpireLapsedChirpyRed(ctx); n != 1 {
+		t.Errorf("ExpireLapsedChirpyRed = %d, want 1", n)
+	}
+
+	// Upsert keeps the period end when given NULL; Set clears it
+	sub, _ := s.UpsertSubscription(ctx, database.UpsertSubscriptionParams{UserID: alice.UUID, Status: "past_due"})
+	if !sub.CurrentPeriodEnd.Valid {
+		t.Error("UpsertSubscription cleared the period end")
+	}
+	sub, _ = s.SetSubscription(ctx, database.SetSubscriptionParams{UserID: alice.UUID, Status: "active"})
+	if sub.CurrentPeriodEnd.Valid {
+		t.Error("SetSubscription kept the period end")
+	}
+
+	_, err := s.SetSubscription(ctx, database.SetSubscriptionParams{UserID: uuid.New(), Status: "active"})
+	if !errors.Is(err, store.ErrConflict) {
+		t.Errorf("SetSubscription for a missing user: %v", err)
+	}
+}
+
+func TestPublishDueChirps(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+	at := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: time.Now().Add(d), Valid: true} }
+
+	now, _ := s.CreateChirp(ctx, database.CreateChirpParams{Body: "now", UserID: alice})
+	if !now.Published {
+		t.Error("unscheduled chirp not published")
+	}
+	s.CreateChirp(ctx, database.CreateChirpParams{Body: "second", UserID: alice, PublishAt: at(-time.Minute)})
+	s.CreateChirp(ctx, database.CreateChirpParams{Body: "first", UserID: alice, PublishAt: at(-time.Hour)})
+	s.CreateChirp(ctx, database.CreateChirpParams{Body: "later", UserID: alice, PublishAt: at(time.Hour)})
+
+	published, _ := s.PublishDueChirps(ctx, 10)
+	var bodies []string
+	for _, c := range published {
+		bodies = append(bodies, c.Body)
+	}
+	if got := strings.Join(bodies, ","); got != "first,second" {
+		t.Errorf("PublishDueChirps = %q, want first,second", got)
+	}
+	if again, _ := s.PublishDueChirps(ctx, 10); len(again) != 0 {
+		t.Errorf("PublishDueChirps published %d chirps twice", len(again))
+	}
+}
+
+func TestUpsertSubscriptionOrdering(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+	now := time.Now()
+	at := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: now.Add(d), Valid: true} }
+
+	s.UpsertSubscription(ctx, database.UpsertSubscriptionParams{UserID: alice.UUID, Status: "canceled", LastEventAt: at(0)})
+	_, err := s.UpsertSubscription(ctx, database.UpsertSubscriptionParams{UserID: alice.UUID, Status: "active", LastEventAt: at(-time.Minute)})
+	if !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("UpsertSubscription with an older event: %v", err)
+	}
+	sub, err := s.UpsertSubscription(ctx, database.UpsertSubscriptionParams{UserID: alice.UUID, Status: "active", LastEventAt: at(time.Minute)})
+	if err != nil || sub.Status != "active" || !sub.LastEventAt.Time.Equal(now.Add(time.Minute)) {
+		t.Errorf("UpsertSubscription with a newer event = %+v, %v", sub, err)
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	endpoint := func(user uuid.NullUUID, events string) database.WebhookEndpoint {
+		t.Helper()
+		e, err := s.CreateWebhookEndpoint(ctx, database.CreateWebhookEndpointParams{UserID: user, Url: "https://example.com", Events: events})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	own := endpoint(alice, "chirp.created chirp.deleted")
+	admin := endpoint(uuid.NullUUID{}, "chirp.created")
+	endpoint(alice, "chirp.deleted")
+	endpoint(bob, "chirp.created")
+	disabled := endpoint(alice, "chirp.created")
+	if at, err := s.RecordWebhookEndpointFailure(ctx, database.RecordWebhookEndpointFailureParams{ID: disabled.ID, ConsecutiveFailures: 1}); err != nil || !at.Valid {
+		t.Fatalf("RecordWebhookEndpointFailure = %v, %v; want disabled", at, err)
+	}
+
+	n, err := s.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{UserID: alice.UUID, Event: "chirp.created"})
+	if err != nil || n != 2 {
+		t.Fatalf("EnqueueWebhookDeliveries = %d, %v; want 2", n, err)
+	}
+	claimed, err := s.ClaimWebhookDeliveries(ctx, 10)
+	if err != nil || len(claimed) != 2 || claimed[0].EndpointID != own.ID || claimed[1].EndpointID != admin.ID {
+		t.Fatalf("ClaimWebhookDeliveries = %+v, %v", claimed, err)
+	}
+	if claimed[0].Attempts != 1 || claimed[0].Url != own.Url {
+		t.Errorf("claimed delivery = %+v", claimed[0])
+	}
+	// claimed deliveries aren't due again until the lease runs out
+	if again, _ := s.ClaimWebhookDeliveries(ctx, 10); len(again) != 0 {
+		t.Errorf("deliveries claimed twice: %+v", again)
+	}
+
+	s.MarkWebhookDeliverySucceeded(ctx, database.MarkWebhookDeliverySucceededParams{ID: claimed[0].ID, LastStatusCode: 204})
+	deliveries, _ := s.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{EndpointID: own.ID, Limit: 10})
+	if len(deliveries) != 1 || deliveries[0].Status != "succeeded" || !deliveries[0].DeliveredAt.Valid {
+		t.Errorf("deliveries after success: %+v", deliveries)
+	}
+
+	// only the owner can see an endpoint; the admin's have no owner
+	if _, err := s.GetWebhookEndpoint(ctx, database.GetWebhookEndpointParams{ID: own.ID, UserID: bob}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("GetWebhookEndpoint by another user: %v", err)
+	}
+	if _, err := s.GetWebhookEndpoint(ctx, database.GetWebhookEndpointParams{ID: admin.ID}); err != nil {
+		t.Errorf("GetWebhookEndpoint of an admin endpoint: %v", err)
+	}
+	enabled, err := s.EnableWebhookEndpoint(ctx, database.EnableWebhookEndpointParams{ID: disabled.ID, UserID: alice})
+	if err != nil || enabled.DisabledAt.Valid || enabled.ConsecutiveFailures != 0 {
+		t.Errorf("EnableWebhookEndpoint = %+v, %v", enabled, err)
+	}
+}
+
+func TestWebhookEventReplay(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	arg := database.RecordWebhookEventParams{Source: "polka", ID: "evt-1", Event: "user.upgraded", Payload: []byte("{}")}
+	s.RecordWebhookEvent(ctx, arg)
+	event, err := s.RecordWebhookEvent(ctx, arg)
+	if err != nil || event.Deliveries != 2 || event.Status != "pending" {
+		t.Fatalf("redelivered RecordWebhookEvent = %+v, %v", event, err)
+	}
+	claimed, _ := s.ClaimWebhookEvents(ctx, 10)
+	if len(claimed) != 1 || claimed[0].Attempts != 1 || claimed[0].Status != "processing" {
+		t.Fatalf("ClaimWebhookEvents = %+v", claimed)
+	}
+
+	replay := database.ReplayWebhookEventParams{Source: "polka", ID: "evt-1"}
+	if _, err := s.ReplayWebhookEvent(ctx, replay); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("ReplayWebhookEvent of a live event: %v", err)
+	}
+	s.DeadLetterWebhookEvent(ctx, database.DeadLetterWebhookEventParams{Source: "polka", ID: "evt-1", LastError: sql.NullString{String: "boom", Valid: true}})
+	if dead, _ := s.ListWebhookEventsByStatus(ctx, database.ListWebhookEventsByStatusParams{Status: "dead", Limit: 10}); len(dead) != 1 {
+		t.Errorf("dead events: %+v", dead)
+	}
+	event, err = s.ReplayWebhookEvent(ctx, replay)
+	if err != nil || event.Status != "pending" || event.Attempts != 0 || event.LastError.Valid {
+		t.Errorf("ReplayWebhookEvent = %+v, %v", event, err)
+	}
+}
+
+func TestUpsertPageViewMergesVisitors(t *testing.T) {
+	ctx := context.Background()
+	s := New()
+	day := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	upsert := func(path string, visitors ...byte) {
+		t.Helper()
+		if err := s.UpsertPageView(ctx, database.UpsertPageViewParams{Path: path, Day: day, Views: 1, Visitors: visitors}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	upsert("/b", 1, 5, 0)
+	upsert("/b", 3, 2, 0)
+	upsert("/a", 1)
+
+	views, _ := s.ListPageViews(ctx, day)
+	if len(views) != 2 || views[0].Path != "/a" {
+		t.Fatalf("ListPageViews = %+v", views)
+	}
+	if b := views[1]; b.Views != 2 || string(b.Visitors) != string([]byte{3, 5, 0}) {
+		t.Errorf("merged page view = %+v", b)
+	}
+	// a sketch of another length replaces the stored one
+	upsert("/b", 9)
+	views, _ = s.ListPageViewsByPath(ctx, database.ListPageViewsByPathParams{Day: day, Path: "/b"})
+	if len(views) != 1 || views[0].Views != 3 || string(views[0].Visitors) != string([]byte{9}) {
+		t.Errorf("replaced page view = %+v", views)
+	}
+}
@@ -0,0 +1,154 @@
+package memstore
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+)
+
+// UpsertPageView adds to a page's views for the day and merges the visitor
+// sketches register by register. A sketch of a different length replaces
+// the stored one.
+func (s *Store) UpsertPageView(ctx context.Context, arg database.UpsertPageViewParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.pageViews, func(v database.PageView) bool {
+		return v.Path == arg.Path && v.Day.Equal(arg.Day)
+	})
+	if i < 0 {
+		s.pageViews = append(s.pageViews, database.PageView(arg))
+		return nil
+	}
+	v := &s.pageViews[i]
+	v.Views += arg.Views
+	if len(v.Visitors) != len(arg.Visitors) {
+		v.Visitors = arg.Visitors
+		return nil
+	}
+	merged := make([]byte, len(arg.Visitors))
+	for j := range merged {
+		merged[j] = max(v.Visitors[j], arg.Visitors[j])
+	}
+	v.Visitors = merged
+	return nil
+}
+
+func (s *Store) GetOrCreateVisitorSalt(ctx context.Context, salt []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.visitorSalt == nil {
+		s.visitorSalt = salt
+	}
+	return s.visitorSalt, nil
+}
+
+// listPageViews returns the rows from day on that match accepts, by day
+// and then path.
+func (s *Store) listPageViews(day time.Time, match func(v database.PageView) bool) []database.PageView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var views []database.PageView
+	for _, v := range s.pageViews {
+		if !v.Day.Before(day) && match(v) {
+			views = append(views, v)
+		}
+	}
+	slices.SortFunc(views, func(a, b database.PageView) int {
+		return cmp.Or(a.Day.Compare(b.Day), strings.Compare(a.Path, b.Path))
+	})
+	return views
+}
+
+func (s *Store) ListPageViews(ctx context.Context, day time.Time) ([]database.PageView, error) {
+	return s.listPageViews(day, func(database.PageView) bool { return true }), nil
+}
+
+func (s *Store) ListPageViewsByPath(ctx context.Context, arg database.ListPageViewsByPathParams) ([]database.PageView, error) {
+	return s.listPageViews(arg.Day, func(v database.PageView) bool { return v.Path == arg.Path }), nil
+}
+
+func (s *Store) CountUsers(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.users)), nil
+}
+
+func (s *Store) CountChirpyRedUsers(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, u := range s.users {
+		if u.IsChirpyRed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *Store) CountChirps(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int64(len(s.chirps)), nil
+}
+
+func (s *Store) CountActiveSessions(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var n int64
+	for _, t := range s.refreshTokens {
+		if !t.RevokedAt.Valid && t.ExpiresAt.After(now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// CountChirpsPerDay counts the chirps created since createdAt by the day
+// they were created, earliest first.
+func (s *Store) CountChirpsPerDay(ctx context.Context, createdAt time.Time) ([]database.CountChirpsPerDayRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var rows []database.CountChirpsPerDayRow
+	for _, c := range s.chirps {
+		if c.CreatedAt.Before(createdAt) {
+			continue
+		}
+		y, m, d := c.CreatedAt.Date()
+		day := time.Date(y, m, d, 0, 0, 0, 0, c.CreatedAt.Location())
+		i := slices.IndexFunc(rows, func(r database.CountChirpsPerDayRow) bool { return r.Day.Equal(day) })
+		if i < 0 {
+			rows = append(rows, database.CountChirpsPerDayRow{Day: day})
+			i = len(rows) - 1
+		}
+		rows[i].Count++
+	}
+	slices.SortFunc(rows, func(a, b database.CountChirpsPerDayRow) int { return a.Day.Compare(b.Day) })
+	return rows, nil
+}
+
+// TopAuthors returns the users with the most chirps. Ties come back in the
+// order the users were created.
+func (s *Store) TopAuthors(ctx context.Context, limit int32) ([]database.TopAuthorsRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[uuid.UUID]int64{}
+	for _, c := range s.chirps {
+		if c.UserID.Valid {
+			counts[c.UserID.UUID]++
+		}
+	}
+	var rows []database.TopAuthorsRow
+	for _, u := range s.users {
+		if n := counts[u.ID]; n > 0 {
+			rows = append(rows, database.TopAuthorsRow{ID: u.ID, Email: u.Email, ChirpCount: n})
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b database.TopAuthorsRow) int { return cmp.Compare(b.ChirpCount, a.ChirpCount) })
+	return page(rows, limit, 0), nil
+}
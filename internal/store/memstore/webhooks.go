@@ -0,0 +1,376 @@
+package memstore
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mattcollier/boot-go-server/internal/database"
+)
+
+// claimLease is how long a claimed delivery or event stays claimed, as in
+// the claim queries.
+const claimLease = 5 * time.Minute
+
+// deleteWebhookEndpoints deletes the endpoints match accepts along with
+// their deliveries.
+func (s *Store) deleteWebhookEndpoints(match func(e database.WebhookEndpoint) bool) {
+	deleted := map[uuid.UUID]bool{}
+	s.webhookEndpoints = slices.DeleteFunc(s.webhookEndpoints, func(e database.WebhookEndpoint) bool {
+		if match(e) {
+			deleted[e.ID] = true
+			return true
+		}
+		return false
+	})
+	s.webhookDeliveries = slices.DeleteFunc(s.webhookDeliveries, func(d database.WebhookDelivery) bool { return deleted[d.EndpointID] })
+}
+
+// endpointIndex finds an endpoint by id and owner. Owner is IS NOT
+// DISTINCT FROM, so a NULL owner matches the admin endpoints.
+func (s *Store) endpointIndex(id uuid.UUID, userID uuid.NullUUID) int {
+	return slices.IndexFunc(s.webhookEndpoints, func(e database.WebhookEndpoint) bool {
+		return e.ID == id && e.UserID == userID
+	})
+}
+
+func (s *Store) CreateWebhookEndpoint(ctx context.Context, arg database.CreateWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkUser(arg.UserID, "webhook_endpoints_user_id_fkey"); err != nil {
+		return database.WebhookEndpoint{}, err
+	}
+	now := time.Now()
+	e := database.WebhookEndpoint{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		UserID:    arg.UserID,
+		Url:       arg.Url,
+		Secret:    arg.Secret,
+		Events:    arg.Events,
+	}
+	s.webhookEndpoints = append(s.webhookEndpoints, e)
+	return e, nil
+}
+
+func (s *Store) ListWebhookEndpoints(ctx context.Context, userID uuid.NullUUID) ([]database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ownedBy(s.webhookEndpoints, func(e database.WebhookEndpoint) bool { return e.UserID == userID },
+		func(e database.WebhookEndpoint) time.Time { return e.CreatedAt }), nil
+}
+
+func (s *Store) GetWebhookEndpoint(ctx context.Context, arg database.GetWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.endpointIndex(arg.ID, arg.UserID)
+	if i < 0 {
+		return database.WebhookEndpoint{}, notFound()
+	}
+	return s.webhookEndpoints[i], nil
+}
+
+func (s *Store) DeleteWebhookEndpoint(ctx context.Context, arg database.DeleteWebhookEndpointParams) (uuid.UUID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.endpointIndex(arg.ID, arg.UserID) < 0 {
+		return uuid.UUID{}, notFound()
+	}
+	s.deleteWebhookEndpoints(func(e database.WebhookEndpoint) bool { return e.ID == arg.ID })
+	return arg.ID, nil
+}
+
+func (s *Store) EnableWebhookEndpoint(ctx context.Context, arg database.EnableWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.endpointIndex(arg.ID, arg.UserID)
+	if i < 0 {
+		return database.WebhookEndpoint{}, notFound()
+	}
+	e := &s.webhookEndpoints[i]
+	e.DisabledAt = sql.NullTime{}
+	e.ConsecutiveFailures = 0
+	e.UpdatedAt = time.Now()
+	return *e, nil
+}
+
+func (s *Store) RecordWebhookEndpointSuccess(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.IndexFunc(s.webhookEndpoints, func(e database.WebhookEndpoint) bool { return e.ID == id }); i >= 0 {
+		s.webhookEndpoints[i].ConsecutiveFailures = 0
+	}
+	return nil
+}
+
+// RecordWebhookEndpointFailure counts a failure and disables the endpoint
+// once arg.ConsecutiveFailures are reached.
+func (s *Store) RecordWebhookEndpointFailure(ctx context.Context, arg database.RecordWebhookEndpointFailureParams) (sql.NullTime, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.webhookEndpoints, func(e database.WebhookEndpoint) bool { return e.ID == arg.ID })
+	if i < 0 {
+		return sql.NullTime{}, notFound()
+	}
+	e := &s.webhookEndpoints[i]
+	now := time.Now()
+	e.ConsecutiveFailures++
+	if e.ConsecutiveFailures >= arg.ConsecutiveFailures {
+		e.DisabledAt = sql.NullTime{Time: now, Valid: true}
+	}
+	e.UpdatedAt = now
+	return e.DisabledAt, nil
+}
+
+// EnqueueWebhookDeliveries queues a delivery for every enabled endpoint
+// subscribed to the event: the user's own and the admin endpoints.
+func (s *Store) EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var queued int64
+	for _, e := range s.webhookEndpoints {
+		if e.DisabledAt.Valid || (e.UserID.Valid && e.UserID.UUID != arg.UserID) {
+			continue
+		}
+		if !slices.Contains(strings.Split(e.Events, " "), arg.Event) {
+			continue
+		}
+		s.webhookDeliveries = append(s.webhookDeliveries, database.WebhookDelivery{
+			ID:            uuid.New(),
+			CreatedAt:     now,
+			EndpointID:    e.ID,
+			Event:         arg.Event,
+			Payload:       arg.Payload,
+			Status:        "pending",
+			NextAttemptAt: now,
+		})
+		queued++
+	}
+	return queued, nil
+}
+
+// ClaimWebhookDeliveries claims up to limit due deliveries to enabled
+// endpoints, oldest first.
+func (s *Store) ClaimWebhookDeliveries(ctx context.Context, limit int32) ([]database.ClaimWebhookDeliveriesRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	endpoints := map[uuid.UUID]database.WebhookEndpoint{}
+	for _, e := range s.webhookEndpoints {
+		endpoints[e.ID] = e
+	}
+	var due []*database.WebhookDelivery
+	for i := range s.webhookDeliveries {
+		d := &s.webhookDeliveries[i]
+		if (d.Status == "pending" || d.Status == "processing") && !d.NextAttemptAt.After(now) &&
+			!endpoints[d.EndpointID].DisabledAt.Valid {
+			due = append(due, d)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *database.WebhookDelivery) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	var claimed []database.ClaimWebhookDeliveriesRow
+	for _, d := range page(due, limit, 0) {
+		d.Status = "processing"
+		d.Attempts++
+		d.NextAttemptAt = now.Add(claimLease)
+		e := endpoints[d.EndpointID]
+		claimed = append(claimed, database.ClaimWebhookDeliveriesRow{
+			ID:         d.ID,
+			EndpointID: d.EndpointID,
+			Event:      d.Event,
+			Payload:    d.Payload,
+			Attempts:   d.Attempts,
+			Url:        e.Url,
+			Secret:     e.Secret,
+		})
+	}
+	return claimed, nil
+}
+
+// updateDelivery applies fn to a delivery. Like the :exec queries,
+// updating nothing isn't an error.
+func (s *Store) updateDelivery(id uuid.UUID, fn func(d *database.WebhookDelivery)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.IndexFunc(s.webhookDeliveries, func(d database.WebhookDelivery) bool { return d.ID == id }); i >= 0 {
+		fn(&s.webhookDeliveries[i])
+	}
+}
+
+func (s *Store) MarkWebhookDeliverySucceeded(ctx context.Context, arg database.MarkWebhookDeliverySucceededParams) error {
+	s.updateDelivery(arg.ID, func(d *database.WebhookDelivery) {
+		d.Status = "succeeded"
+		d.DeliveredAt = sql.NullTime{Time: time.Now(), Valid: true}
+		d.LastStatusCode = arg.LastStatusCode
+		d.LastError = sql.NullString{}
+		d.LastResponse = arg.LastResponse
+	})
+	return nil
+}
+
+func (s *Store) RetryWebhookDelivery(ctx context.Context, arg database.RetryWebhookDeliveryParams) error {
+	s.updateDelivery(arg.ID, func(d *database.WebhookDelivery) {
+		d.Status = "pending"
+		d.NextAttemptAt = arg.NextAttemptAt
+		d.LastStatusCode = arg.LastStatusCode
+		d.LastError = arg.LastError
+		d.LastResponse = arg.LastResponse
+	})
+	return nil
+}
+
+func (s *Store) FailWebhookDelivery(ctx context.Context, arg database.FailWebhookDeliveryParams) error {
+	s.updateDelivery(arg.ID, func(d *database.WebhookDelivery) {
+		d.Status = "failed"
+		d.LastStatusCode = arg.LastStatusCode
+		d.LastError = arg.LastError
+		d.LastResponse = arg.LastResponse
+	})
+	return nil
+}
+
+// ListWebhookDeliveries returns an endpoint's newest deliveries first.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var deliveries []database.WebhookDelivery
+	for _, d := range slices.Backward(s.webhookDeliveries) {
+		if d.EndpointID == arg.EndpointID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	slices.SortStableFunc(deliveries, func(a, b database.WebhookDelivery) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return page(deliveries, arg.Limit, 0), nil
+}
+
+func (s *Store) eventIndex(source, id string) int {
+	return slices.IndexFunc(s.webhookEvents, func(e database.WebhookEvent) bool { return e.Source == source && e.ID == id })
+}
+
+// RecordWebhookEvent stores an event, or counts a redelivery of one
+// already stored.
+func (s *Store) RecordWebhookEvent(ctx context.Context, arg database.RecordWebhookEventParams) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.eventIndex(arg.Source, arg.ID); i >= 0 {
+		s.webhookEvents[i].Deliveries++
+		return s.webhookEvents[i], nil
+	}
+	now := time.Now()
+	e := database.WebhookEvent{
+		Source:        arg.Source,
+		ID:            arg.ID,
+		ReceivedAt:    now,
+		Event:         arg.Event,
+		Deliveries:    1,
+		Payload:       arg.Payload,
+		Status:        "pending",
+		NextAttemptAt: now,
+		SentAt:        arg.SentAt,
+	}
+	s.webhookEvents = append(s.webhookEvents, e)
+	return e, nil
+}
+
+// ClaimWebhookEvents claims up to limit due events, oldest first.
+func (s *Store) ClaimWebhookEvents(ctx context.Context, limit int32) ([]database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var due []*database.WebhookEvent
+	for i := range s.webhookEvents {
+		e := &s.webhookEvents[i]
+		if (e.Status == "pending" || e.Status == "processing") && !e.NextAttemptAt.After(now) {
+			due = append(due, e)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *database.WebhookEvent) int { return a.ReceivedAt.Compare(b.ReceivedAt) })
+	var claimed []database.WebhookEvent
+	for _, e := range page(due, limit, 0) {
+		e.Status = "processing"
+		e.Attempts++
+		e.NextAttemptAt = now.Add(claimLease)
+		claimed = append(claimed, *e)
+	}
+	return claimed, nil
+}
+
+// updateEvent applies fn to an event. Like the :exec queries, updating
+// nothing isn't an error.
+func (s *Store) updateEvent(source, id string, fn func(e *database.WebhookEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := s.eventIndex(source, id); i >= 0 {
+		fn(&s.webhookEvents[i])
+	}
+}
+
+func (s *Store) MarkWebhookEventProcessed(ctx context.Context, arg database.MarkWebhookEventProcessedParams) error {
+	s.updateEvent(arg.Source, arg.ID, func(e *database.WebhookEvent) {
+		e.Status = "processed"
+		e.ProcessedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		e.LastError = sql.NullString{}
+	})
+	return nil
+}
+
+func (s *Store) RetryWebhookEvent(ctx context.Context, arg database.RetryWebhookEventParams) error {
+	s.updateEvent(arg.Source, arg.ID, func(e *database.WebhookEvent) {
+		e.Status = "pending"
+		e.NextAttemptAt = arg.NextAttemptAt
+		e.LastError = arg.LastError
+	})
+	return nil
+}
+
+func (s *Store) DeadLetterWebhookEvent(ctx context.Context, arg database.DeadLetterWebhookEventParams) error {
+	s.updateEvent(arg.Source, arg.ID, func(e *database.WebhookEvent) {
+		e.Status = "dead"
+		e.LastError = arg.LastError
+	})
+	return nil
+}
+
+// newestEvents returns the events match accepts, most recently received
+// first.
+func (s *Store) newestEvents(match func(e database.WebhookEvent) bool, limit int32) []database.WebhookEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var events []database.WebhookEvent
+	for _, e := range slices.Backward(s.webhookEvents) {
+		if match(e) {
+			events = append(events, e)
+		}
+	}
+	slices.SortStableFunc(events, func(a, b database.WebhookEvent) int { return b.ReceivedAt.Compare(a.ReceivedAt) })
+	return page(events, limit, 0)
+}
+
+func (s *Store) ListWebhookEventsByStatus(ctx context.Context, arg database.ListWebhookEventsByStatusParams) ([]database.WebhookEvent, error) {
+	return s.newestEvents(func(e database.WebhookEvent) bool { return e.Status == arg.Status }, arg.Limit), nil
+}
+
+func (s *Store) ListRecentWebhookEvents(ctx context.Context, limit int32) ([]database.WebhookEvent, error) {
+	return s.newestEvents(func(database.WebhookEvent) bool { return true }, limit), nil
+}
+
+// ReplayWebhookEvent queues a dead event to be processed again.
+func (s *Store) ReplayWebhookEvent(ctx context.Context, arg database.ReplayWebhookEventParams) (database.WebhookEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.eventIndex(arg.Source, arg.ID)
+	if i < 0 || s.webhookEvents[i].Status != "dead" {
+		return database.WebhookEvent{}, notFound()
+	}
+	e := &s.webhookEvents[i]
+	e.Status = "pending"
+	e.Attempts = 0
+	e.NextAttemptAt = time.Now()
+	e.LastError = sql.NullString{}
+	return *e, nil
+}
@@ -0,0 +1,92 @@
+// Package store is the repository the server uses for every query. Store
+// is the interface handlers depend on. Postgres implements it by wrapping
+// the sqlc generated database.Queries and translating driver errors into
+// ErrNotFound and ErrConflict, so callers don't depend on database/sql or
+// lib/pq error details; other implementations must return the same errors.
+package store
+
+//go:generate go run gen.go
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/mattcollier/boot-go-server/internal/database"
+)
+
+var (
+	// ErrNotFound means a query expecting a row found none.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means a write broke a unique or foreign key constraint,
+	// such as registering an email that's taken or referencing a row that
+	// was just deleted.
+	ErrConflict = errors.New("conflict")
+)
+
+// Postgres error codes; see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// Error is a translated database error. It matches both its Kind and the
+// original error with errors.Is and errors.As.
+type Error struct {
+	Kind error
+	// the violated constraint, for conflicts
+	Constraint string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Constraint != "" {
+		return e.Kind.Error() + " (" + e.Constraint + "): " + e.Err.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// Constraint returns the constraint a conflict broke, or "".
+func Constraint(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Constraint
+	}
+	return ""
+}
+
+func translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &Error{Kind: ErrNotFound, Err: err}
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case uniqueViolation, foreignKeyViolation:
+			return &Error{Kind: ErrConflict, Constraint: pqErr.Constraint, Err: err}
+		}
+	}
+	return err
+}
+
+// Postgres runs queries with sqlc, translating their errors.
+type Postgres struct {
+	q *database.Queries
+}
+
+func NewPostgres(db database.DBTX) *Postgres {
+	return &Postgres{q: database.New(db)}
+}
+
+// WithTx returns a Postgres that runs its queries in tx.
+func (p *Postgres) WithTx(tx *sql.Tx) *Postgres {
+	return &Postgres{q: p.q.WithTx(tx)}
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestTranslate(t *testing.T) {
+	if translate(nil) != nil {
+		t.Error("translate(nil) != nil")
+	}
+
+	err := translate(fmt.Errorf("scan: %w", sql.ErrNoRows))
+	if !errors.Is(err, ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("no rows: %v", err)
+	}
+
+	err = translate(&pq.Error{Code: uniqueViolation, Constraint: "users_email_key"})
+	if !errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+		t.Errorf("unique violation: %v", err)
+	}
+	if Constraint(err) != "users_email_key" {
+		t.Errorf("Constraint() = %q", Constraint(err))
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		t.Error("driver error not reachable with errors.As")
+	}
+
+	err = translate(&pq.Error{Code: foreignKeyViolation})
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("foreign key violation: %v", err)
+	}
+
+	other := &pq.Error{Code: "57014"}
+	if err := translate(other); err != other {
+		t.Errorf("other errors changed: %v", err)
+	}
+	if Constraint(other) != "" {
+		t.Error("Constraint() set for an untranslated error")
+	}
+}
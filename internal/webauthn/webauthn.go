@@ -0,0 +1,280 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers Chirpy accepts for passkeys.
+const (
+	AlgES256 = -7
+	AlgRS256 = -257
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for a passkey.
+const minRSAKeyBits = 2048
+
+const (
+	flagUserPresent        = 0x01
+	flagAttestedCredential = 0x40
+)
+
+var (
+	ErrChallengeMismatch = errors.New("webauthn: challenge mismatch")
+	ErrOriginMismatch    = errors.New("webauthn: origin mismatch")
+	ErrRPIDMismatch      = errors.New("webauthn: relying party id mismatch")
+	ErrUserNotPresent    = errors.New("webauthn: user presence flag not set")
+	ErrBadSignature      = errors.New("webauthn: invalid signature")
+	// ErrSignCount means the authenticator's counter went backwards, which
+	// indicates a cloned authenticator.
+	ErrSignCount = errors.New("webauthn: sign count did not increase")
+)
+
+// RelyingParty identifies this server to authenticators.
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// Credential is a registered passkey.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte // COSE_Key encoding
+	SignCount uint32
+	AAGUID    []byte
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	// only present during registration
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+// NewChallenge returns a random challenge in the base64url form browsers
+// echo back in clientDataJSON.
+func NewChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// VerifyRegistration checks the response to a navigator.credentials.create()
+// call and returns the new credential. Only the "none" attestation format is
+// accepted; Chirpy does not restrict which authenticators may be used.
+func (rp RelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return Credential{}, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return Credential{}, err
+	}
+	attestation, ok := decoded.(map[any]any)
+	if !ok {
+		return Credential{}, errors.New("webauthn: attestation object is not a map")
+	}
+	if format, _ := attestation["fmt"].(string); format != "none" {
+		return Credential{}, fmt.Errorf("webauthn: unsupported attestation format %q", format)
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return Credential{}, errors.New("webauthn: attestation object is missing authData")
+	}
+
+	authData, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if authData.flags&flagAttestedCredential == 0 {
+		return Credential{}, errors.New("webauthn: no attested credential data")
+	}
+	if _, err := parsePublicKey(authData.publicKey); err != nil {
+		return Credential{}, err
+	}
+
+	return Credential{
+		ID:        authData.credentialID,
+		PublicKey: authData.publicKey,
+		SignCount: authData.signCount,
+		AAGUID:    authData.aaguid,
+	}, nil
+}
+
+// VerifyAssertion checks the response to a navigator.credentials.get() call
+// against a stored credential and returns the authenticator's new sign count.
+func (rp RelyingParty) VerifyAssertion(cred Credential, challenge string, clientDataJSON, rawAuthData, signature []byte) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := rp.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return 0, err
+	}
+
+	publicKey, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	digest := sha256.Sum256(signed)
+
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return 0, ErrBadSignature
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) != nil {
+			return 0, ErrBadSignature
+		}
+	}
+
+	// authenticators that do not implement counters always report zero
+	if (authData.signCount != 0 || cred.SignCount != 0) && authData.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+
+	return authData.signCount, nil
+}
+
+func (rp RelyingParty) verifyClientData(clientDataJSON []byte, ceremony, challenge string) error {
+	cd := clientData{}
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("webauthn: client data type = %q, want %q", cd.Type, ceremony)
+	}
+	if cd.Challenge != challenge {
+		return ErrChallengeMismatch
+	}
+	if cd.Origin != rp.Origin {
+		return ErrOriginMismatch
+	}
+	return nil
+}
+
+func (rp RelyingParty) parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	if len(data) < 37 {
+		return authenticatorData{}, errors.New("webauthn: authenticator data too short")
+	}
+	ad := authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.rpIDHash, rpIDHash[:]) {
+		return authenticatorData{}, ErrRPIDMismatch
+	}
+	if ad.flags&flagUserPresent == 0 {
+		return authenticatorData{}, ErrUserNotPresent
+	}
+
+	if ad.flags&flagAttestedCredential != 0 {
+		rest := data[37:]
+		if len(rest) < 18 {
+			return authenticatorData{}, errors.New("webauthn: attested credential data too short")
+		}
+		ad.aaguid = rest[:16]
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if len(rest) < idLen {
+			return authenticatorData{}, errors.New("webauthn: credential id too short")
+		}
+		ad.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+
+		// the key is followed by optional extension data, so measure it
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return authenticatorData{}, err
+		}
+		ad.publicKey = rest[:len(rest)-len(after)]
+	}
+
+	return ad, nil
+}
+
+// parsePublicKey decodes a COSE_Key into an ECDSA P-256 or RSA public key.
+func parsePublicKey(coseKey []byte) (crypto.PublicKey, error) {
+	decoded, _, err := decodeCBOR(coseKey)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, errors.New("webauthn: public key is not a map")
+	}
+
+	alg, _ := key[int64(3)].(int64)
+	switch alg {
+	case AlgES256:
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv, _ := key[int64(-1)].(int64); crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("webauthn: invalid P-256 key")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		pub, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), point)
+		if err != nil {
+			return nil, fmt.Errorf("webauthn: invalid P-256 key: %w", err)
+		}
+		return pub, nil
+	case AlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("webauthn: invalid RSA key")
+		}
+		pub := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("webauthn: RSA key of %d bits is too small", pub.N.BitLen())
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("webauthn: unsupported algorithm %d", alg)
+}
+
+// ClientDataChallenge returns the challenge echoed in clientDataJSON so the
+// server can find the ceremony a response belongs to. The response must
+// still be verified with VerifyRegistration or VerifyAssertion.
+func ClientDataChallenge(clientDataJSON []byte) (string, error) {
+	cd := clientData{}
+	if err := json.Unmarshal(clientDataJSON, &cd); err != nil {
+		return "", fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	return cd.Challenge, nil
+}
@@ -0,0 +1,192 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/mattcollier/boot-go-server/internal/webauthn/webauthntest"
+)
+
+var testRP = RelyingParty{ID: "localhost", Name: "Chirpy", Origin: "http://localhost:8080"}
+
+func register(t *testing.T, a *webauthntest.Authenticator) Credential {
+	t.Helper()
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge returned error: %v", err)
+	}
+	clientDataJSON, attestationObject := a.Create(challenge)
+
+	cred, err := testRP.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		t.Fatalf("VerifyRegistration returned error: %v", err)
+	}
+	return cred
+}
+
+func newAuthenticator(t *testing.T, rpID, origin string) *webauthntest.Authenticator {
+	t.Helper()
+	a, err := webauthntest.New(rpID, origin)
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+	return a
+}
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	a := newAuthenticator(t, testRP.ID, testRP.Origin)
+	cred := register(t, a)
+
+	if string(cred.ID) != string(a.CredentialID) {
+		t.Fatalf("credential id = %x, want %x", cred.ID, a.CredentialID)
+	}
+
+	for i := 1; i <= 2; i++ {
+		challenge, _ := NewChallenge()
+		clientDataJSON, authData, sig := a.Get(challenge)
+		count, err := testRP.VerifyAssertion(cred, challenge, clientDataJSON, authData, sig)
+		if err != nil {
+			t.Fatalf("VerifyAssertion #%d returned error: %v", i, err)
+		}
+		if count != uint32(i) {
+			t.Fatalf("sign count = %d, want %d", count, i)
+		}
+		cred.SignCount = count
+	}
+}
+
+func TestVerifyRegistration_WrongChallenge(t *testing.T) {
+	a := newAuthenticator(t, testRP.ID, testRP.Origin)
+	clientDataJSON, attestationObject := a.Create("some-other-challenge")
+
+	_, err := testRP.VerifyRegistration("expected", clientDataJSON, attestationObject)
+	if !errors.Is(err, ErrChallengeMismatch) {
+		t.Fatalf("expected ErrChallengeMismatch, got %v", err)
+	}
+}
+
+func TestVerifyRegistration_WrongOrigin(t *testing.T) {
+	a := newAuthenticator(t, testRP.ID, "https://evil.example")
+	challenge, _ := NewChallenge()
+	clientDataJSON, attestationObject := a.Create(challenge)
+
+	_, err := testRP.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if !errors.Is(err, ErrOriginMismatch) {
+		t.Fatalf("expected ErrOriginMismatch, got %v", err)
+	}
+}
+
+func TestVerifyRegistration_WrongRPID(t *testing.T) {
+	a := newAuthenticator(t, "evil.example", testRP.Origin)
+	challenge, _ := NewChallenge()
+	clientDataJSON, attestationObject := a.Create(challenge)
+
+	_, err := testRP.VerifyRegistration(challenge, clientDataJSON, attestationObject)
+	if !errors.Is(err, ErrRPIDMismatch) {
+		t.Fatalf("expected ErrRPIDMismatch, got %v", err)
+	}
+}
+
+func TestVerifyAssertion_ClonedAuthenticator(t *testing.T) {
+	a := newAuthenticator(t, testRP.ID, testRP.Origin)
+	cred := register(t, a)
+	cred.SignCount = 5
+
+	// the authenticator reports 1, behind what the server has seen
+	challenge, _ := NewChallenge()
+	clientDataJSON, authData, sig := a.Get(challenge)
+	_, err := testRP.VerifyAssertion(cred, challenge, clientDataJSON, authData, sig)
+	if !errors.Is(err, ErrSignCount) {
+		t.Fatalf("expected ErrSignCount, got %v", err)
+	}
+}
+
+func TestVerifyAssertion_TamperedClientData(t *testing.T) {
+	a := newAuthenticator(t, testRP.ID, testRP.Origin)
+	cred := register(t, a)
+
+	challenge, _ := NewChallenge()
+	_, authData, sig := a.Get(challenge)
+	other := []byte(`{"type":"webauthn.get","challenge":"` + challenge + `","origin":"http://localhost:8080","extra":1}`)
+
+	_, err := testRP.VerifyAssertion(cred, challenge, other, authData, sig)
+	if !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature, got %v", err)
+	}
+}
+
+func TestVerifyAssertion_OtherCredentialKey(t *testing.T) {
+	a := newAuthenticator(t, testRP.ID, testRP.Origin)
+	cred := register(t, a)
+	imposter := newAuthenticator(t, testRP.ID, testRP.Origin)
+
+	challenge, _ := NewChallenge()
+	clientDataJSON, authData, sig := imposter.Get(challenge)
+	_, err := testRP.VerifyAssertion(cred, challenge, clientDataJSON, authData, sig)
+	if !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected ErrBadSignature, got %v", err)
+	}
+}
+
+// rsaCOSEKey encodes an RS256 COSE_Key with modulus n and exponent 65537.
+func rsaCOSEKey(n []byte) []byte {
+	b := []byte{0xa4, 0x01, 0x03, 0x03, 0x39, 0x01, 0x00, 0x20, 0x59}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(n)))
+	b = append(b, n...)
+	return append(b, 0x21, 0x43, 0x01, 0x00, 0x01)
+}
+
+func TestParsePublicKey_RSAKeySize(t *testing.T) {
+	for bits, ok := range map[int]bool{1024: false, 2048: true} {
+		n := bytes.Repeat([]byte{0xff}, bits/8)
+		_, err := parsePublicKey(rsaCOSEKey(n))
+		if ok && err != nil {
+			t.Errorf("%d bit key: %v", bits, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("%d bit key accepted", bits)
+		}
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// {1: 2, "a": [-1, h'0102', true]}
+	data := []byte{0xa2, 0x01, 0x02, 0x61, 'a', 0x83, 0x20, 0x42, 0x01, 0x02, 0xf5, 0xff}
+
+	v, rest, err := decodeCBOR(data)
+	if err != nil {
+		t.Fatalf("decodeCBOR returned error: %v", err)
+	}
+	if len(rest) != 1 || rest[0] != 0xff {
+		t.Fatalf("rest = %x, want ff", rest)
+	}
+	m := v.(map[any]any)
+	if m[int64(1)] != int64(2) {
+		t.Errorf("m[1] = %v, want 2", m[int64(1)])
+	}
+	arr := m["a"].([]any)
+	if arr[0] != int64(-1) || string(arr[1].([]byte)) != "\x01\x02" || arr[2] != true {
+		t.Errorf("unexpected array %v", arr)
+	}
+}
+
+func TestDecodeCBOR_Truncated(t *testing.T) {
+	if _, _, err := decodeCBOR([]byte{0x58, 0x10, 0x01}); err == nil {
+		t.Fatalf("expected error for truncated byte string, got nil")
+	}
+}
+
+func TestDecodeCBOR_Depth(t *testing.T) {
+	// maxCBORDepth single-element arrays around a zero decode fine
+	nested := append(bytes.Repeat([]byte{0x81}, maxCBORDepth), 0x00)
+	if _, _, err := decodeCBOR(nested); err != nil {
+		t.Fatalf("decodeCBOR returned error for %d levels: %v", maxCBORDepth, err)
+	}
+
+	deep := append(bytes.Repeat([]byte{0x81}, 100000), 0x00)
+	if _, _, err := decodeCBOR(deep); err == nil {
+		t.Fatalf("expected error for deeply nested arrays, got nil")
+	}
+}
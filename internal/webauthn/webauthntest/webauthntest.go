@@ -0,0 +1,136 @@
+// Package webauthntest provides a software authenticator for exercising
+// passkey ceremonies in tests.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+)
+
+// Authenticator is a software passkey holding a single P-256 credential.
+type Authenticator struct {
+	RPID         string
+	Origin       string
+	CredentialID []byte
+	SignCount    uint32
+
+	key *ecdsa.PrivateKey
+}
+
+func New(rpID, origin string) (*Authenticator, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Authenticator{RPID: rpID, Origin: origin, CredentialID: id, key: key}, nil
+}
+
+// Create answers a registration challenge with clientDataJSON and a "none"
+// attestation object.
+func (a *Authenticator) Create(challenge string) (clientDataJSON, attestationObject []byte) {
+	clientDataJSON = a.clientData("webauthn.create", challenge)
+
+	authData := a.authData(0x41) // user present, attested credential data
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, a.coseKey()...)
+
+	var obj []byte
+	obj = appendHead(obj, 5, 3)
+	obj = appendText(obj, "fmt")
+	obj = appendText(obj, "none")
+	obj = appendText(obj, "attStmt")
+	obj = appendHead(obj, 5, 0)
+	obj = appendText(obj, "authData")
+	obj = appendBytes(obj, authData)
+	return clientDataJSON, obj
+}
+
+// Get answers an authentication challenge, bumping the sign counter.
+func (a *Authenticator) Get(challenge string) (clientDataJSON, authenticatorData, signature []byte) {
+	a.SignCount++
+	clientDataJSON = a.clientData("webauthn.get", challenge)
+	authenticatorData = a.authData(0x05) // user present and verified
+	return clientDataJSON, authenticatorData, a.Sign(authenticatorData, clientDataJSON)
+}
+
+// Sign produces an assertion signature over authenticatorData and
+// clientDataJSON. Tests use it to build tampered assertions.
+func (a *Authenticator) Sign(authenticatorData, clientDataJSON []byte) []byte {
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authenticatorData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return sig
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) []byte {
+	b, _ := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    a.Origin,
+	})
+	return b
+}
+
+func (a *Authenticator) authData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+	b := append(rpIDHash[:], flags)
+	return binary.BigEndian.AppendUint32(b, a.SignCount)
+}
+
+func (a *Authenticator) coseKey() []byte {
+	point, _ := a.key.PublicKey.Bytes() // 0x04 || X || Y
+	var b []byte
+	b = appendHead(b, 5, 5)
+	b = appendInt(b, 1) // kty
+	b = appendInt(b, 2) // EC2
+	b = appendInt(b, 3) // alg
+	b = appendInt(b, -7)
+	b = appendInt(b, -1) // crv
+	b = appendInt(b, 1)  // P-256
+	b = appendInt(b, -2) // x
+	b = appendBytes(b, point[1:33])
+	b = appendInt(b, -3) // y
+	b = appendBytes(b, point[33:])
+	return b
+}
+
+func appendHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, major<<5|byte(n))
+	case n <= 0xff:
+		return append(b, major<<5|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major<<5|25), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(b, major<<5|26), uint32(n))
+	}
+}
+
+func appendInt(b []byte, n int64) []byte {
+	if n < 0 {
+		return appendHead(b, 1, uint64(-1-n))
+	}
+	return appendHead(b, 0, uint64(n))
+}
+
+func appendBytes(b, v []byte) []byte {
+	return append(appendHead(b, 2, uint64(len(v))), v...)
+}
+
+func appendText(b []byte, s string) []byte {
+	return append(appendHead(b, 3, uint64(len(s))), s...)
+}
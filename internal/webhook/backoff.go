@@ -0,0 +1,19 @@
+package webhook
+
+import "time"
+
+// Backoff returns how long to wait before retry number attempt (starting at
+// 1): base doubled for every earlier attempt, capped at max.
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	delay := base
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= max {
+			return max
+		}
+	}
+	return min(delay, max)
+}
@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxResponseBytes is how much of a receiver's response body is kept for
+// delivery logs.
+const maxResponseBytes = 1 << 10
+
+// Sender posts signed events to receivers. Headers are named with Prefix,
+// e.g. "Chirpy-Signature", "Chirpy-Timestamp", "Chirpy-Event" and
+// "Chirpy-Delivery".
+type Sender struct {
+	Client *http.Client
+	Prefix string
+}
+
+// Delivery is a single event sent to a single receiver.
+type Delivery struct {
+	ID      string
+	URL     string
+	Secret  []byte
+	Event   string
+	Payload []byte
+}
+
+// Result describes a receiver's response. StatusCode is zero when no
+// response was received. Body holds at most maxResponseBytes of the
+// response and is stored with the delivery.
+type Result struct {
+	StatusCode int
+	Body       string
+}
+
+// Send posts d and returns an error unless the receiver answers with a 2xx
+// status.
+func (s Sender) Send(ctx context.Context, d Delivery, now time.Time) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(s.Prefix+"-Timestamp", strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(s.Prefix+"-Signature", Sign(d.Secret, now, d.Payload))
+	req.Header.Set(s.Prefix+"-Event", d.Event)
+	req.Header.Set(s.Prefix+"-Delivery", d.ID)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	result := Result{StatusCode: resp.StatusCode, Body: string(body)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return result, fmt.Errorf("webhook: receiver responded %s", resp.Status)
+	}
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendSignsPayload(t *testing.T) {
+	secret := []byte("endpoint-secret")
+	payload := []byte(`{"type":"chirp.created"}`)
+
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	s := Sender{Client: srv.Client(), Prefix: "Chirpy"}
+	result, err := s.Send(context.Background(), Delivery{
+		ID:      "delivery-1",
+		URL:     srv.URL,
+		Secret:  secret,
+		Event:   "chirp.created",
+		Payload: payload,
+	}, now)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if result.StatusCode != http.StatusNoContent {
+		t.Errorf("StatusCode = %d, want %d", result.StatusCode, http.StatusNoContent)
+	}
+
+	if string(gotBody) != string(payload) {
+		t.Errorf("body = %q, want %q", gotBody, payload)
+	}
+	if got.Header.Get("Chirpy-Event") != "chirp.created" || got.Header.Get("Chirpy-Delivery") != "delivery-1" {
+		t.Errorf("unexpected headers: %v", got.Header)
+	}
+	err = Verify([][]byte{secret}, got.Header.Get("Chirpy-Timestamp"), got.Header.Get("Chirpy-Signature"), gotBody, now, time.Minute)
+	if err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestSendFailsOnNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(strings.Repeat("x", 2*maxResponseBytes)))
+	}))
+	defer srv.Close()
+
+	s := Sender{Client: srv.Client(), Prefix: "Chirpy"}
+	result, err := s.Send(context.Background(), Delivery{URL: srv.URL, Secret: []byte("s")}, time.Now())
+	if err == nil {
+		t.Fatal("Send() error = nil, want error")
+	}
+	if result.StatusCode != http.StatusInternalServerError {
+		t.Errorf("StatusCode = %d, want %d", result.StatusCode, http.StatusInternalServerError)
+	}
+	if len(result.Body) != maxResponseBytes {
+		t.Errorf("len(Body) = %d, want %d", len(result.Body), maxResponseBytes)
+	}
+}
+
+func TestSendConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	s := Sender{Prefix: "Chirpy"}
+	result, err := s.Send(context.Background(), Delivery{URL: url, Secret: []byte("s")}, time.Now())
+	if err == nil {
+		t.Fatal("Send() error = nil, want error")
+	}
+	if result.StatusCode != 0 {
+		t.Errorf("StatusCode = %d, want 0", result.StatusCode)
+	}
+}
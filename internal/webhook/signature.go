@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// signatures are sent as "v1=<hex>", comma separated when the sender signs
+// with more than one secret during a rotation
+const signatureVersion = "v1"
+
+var (
+	ErrMissingSignature = errors.New("webhook: missing signature or timestamp")
+	ErrInvalidTimestamp = errors.New("webhook: invalid timestamp")
+	ErrStaleTimestamp   = errors.New("webhook: timestamp outside tolerance")
+	ErrInvalidSignature = errors.New("webhook: no valid signature")
+)
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret []byte, timestamp time.Time, body []byte) string {
+	return signatureVersion + "=" + hex.EncodeToString(mac(secret, timestamp.Unix(), body))
+}
+
+// Verify checks a request signed with Sign. The timestamp must be within
+// tolerance of now, and any signature in signatureHeader may match any of
+// secrets so senders and receivers can rotate secrets independently.
+func Verify(secrets [][]byte, timestampHeader, signatureHeader string, body []byte, now time.Time, tolerance time.Duration) error {
+	if timestampHeader == "" || signatureHeader == "" {
+		return ErrMissingSignature
+	}
+
+	sentAt, err := ParseTimestamp(timestampHeader)
+	if err != nil {
+		return err
+	}
+	if d := now.Sub(sentAt); d > tolerance || d < -tolerance {
+		return ErrStaleTimestamp
+	}
+	ts := sentAt.Unix()
+
+	for _, part := range strings.Split(signatureHeader, ",") {
+		version, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || version != signatureVersion {
+			continue
+		}
+		given, err := hex.DecodeString(value)
+		if err != nil {
+			continue
+		}
+		for _, secret := range secrets {
+			if hmac.Equal(given, mac(secret, ts, body)) {
+				return nil
+			}
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// ParseTimestamp parses the timestamp header sent alongside a signature.
+func ParseTimestamp(timestampHeader string) (time.Time, error) {
+	ts, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+	return time.Unix(ts, 0), nil
+}
+
+func mac(secret []byte, timestamp int64, body []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
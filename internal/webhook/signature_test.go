@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerify_Success(t *testing.T) {
+	secret := []byte("current")
+	now := time.Now()
+	body := []byte(`{"event":"user.upgraded"}`)
+
+	sig := Sign(secret, now, body)
+	err := Verify([][]byte{secret}, strconv.FormatInt(now.Unix(), 10), sig, body, now, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+}
+
+func TestVerify_RotatedSecret(t *testing.T) {
+	previous := []byte("previous")
+	now := time.Now()
+	body := []byte(`{}`)
+
+	// sender still signs with the old secret
+	sig := Sign(previous, now, body)
+	err := Verify([][]byte{[]byte("current"), previous}, strconv.FormatInt(now.Unix(), 10), sig, body, now, time.Minute)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+}
+
+func TestVerify_MultipleSignatures(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{}`)
+
+	header := Sign([]byte("unknown"), now, body) + ", " + Sign([]byte("current"), now, body)
+	err := Verify([][]byte{[]byte("current")}, strconv.FormatInt(now.Unix(), 10), header, body, now, time.Minute)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+}
+
+func TestVerify_TamperedBody(t *testing.T) {
+	secret := []byte("current")
+	now := time.Now()
+
+	sig := Sign(secret, now, []byte(`{"event":"user.upgraded"}`))
+	err := Verify([][]byte{secret}, strconv.FormatInt(now.Unix(), 10), sig, []byte(`{"event":"user.downgraded"}`), now, time.Minute)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestVerify_StaleTimestamp(t *testing.T) {
+	secret := []byte("current")
+	sent := time.Now().Add(-10 * time.Minute)
+	body := []byte(`{}`)
+
+	sig := Sign(secret, sent, body)
+	err := Verify([][]byte{secret}, strconv.FormatInt(sent.Unix(), 10), sig, body, time.Now(), 5*time.Minute)
+	if !errors.Is(err, ErrStaleTimestamp) {
+		t.Fatalf("expected ErrStaleTimestamp, got %v", err)
+	}
+}
+
+func TestVerify_TimestampNotSigned(t *testing.T) {
+	secret := []byte("current")
+	now := time.Now()
+	body := []byte(`{}`)
+
+	// a replayed body with a fresh timestamp must not verify
+	sig := Sign(secret, now.Add(-time.Hour), body)
+	err := Verify([][]byte{secret}, strconv.FormatInt(now.Unix(), 10), sig, body, now, 5*time.Minute)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature, got %v", err)
+	}
+}
+
+func TestVerify_MissingHeaders(t *testing.T) {
+	err := Verify([][]byte{[]byte("s")}, "", "", nil, time.Now(), time.Minute)
+	if !errors.Is(err, ErrMissingSignature) {
+		t.Fatalf("expected ErrMissingSignature, got %v", err)
+	}
+}
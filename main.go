@@ -1,91 +1,287 @@
 package main
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
-	"github.com/mattcollier/boot-go-server/internal/database"
+	"github.com/mattcollier/boot-go-server/internal/analytics"
+	"github.com/mattcollier/boot-go-server/internal/config"
+	"github.com/mattcollier/boot-go-server/internal/entitlements"
+	"github.com/mattcollier/boot-go-server/internal/health"
+	"github.com/mattcollier/boot-go-server/internal/logging"
+	"github.com/mattcollier/boot-go-server/internal/metrics"
+	"github.com/mattcollier/boot-go-server/internal/oidc"
+	"github.com/mattcollier/boot-go-server/internal/store"
+	"github.com/mattcollier/boot-go-server/internal/tracing"
+	"github.com/mattcollier/boot-go-server/internal/webauthn"
+	"github.com/mattcollier/boot-go-server/internal/webhook"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	db             *database.Queries
-	platform       string
-	jwtSecret      string
-	polkaAPIKey    string
+	fileserverHits  atomic.Int32
+	db              store.Store
+	platform        string
+	jwtSecret       string
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	entitlements    entitlements.Catalog
+	// any of these may sign Polka webhooks; two are active during rotation
+	polkaWebhookSecrets [][]byte
+	oidcProviders       map[string]*oidc.Provider
+	webauthnRP          webauthn.RelyingParty
+	webhookSender       webhook.Sender
+	pageViews           *analytics.Counter
+	visitorSalt         []byte
+	// proxies whose X-Forwarded-For is believed when identifying clients
+	trustedProxies []netip.Prefix
 }
 
 func main() {
 	godotenv.Load()
-	const filepathRoot = "."
-	const port = "8080"
-	dbURL := os.Getenv("DB_URL")
+	if err := run(os.Args[1:]); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
 
-	jwtSecret := os.Getenv("JWT_TOKEN_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("'JWT_TOKEN_SECRET' env must be set")
+// serve runs the HTTP server and background workers until SIGINT or
+// SIGTERM.
+func serve(args []string) error {
+	conf, err := config.Load(args, os.Getenv)
+	if err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	logLevel, err := logging.ParseLevel(conf.LogLevel)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logging.New(os.Stderr, logLevel, conf.LogFormat))
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: "chirpy",
+		Exporter:    conf.TracingExporter,
+		Endpoint:    conf.TracingOTLPEndpoint,
+		SampleRatio: conf.TracingSampleRatio,
+		Stdout:      os.Stdout,
+	})
+	if err != nil {
+		return err
 	}
 
-	platform := os.Getenv("PLATFORM")
-	if platform == "" {
-		log.Fatal("'PLATFORM' env must be set")
+	var polkaWebhookSecrets [][]byte
+	for _, secret := range conf.PolkaWebhookSecrets {
+		polkaWebhookSecrets = append(polkaWebhookSecrets, []byte(secret))
 	}
 
-	polkaAPIKey := os.Getenv("POLKA_KEY")
-	if polkaAPIKey == "" {
-		log.Fatal("'POLKA_KEY' env must be set")
+	oidcProviders := newOIDCProviders(conf.OIDCProviders)
+
+	// passkeys are bound to the site they were created on
+	webauthnRP := webauthn.RelyingParty{
+		ID:     conf.WebAuthnRPID,
+		Name:   "Chirpy",
+		Origin: conf.WebAuthnRPOrigin,
 	}
 
-	db, err := sql.Open("postgres", dbURL)
+	db, err := openDatabase(conf.DBURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := newMigrator(db)
 	if err != nil {
-		log.Fatal("database connection error")
+		return err
+	}
+	if err := prepareSchema(context.Background(), migrator, conf.AutoMigrate); err != nil {
+		return err
+	}
+	metricsRegistry := metrics.NewRegistry()
+	dbQueries := store.NewPostgres(newInstrumentedDB(db, metricsRegistry))
+	visitorSalt, err := loadVisitorSalt(context.Background(), dbQueries, conf.AnalyticsVisitorSalt)
+	if err != nil {
+		return fmt.Errorf("loading visitor salt: %w", err)
 	}
-	dbQueries := database.New(db)
 
 	api := apiConfig{
-		db:          dbQueries,
-		platform:    platform,
-		jwtSecret:   jwtSecret,
-		polkaAPIKey: polkaAPIKey,
+		db:                  dbQueries,
+		platform:            conf.Platform,
+		jwtSecret:           conf.JWTSecret,
+		accessTokenTTL:      conf.AccessTokenTTL,
+		refreshTokenTTL:     conf.RefreshTokenTTL,
+		entitlements:        entitlements.NewCatalog(conf.MaxChirpLength, conf.ChirpyRedMaxChirpLength),
+		polkaWebhookSecrets: polkaWebhookSecrets,
+		oidcProviders:       oidcProviders,
+		webauthnRP:          webauthnRP,
+		pageViews:           analytics.NewCounter(),
+		visitorSalt:         visitorSalt,
+		trustedProxies:      conf.TrustedProxies,
+		webhookSender: webhook.Sender{
+			Client: webhook.NewClient(10 * time.Second),
+			Prefix: "Chirpy",
+		},
 	}
 
-	h := api.middlewareMetricsInc(http.FileServer(http.Dir(filepathRoot)))
+	// ctx is cancelled on SIGINT or SIGTERM, which stops the workers and
+	// starts draining the server
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	healthChecks := health.NewRegistry()
+	healthChecks.Register("database", checkDatabase(db))
+	healthChecks.Register("schema", checkSchemaVersion(migrator))
+
+	var workers sync.WaitGroup
+	startWorker := func(name string, interval time.Duration, job func(context.Context) error) {
+		heartbeat := workerHeartbeat(interval)
+		healthChecks.Register("worker:"+name, heartbeat.Check)
+		workers.Go(func() { runEvery(ctx, name, interval, heartbeat, job) })
+	}
+	startWorker("purgeDeletedUsers", time.Hour, api.purgeDeletedUsers)
+	startWorker("expireChirpyRed", time.Hour, api.expireChirpyRed)
+	startWorker("purgeExpiredLoginStates", time.Hour, api.purgeExpiredLoginStates)
+	startWorker("processWebhookInbox", 2*time.Second, api.processWebhookInbox)
+	startWorker("publishScheduledChirps", 10*time.Second, api.publishScheduledChirps)
+	startWorker("deliverWebhooks", 2*time.Second, api.deliverWebhooks)
+	startWorker("flushPageViews", time.Minute, api.flushPageViews)
+
+	h := api.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(conf.FilepathRoot))))
 
 	mux := http.NewServeMux()
-	mux.Handle("/app/", http.StripPrefix("/app", h))
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("POST /api/chirps", api.handleChirps)
-	mux.HandleFunc("GET /api/chirps", api.handleGetAllChirps)
-	mux.HandleFunc("DELETE /api/chirps/{chirp_id}", api.handleDeleteChirps)
-	mux.HandleFunc("GET /api/chirps/{chirp_id}", api.handleGetChirp)
-	mux.HandleFunc("POST /api/polka/webhooks", api.handlePolkaWebhook)
-	mux.HandleFunc("POST /api/users", api.handleCreateUser)
-	mux.HandleFunc("PUT /api/users", api.handleUpdateUser)
-	mux.HandleFunc("POST /api/login", api.handleLogin)
-	mux.HandleFunc("POST /api/refresh", api.handleRefreshToken)
-	mux.HandleFunc("POST /api/revoke", api.handleRevokeRefreshToken)
-	mux.HandleFunc("GET /admin/metrics", api.handleMetrics)
-	mux.HandleFunc("POST /admin/reset", api.resetMetrics)
+	mux.Handle("/app/", h)
+	mux.Handle("GET /api/readyz", healthChecks.ReadyHandler())
+	mux.Handle("GET /admin/health", healthChecks.ReportHandler())
+	api.registerRoutes(mux)
+	mux.Handle("GET /metrics", metricsRegistry.Handler())
 
 	srv := &http.Server{
-		Addr:    ":" + port,
-		Handler: mux,
+		Addr:              ":" + strconv.Itoa(conf.Port),
+		Handler:           tracing.Middleware(middlewareAccessLog(newHTTPMetrics(metricsRegistry).middleware(api.middlewareAdmin(mux)))),
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		ReadTimeout:       conf.ReadTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+		MaxHeaderBytes:    conf.MaxHeaderBytes,
 	}
 
-	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Serving files", "root", conf.FilepathRoot, "port", conf.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	// a second signal kills the process straight away
+	stop()
+
+	// fail readiness first and give load balancers time to notice before
+	// the listener closes
+	healthChecks.SetShuttingDown()
+	if conf.ShutdownDelay > 0 {
+		slog.Info("Shutting down, waiting for load balancers", "delay", conf.ShutdownDelay.String())
+		time.Sleep(conf.ShutdownDelay)
+	}
+	slog.Info("Shutting down, draining requests", "timeout", conf.ShutdownTimeout.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error draining requests", "error", err)
+	}
+	workers.Wait()
+
+	// views counted since the last flush would otherwise be lost
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFlush()
+	if err := api.flushPageViews(flushCtx); err != nil {
+		slog.Error("Error flushing page views", "error", err)
+	}
+	if err := db.Close(); err != nil {
+		slog.Error("Error closing database", "error", err)
+	}
+	// export the spans still buffered, including the final flush's
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Error exporting traces", "error", err)
+	}
+	slog.Info("Shutdown complete")
+	return nil
 }
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
-	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(http.StatusText(http.StatusOK)))
+// registerRoutes adds the API and admin routes. The file server, readiness
+// and metrics need the running server's state, so serve adds those.
+func (cfg *apiConfig) registerRoutes(mux *http.ServeMux) {
+	// healthz predates the split and stays for existing probes
+	mux.HandleFunc("GET /api/healthz", handleLivez)
+	mux.HandleFunc("GET /api/livez", handleLivez)
+	mux.HandleFunc("POST /api/chirps", cfg.handleChirps)
+	mux.HandleFunc("GET /api/chirps", cfg.handleGetAllChirps)
+	mux.HandleFunc("GET /api/chirps/scheduled", cfg.handleGetScheduledChirps)
+	mux.HandleFunc("PUT /api/chirps/{chirp_id}", cfg.handleUpdateChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirp_id}", cfg.handleDeleteChirps)
+	mux.HandleFunc("GET /api/chirps/{chirp_id}", cfg.handleGetChirp)
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.handlePolkaWebhook)
+	mux.HandleFunc("POST /api/users", cfg.handleCreateUser)
+	mux.HandleFunc("PUT /api/users", cfg.handleUpdateUser)
+	mux.HandleFunc("DELETE /api/users", cfg.handleDeleteUser)
+	mux.HandleFunc("GET /api/users/export", cfg.handleExportUser)
+	mux.HandleFunc("GET /api/users/me/entitlements", cfg.handleGetEntitlements)
+	mux.HandleFunc("POST /api/login", cfg.handleLogin)
+	mux.HandleFunc("GET /api/auth/{provider}/start", cfg.handleOIDCStart)
+	mux.HandleFunc("GET /api/auth/{provider}/callback", cfg.handleOIDCCallback)
+	mux.HandleFunc("POST /api/passkeys/register/begin", cfg.handlePasskeyRegisterBegin)
+	mux.HandleFunc("POST /api/passkeys/register/finish", cfg.handlePasskeyRegisterFinish)
+	mux.HandleFunc("POST /api/passkeys/login/begin", cfg.handlePasskeyLoginBegin)
+	mux.HandleFunc("POST /api/passkeys/login/finish", cfg.handlePasskeyLoginFinish)
+	mux.HandleFunc("POST /api/oauth/clients", cfg.handleCreateOAuthClient)
+	mux.HandleFunc("GET /api/oauth/clients/{client_id}", cfg.handleGetOAuthClient)
+	mux.HandleFunc("POST /api/oauth/authorize", cfg.handleOAuthAuthorize)
+	mux.HandleFunc("POST /api/oauth/token", cfg.handleOAuthToken)
+	mux.HandleFunc("GET /api/oauth/consents", cfg.handleListOAuthConsents)
+	mux.HandleFunc("DELETE /api/oauth/consents/{client_id}", cfg.handleRevokeOAuthConsent)
+	mux.HandleFunc("POST /api/keys", cfg.handleCreateAPIKey)
+	mux.HandleFunc("GET /api/keys", cfg.handleListAPIKeys)
+	mux.HandleFunc("DELETE /api/keys/{key_id}", cfg.handleRevokeAPIKey)
+	mux.HandleFunc("POST /api/webhooks/endpoints", cfg.handleCreateWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/endpoints", cfg.handleListWebhookEndpoints)
+	mux.HandleFunc("DELETE /api/webhooks/endpoints/{endpoint_id}", cfg.handleDeleteWebhookEndpoint)
+	mux.HandleFunc("POST /api/webhooks/endpoints/{endpoint_id}/enable", cfg.handleEnableWebhookEndpoint)
+	mux.HandleFunc("GET /api/webhooks/endpoints/{endpoint_id}/deliveries", cfg.handleListWebhookDeliveries)
+	mux.HandleFunc("POST /api/refresh", cfg.handleRefreshToken)
+	mux.HandleFunc("POST /api/revoke", cfg.handleRevokeRefreshToken)
+	mux.HandleFunc("GET /admin/metrics", cfg.handleMetrics)
+	mux.HandleFunc("POST /admin/reset", cfg.resetMetrics)
+	mux.HandleFunc("GET /admin/audit-log", cfg.handleListAuditLog)
+	mux.HandleFunc("GET /admin/analytics/page-views", cfg.handlePageViewStats)
+	mux.HandleFunc("GET /admin/users", cfg.handleAdminListUsers)
+	mux.HandleFunc("GET /admin/users/{user_id}", cfg.handleAdminGetUser)
+	mux.HandleFunc("GET /admin/users/{user_id}/chirps", cfg.handleAdminGetUserChirps)
+	mux.HandleFunc("GET /admin/users/{user_id}/sessions", cfg.handleAdminGetUserSessions)
+	mux.HandleFunc("POST /admin/users/{user_id}/suspend", cfg.handleAdminSuspendUser)
+	mux.HandleFunc("POST /admin/users/{user_id}/unsuspend", cfg.handleAdminUnsuspendUser)
+	mux.HandleFunc("POST /admin/users/{user_id}/logout", cfg.handleAdminLogoutUser)
+	mux.HandleFunc("PUT /admin/users/{user_id}/chirpy-red", cfg.handleAdminSetChirpyRed)
+	mux.HandleFunc("GET /admin/webhooks/events", cfg.handleListWebhookEvents)
+	mux.HandleFunc("POST /admin/webhooks/events/{source}/{event_id}/replay", cfg.handleReplayWebhookEvent)
+	mux.HandleFunc("POST /admin/webhooks/endpoints", cfg.handleAdminCreateWebhookEndpoint)
+	mux.HandleFunc("GET /admin/webhooks/endpoints", cfg.handleAdminListWebhookEndpoints)
+	mux.HandleFunc("DELETE /admin/webhooks/endpoints/{endpoint_id}", cfg.handleAdminDeleteWebhookEndpoint)
+	mux.HandleFunc("POST /admin/webhooks/endpoints/{endpoint_id}/enable", cfg.handleAdminEnableWebhookEndpoint)
+	mux.HandleFunc("GET /admin/webhooks/endpoints/{endpoint_id}/deliveries", cfg.handleAdminListWebhookDeliveries)
 }
 
 func cleanMessage(s string) string {
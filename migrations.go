@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mattcollier/boot-go-server/internal/migrate"
+)
+
+//go:embed sql/schema/*.sql
+var schemaFS embed.FS
+
+func newMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	migrations, err := migrate.Load(schemaFS, "sql/schema")
+	if err != nil {
+		return nil, err
+	}
+	return &migrate.Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// runMigrate implements the migrate subcommand.
+func runMigrate(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate up|down|status|redo")
+	}
+	migrator, err := newMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			slog.Info("Applied migration", "migration", m.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			slog.Info("Database is up to date")
+		}
+		return err
+	case "down":
+		m, ok, err := migrator.Down(ctx)
+		if err == nil && !ok {
+			slog.Info("No migrations to roll back")
+		} else if err == nil {
+			slog.Info("Rolled back migration", "migration", m.Name)
+		}
+		return err
+	case "redo":
+		m, ok, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("migrate redo: no migrations have been applied")
+		}
+		slog.Info("Rolled back migration", "migration", m.Name)
+		// only reapply what was rolled back, even if newer ones are pending
+		_, err = migrator.UpTo(ctx, m.Version)
+		if err == nil {
+			slog.Info("Applied migration", "migration", m.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "APPLIED AT\tMIGRATION")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", appliedAt, s.Migration.Name)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("migrate: unknown command %q", args[0])
+}
+
+// prepareSchema applies pending migrations when autoMigrate is set, then
+// refuses to continue if any are still pending: handlers would fail on
+// missing tables and columns.
+func prepareSchema(ctx context.Context, migrator *migrate.Migrator, autoMigrate bool) error {
+	if autoMigrate {
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			slog.InfoContext(ctx, "Applied migration", "migration", m.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind: %d migrations pending, starting with %s; run the migrate up command or enable auto-migrate", len(pending), pending[0].Name)
+	}
+	return nil
+}
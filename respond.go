@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/mattcollier/boot-go-server/internal/logging"
+)
+
+// Error codes are part of the API: clients switch on them, so they must not
+// change once released. The detail that goes with them is for people and
+// may be reworded.
+const (
+	codeInternal                    = "internal_error"
+	codeInvalidJSON                 = "invalid_json"
+	codeValidation                  = "validation_failed"
+	codeBodyTooLarge                = "body_too_large"
+	codeNotFound                    = "not_found"
+	codeForbidden                   = "forbidden"
+	codeUnauthenticated             = "unauthenticated"
+	codeInvalidToken                = "invalid_token"
+	codeInvalidAPIKey               = "invalid_api_key"
+	codeInvalidCredentials          = "invalid_credentials"
+	codeInvalidSignature            = "invalid_signature"
+	codeSessionRevoked              = "session_revoked"
+	codeAccountSuspended            = "account_suspended"
+	codeAccountPendingDeletion      = "account_pending_deletion"
+	codeInsufficientScope           = "insufficient_scope"
+	codeAdminRequired               = "admin_required"
+	codeSubscriptionRequired        = "subscription_required"
+	codeEmailTaken                  = "email_taken"
+	codeConflict                    = "conflict"
+	codeInvalidState                = "invalid_state"
+	codeSignInIncomplete            = "sign_in_incomplete"
+	codePasskeyFailed               = "passkey_failed"
+	codeIdentityProviderError       = "identity_provider_error"
+	codeIdentityProviderUnavailable = "identity_provider_unavailable"
+)
+
+// Field error codes say what was wrong with one field of a request.
+const (
+	fieldRequired = "required"
+	fieldInvalid  = "invalid"
+	fieldTooLong  = "too_long"
+	fieldType     = "invalid_type"
+	fieldUnknown  = "unknown_field"
+)
+
+// maxRequestBodyBytes caps JSON request bodies. Nothing the API accepts
+// comes close.
+const maxRequestBodyBytes = 1 << 20
+
+// problem is an RFC 7807 problem details object. Type is always
+// about:blank, so Title is the status text and Code carries the specific
+// error.
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError describes one invalid field of a request body or query.
+type fieldError struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+// respondJSON writes v as the response body.
+func respondJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error encoding response", "error", err)
+		respondInternalError(w, r)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// respondError writes a problem+json error. The request ID is included so
+// a user reporting an error can be matched to the logs.
+func respondError(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	writeProblem(w, r, problem{Status: status, Code: code, Detail: detail})
+}
+
+// respondInvalid rejects a request with invalid fields. All of them are
+// listed, so a client can show every problem at once.
+func respondInvalid(w http.ResponseWriter, r *http.Request, errs ...fieldError) {
+	detail := fmt.Sprintf("%d fields are invalid", len(errs))
+	if len(errs) == 1 {
+		detail = errs[0].Detail
+	}
+	writeProblem(w, r, problem{Status: 400, Code: codeValidation, Detail: detail, Errors: errs})
+}
+
+// respondInternalError hides the cause of a server error from the client.
+// Callers log it first.
+func respondInternalError(w http.ResponseWriter, r *http.Request) {
+	respondError(w, r, 500, codeInternal, "Something went wrong")
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, p problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Instance = r.URL.Path
+	p.RequestID = logging.RequestID(r.Context())
+	data, err := json.Marshal(p)
+	if err != nil {
+		// only possible if problem gains a field that can't be encoded
+		panic(err)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(data)
+}
+
+// decodeJSON reads a JSON request body into v. Bodies that are malformed,
+// too large or have fields of the wrong type are the client's fault and get
+// a 4xx saying what was wrong. When it returns false the error response has
+// already been written.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	return decodeBody(w, r, v, false)
+}
+
+// decodeJSONStrict is decodeJSON, but also rejects fields v doesn't have.
+func decodeJSONStrict(w http.ResponseWriter, r *http.Request, v any) bool {
+	return decodeBody(w, r, v, true)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any, strict bool) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(v)
+	if err == nil {
+		return true
+	}
+	slog.WarnContext(r.Context(), "Error decoding request body", "error", err)
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		respondError(w, r, 413, codeBodyTooLarge, fmt.Sprintf("Request body must not be larger than %d bytes", maxBytesErr.Limit))
+	case errors.Is(err, io.EOF):
+		respondError(w, r, 400, codeInvalidJSON, "Request body is empty")
+	case errors.As(err, &syntaxErr):
+		respondError(w, r, 400, codeInvalidJSON, fmt.Sprintf("Request body is not valid JSON at offset %d", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		respondError(w, r, 400, codeInvalidJSON, "Request body is not valid JSON")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		respondInvalid(w, r, fieldError{
+			Field:  typeErr.Field,
+			Code:   fieldType,
+			Detail: fmt.Sprintf("%s must be a JSON %s", typeErr.Field, jsonType(typeErr.Type)),
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for DisallowUnknownFields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		respondInvalid(w, r, fieldError{Field: field, Code: fieldUnknown, Detail: "Unknown field " + field})
+	default:
+		// such as a timestamp that isn't RFC 3339
+		respondError(w, r, 400, codeInvalidJSON, "Invalid request body")
+	}
+	return false
+}
+
+// jsonType names a Go type the way a JSON client would.
+func jsonType(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	}
+	return "object"
+}
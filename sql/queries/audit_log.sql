@@ -0,0 +1,15 @@
+-- name: CreateAuditLogEntry :exec
+INSERT INTO admin_audit_log (id, created_at, actor_id, method, path, status_code)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+);
+
+-- name: ListAuditLog :many
+SELECT * FROM admin_audit_log
+ORDER BY created_at DESC
+LIMIT $1;
@@ -1,28 +1,63 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, published)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    COALESCE($3, NOW()),
+    $3 IS NULL
 )
 RETURNING *;
 
 -- name: GetAllChirps :many
 SELECT * FROM chirps
+WHERE publish_at <= NOW()
 ORDER BY created_at ASC;
 
 -- name: GetChirpsByAuthor :many
 SELECT * FROM chirps
-WHERE user_id = $1
+WHERE user_id = $1 AND publish_at <= NOW()
 ORDER BY created_at ASC;
 
+-- name: GetScheduledChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND publish_at > NOW()
+ORDER BY publish_at ASC;
+
 -- name: GetChirp :one
 SELECT * FROM chirps
 WHERE id = $1;
 
+-- name: UpdateChirp :one
+UPDATE chirps
+SET body = $3, updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+RETURNING *;
+
 -- name: DeleteChirp :one
 DELETE FROM chirps
 WHERE id = $1 AND user_id = $2
 RETURNING id;
+
+-- name: ListAllChirps :many
+SELECT * FROM chirps
+ORDER BY created_at ASC;
+
+-- name: ImportChirp :execrows
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, publish_at, published)
+VALUES ($1, $2, $3, $4, $5, $6, $7 OR $6 <= NOW())
+ON CONFLICT (id) DO NOTHING;
+
+-- name: PublishDueChirps :many
+UPDATE chirps
+SET published = true
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE NOT published AND publish_at <= NOW()
+    ORDER BY publish_at ASC
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
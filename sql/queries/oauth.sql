@@ -0,0 +1,69 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, updated_at, name, hashed_secret, redirect_uri, scope, user_id)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code, created_at, expires_at, client_id, user_id, redirect_uri, scope, code_challenge)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+);
+
+-- name: ConsumeOAuthAuthorizationCode :one
+DELETE FROM oauth_authorization_codes
+WHERE code = $1
+RETURNING *;
+
+-- name: UpsertOAuthConsent :one
+INSERT INTO oauth_consents (user_id, client_id, created_at, updated_at, scope, granted_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    NOW(),
+    $3,
+    NOW()
+)
+ON CONFLICT (user_id, client_id)
+DO UPDATE SET
+    scope = EXCLUDED.scope,
+    updated_at = NOW(),
+    -- consenting again after a revocation must not revive older tokens
+    granted_at = CASE WHEN oauth_consents.revoked_at IS NULL THEN oauth_consents.granted_at ELSE NOW() END,
+    revoked_at = NULL
+RETURNING *;
+
+-- name: GetOAuthConsent :one
+SELECT * FROM oauth_consents
+WHERE user_id = $1 AND client_id = $2;
+
+-- name: ListOAuthConsents :many
+SELECT * FROM oauth_consents
+WHERE user_id = $1 AND revoked_at IS NULL
+ORDER BY created_at ASC;
+
+-- name: RevokeOAuthConsent :one
+UPDATE oauth_consents
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND client_id = $2 AND revoked_at IS NULL
+RETURNING client_id;
@@ -0,0 +1,19 @@
+-- name: CreateOIDCLoginState :exec
+INSERT INTO oidc_login_states (state, created_at, expires_at, provider, nonce, code_verifier)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: ConsumeOIDCLoginState :one
+DELETE FROM oidc_login_states
+WHERE state = $1
+RETURNING *;
+
+-- name: DeleteExpiredOIDCLoginStates :execrows
+DELETE FROM oidc_login_states
+WHERE expires_at < NOW();
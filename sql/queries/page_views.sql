@@ -0,0 +1,37 @@
+-- name: UpsertPageView :exec
+INSERT INTO page_views (path, day, views, visitors)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (path, day)
+DO UPDATE SET
+    views = page_views.views + EXCLUDED.views,
+    -- merge the HyperLogLog sketches by taking the larger value of each
+    -- register byte; a sketch in another encoding is replaced
+    visitors = CASE
+        WHEN length(page_views.visitors) <> length(EXCLUDED.visitors) THEN EXCLUDED.visitors
+        ELSE (
+            SELECT decode(string_agg(lpad(to_hex(greatest(get_byte(page_views.visitors, i), get_byte(EXCLUDED.visitors, i))), 2, '0'), '' ORDER BY i), 'hex')
+            FROM generate_series(0, length(EXCLUDED.visitors) - 1) AS i
+        )
+    END;
+
+-- name: ListPageViews :many
+SELECT * FROM page_views
+WHERE day >= $1
+ORDER BY day ASC, path ASC;
+
+-- name: ListPageViewsByPath :many
+SELECT * FROM page_views
+WHERE day >= $1 AND path = $2
+ORDER BY day ASC;
+
+-- name: GetOrCreateVisitorSalt :one
+INSERT INTO analytics_visitor_salt (id, salt, created_at)
+VALUES (TRUE, $1, NOW())
+-- the no-op update makes RETURNING give the stored salt on conflict
+ON CONFLICT (id) DO UPDATE SET id = analytics_visitor_salt.id
+RETURNING salt;
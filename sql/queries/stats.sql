@@ -0,0 +1,33 @@
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: CountChirpyRedUsers :one
+SELECT COUNT(*) FROM users
+WHERE is_chirpy_red;
+
+-- name: CountChirps :one
+SELECT COUNT(*) FROM chirps;
+
+-- name: CountActiveSessions :one
+SELECT COUNT(*) FROM refresh_tokens
+WHERE revoked_at IS NULL AND expires_at > NOW();
+
+-- name: CountChirpsPerDay :many
+SELECT date_trunc('day', created_at)::timestamp AS day, COUNT(*) AS count
+FROM chirps
+WHERE created_at >= $1
+GROUP BY day
+ORDER BY day ASC;
+
+-- name: TopAuthors :many
+SELECT users.id, users.email, COUNT(chirps.id) AS chirp_count
+FROM chirps
+JOIN users ON users.id = chirps.user_id
+GROUP BY users.id, users.email
+ORDER BY chirp_count DESC
+LIMIT $1;
+
+-- name: ListRecentWebhookEvents :many
+SELECT * FROM webhook_events
+ORDER BY received_at DESC
+LIMIT $1;
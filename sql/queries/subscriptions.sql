@@ -0,0 +1,65 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end, last_event_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3,
+    $4
+)
+ON CONFLICT (user_id)
+DO UPDATE SET
+    status = EXCLUDED.status,
+    current_period_end = COALESCE(EXCLUDED.current_period_end, subscriptions.current_period_end),
+    last_event_at = COALESCE(EXCLUDED.last_event_at, subscriptions.last_event_at),
+    updated_at = NOW()
+WHERE EXCLUDED.last_event_at IS NULL
+   OR subscriptions.last_event_at IS NULL
+   OR subscriptions.last_event_at <= EXCLUDED.last_event_at
+RETURNING *;
+
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: SyncChirpyRed :one
+UPDATE users
+SET is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+      AND (
+        (subscriptions.status = 'active' AND (subscriptions.current_period_end IS NULL OR subscriptions.current_period_end > NOW()))
+        OR (subscriptions.status IN ('past_due', 'canceled') AND subscriptions.current_period_end > NOW())
+      )
+)
+WHERE id = $1
+RETURNING is_chirpy_red;
+
+-- name: ExpireLapsedChirpyRed :execrows
+UPDATE users
+SET is_chirpy_red = false
+WHERE is_chirpy_red AND NOT EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+      AND (
+        (subscriptions.status = 'active' AND (subscriptions.current_period_end IS NULL OR subscriptions.current_period_end > NOW()))
+        OR (subscriptions.status IN ('past_due', 'canceled') AND subscriptions.current_period_end > NOW())
+      )
+);
+
+-- name: SetSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    $3
+)
+ON CONFLICT (user_id)
+DO UPDATE SET
+    status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    updated_at = NOW()
+RETURNING *;
@@ -22,8 +22,55 @@ SET email = $2, hashed_password = $3, updated_at = NOW()
 WHERE id = $1
 RETURNING id, created_at, updated_at, email, is_chirpy_red;
 
--- name: UpdateIsChirpyRed :one
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: ScheduleUserDeletion :one
+UPDATE users
+SET deletion_due_at = $2, sessions_revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING deletion_due_at;
+
+-- name: CancelUserDeletion :exec
+UPDATE users
+SET deletion_due_at = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteUsersPastGracePeriod :execrows
+DELETE FROM users
+WHERE deletion_due_at <= NOW();
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUserAuthState :one
+SELECT suspended_at, sessions_revoked_at, deletion_due_at FROM users
+WHERE id = $1;
+
+-- name: SearchUsers :many
+SELECT * FROM users
+WHERE position(lower(sqlc.arg(query)::text) IN lower(email)) > 0
+ORDER BY created_at ASC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- name: SuspendUser :one
+UPDATE users
+SET suspended_at = NOW(), sessions_revoked_at = NOW(), updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UnsuspendUser :one
+UPDATE users
+SET suspended_at = NULL, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: RevokeUserSessions :one
 UPDATE users
-SET is_chirpy_red = $2
+SET sessions_revoked_at = NOW(), updated_at = NOW()
 WHERE id = $1
-RETURNING id;
+RETURNING *;
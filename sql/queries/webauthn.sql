@@ -0,0 +1,42 @@
+-- name: CreateWebauthnCredential :one
+INSERT INTO webauthn_credentials (id, created_at, updated_at, user_id, credential_id, public_key, sign_count, aaguid)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: GetWebauthnCredential :one
+SELECT * FROM webauthn_credentials
+WHERE credential_id = $1;
+
+-- name: ListWebauthnCredentials :many
+SELECT * FROM webauthn_credentials
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: UpdateWebauthnSignCount :exec
+UPDATE webauthn_credentials
+SET sign_count = $2, last_used_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateWebauthnChallenge :exec
+INSERT INTO webauthn_challenges (challenge, created_at, expires_at, ceremony, user_id)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4
+);
+
+-- name: ConsumeWebauthnChallenge :one
+DELETE FROM webauthn_challenges
+WHERE challenge = $1
+RETURNING *;
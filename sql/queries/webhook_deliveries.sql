@@ -0,0 +1,47 @@
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, created_at, endpoint_id, event, payload, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), webhook_endpoints.id, $2, $3, NOW()
+FROM webhook_endpoints
+WHERE webhook_endpoints.disabled_at IS NULL
+  AND (webhook_endpoints.user_id IS NULL OR webhook_endpoints.user_id = $1)
+  AND $2 = ANY(string_to_array(webhook_endpoints.events, ' '));
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET status = 'processing',
+    attempts = webhook_deliveries.attempts + 1,
+    next_attempt_at = NOW() + INTERVAL '5 minutes'
+FROM webhook_endpoints
+WHERE webhook_endpoints.id = webhook_deliveries.endpoint_id
+  AND webhook_deliveries.id IN (
+    SELECT due.id FROM webhook_deliveries due
+    JOIN webhook_endpoints endpoint ON endpoint.id = due.endpoint_id
+    WHERE due.status IN ('pending', 'processing')
+      AND due.next_attempt_at <= NOW()
+      AND endpoint.disabled_at IS NULL
+    ORDER BY due.created_at
+    LIMIT $1
+    FOR UPDATE OF due SKIP LOCKED
+  )
+RETURNING webhook_deliveries.id, webhook_deliveries.endpoint_id, webhook_deliveries.event, webhook_deliveries.payload, webhook_deliveries.attempts, webhook_endpoints.url, webhook_endpoints.secret;
+
+-- name: MarkWebhookDeliverySucceeded :exec
+UPDATE webhook_deliveries
+SET status = 'succeeded', delivered_at = NOW(), last_status_code = $2, last_error = NULL, last_response = $3
+WHERE id = $1;
+
+-- name: RetryWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'pending', next_attempt_at = $2, last_status_code = $3, last_error = $4, last_response = $5
+WHERE id = $1;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries
+SET status = 'failed', last_status_code = $2, last_error = $3, last_response = $4
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
@@ -0,0 +1,45 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE user_id IS NOT DISTINCT FROM $1
+ORDER BY created_at ASC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2;
+
+-- name: DeleteWebhookEndpoint :one
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2
+RETURNING id;
+
+-- name: EnableWebhookEndpoint :one
+UPDATE webhook_endpoints
+SET disabled_at = NULL, consecutive_failures = 0, updated_at = NOW()
+WHERE id = $1 AND user_id IS NOT DISTINCT FROM $2
+RETURNING *;
+
+-- name: RecordWebhookEndpointSuccess :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0
+WHERE id = $1;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1,
+    disabled_at = CASE WHEN consecutive_failures + 1 >= $2 THEN NOW() ELSE disabled_at END,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING disabled_at;
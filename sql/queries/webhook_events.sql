@@ -0,0 +1,54 @@
+-- name: RecordWebhookEvent :one
+INSERT INTO webhook_events (source, id, received_at, event, payload, sent_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (source, id)
+DO UPDATE SET deliveries = webhook_events.deliveries + 1
+RETURNING *;
+
+-- name: ClaimWebhookEvents :many
+UPDATE webhook_events
+SET status = 'processing',
+    attempts = attempts + 1,
+    next_attempt_at = NOW() + INTERVAL '5 minutes'
+WHERE (source, id) IN (
+    SELECT source, id FROM webhook_events
+    WHERE status IN ('pending', 'processing') AND next_attempt_at <= NOW()
+    ORDER BY received_at
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkWebhookEventProcessed :exec
+UPDATE webhook_events
+SET status = 'processed', processed_at = NOW(), last_error = NULL
+WHERE source = $1 AND id = $2;
+
+-- name: RetryWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'pending', next_attempt_at = $3, last_error = $4
+WHERE source = $1 AND id = $2;
+
+-- name: DeadLetterWebhookEvent :exec
+UPDATE webhook_events
+SET status = 'dead', last_error = $3
+WHERE source = $1 AND id = $2;
+
+-- name: ListWebhookEventsByStatus :many
+SELECT * FROM webhook_events
+WHERE status = $1
+ORDER BY received_at DESC
+LIMIT $2;
+
+-- name: ReplayWebhookEvent :one
+UPDATE webhook_events
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), last_error = NULL
+WHERE source = $1 AND id = $2 AND status = 'dead'
+RETURNING *;
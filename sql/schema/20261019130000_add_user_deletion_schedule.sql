@@ -0,0 +1,8 @@
+-- +goose Up
+-- when the account will be purged, not when deletion was requested
+ALTER TABLE users
+  ADD COLUMN deletion_due_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+  DROP COLUMN IF EXISTS deletion_due_at;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS webhook_events (
+  source TEXT NOT NULL,
+  id TEXT NOT NULL,
+  received_at TIMESTAMP NOT NULL,
+  event TEXT NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 1,
+  processed_at TIMESTAMP,
+  PRIMARY KEY (source, id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_events;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS subscriptions (
+  user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  status TEXT NOT NULL,
+  current_period_end TIMESTAMP
+);
+
+-- carry over existing upgrades as open-ended subscriptions
+INSERT INTO subscriptions (user_id, created_at, updated_at, status)
+SELECT id, NOW(), NOW(), 'active' FROM users WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,29 @@
+-- +goose Up
+-- attempts used to count deliveries from the sender; it now counts our own
+-- processing attempts
+ALTER TABLE webhook_events RENAME COLUMN attempts TO deliveries;
+ALTER TABLE webhook_events
+  ADD COLUMN payload BYTEA NOT NULL DEFAULT ''::bytea,
+  ADD COLUMN status TEXT NOT NULL DEFAULT 'pending',
+  ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN last_error TEXT;
+ALTER TABLE webhook_events ALTER COLUMN payload DROP DEFAULT;
+
+UPDATE webhook_events SET status = 'processed' WHERE processed_at IS NOT NULL;
+-- earlier events were not stored, so there is nothing to retry
+UPDATE webhook_events SET status = 'dead', last_error = 'received before payloads were stored'
+WHERE processed_at IS NULL;
+
+CREATE INDEX webhook_events_due_idx ON webhook_events (next_attempt_at)
+WHERE status IN ('pending', 'processing');
+
+-- +goose Down
+DROP INDEX IF EXISTS webhook_events_due_idx;
+ALTER TABLE webhook_events
+  DROP COLUMN payload,
+  DROP COLUMN status,
+  DROP COLUMN attempts,
+  DROP COLUMN next_attempt_at,
+  DROP COLUMN last_error;
+ALTER TABLE webhook_events RENAME COLUMN deliveries TO attempts;
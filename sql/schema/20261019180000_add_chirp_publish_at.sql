@@ -0,0 +1,10 @@
+-- +goose Up
+-- scheduled chirps stay hidden until publish_at
+ALTER TABLE chirps ADD COLUMN publish_at TIMESTAMP;
+UPDATE chirps SET publish_at = created_at;
+ALTER TABLE chirps ALTER COLUMN publish_at SET NOT NULL;
+CREATE INDEX chirps_publish_at_idx ON chirps (publish_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_publish_at_idx;
+ALTER TABLE chirps DROP COLUMN publish_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users
+  ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+  CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+  id UUID PRIMARY KEY,
+  created_at TIMESTAMP NOT NULL,
+  -- kept when the admin's account is deleted
+  actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+  method TEXT NOT NULL,
+  path TEXT NOT NULL,
+  status_code INTEGER NOT NULL
+);
+
+CREATE INDEX admin_audit_log_created_at_idx ON admin_audit_log (created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS admin_audit_log;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE users
+  ADD COLUMN suspended_at TIMESTAMP,
+  -- access tokens issued before this are rejected
+  ADD COLUMN sessions_revoked_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE users
+  DROP COLUMN suspended_at,
+  DROP COLUMN sessions_revoked_at;
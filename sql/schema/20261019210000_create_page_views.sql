@@ -0,0 +1,23 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS page_views (
+  path TEXT NOT NULL,
+  day DATE NOT NULL,
+  views BIGINT NOT NULL,
+  -- HyperLogLog sketch of hashed visitor identifiers; no IPs are stored
+  visitors BYTEA NOT NULL,
+  PRIMARY KEY (path, day)
+);
+
+CREATE INDEX page_views_day_idx ON page_views (day);
+
+-- keys the visitor hashes when analytics.visitor_salt isn't configured; the
+-- single row is generated by the first instance to start
+CREATE TABLE IF NOT EXISTS analytics_visitor_salt (
+  id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+  salt BYTEA NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS analytics_visitor_salt;
+DROP TABLE IF EXISTS page_views;
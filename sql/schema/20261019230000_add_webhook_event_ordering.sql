@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE webhook_events
+  -- when the sender signed the event
+  ADD COLUMN sent_at TIMESTAMP;
+
+ALTER TABLE subscriptions
+  -- sent_at of the newest event applied, so older ones arriving late are
+  -- skipped
+  ADD COLUMN last_event_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE subscriptions
+  DROP COLUMN last_event_at;
+
+ALTER TABLE webhook_events
+  DROP COLUMN sent_at;
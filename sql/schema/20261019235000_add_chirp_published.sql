@@ -0,0 +1,9 @@
+-- +goose Up
+-- set once a chirp is live and chirp.created has been sent for it
+ALTER TABLE chirps ADD COLUMN published BOOLEAN NOT NULL DEFAULT false;
+UPDATE chirps SET published = true WHERE publish_at <= NOW();
+CREATE INDEX chirps_unpublished_idx ON chirps (publish_at) WHERE NOT published;
+
+-- +goose Down
+DROP INDEX IF EXISTS chirps_unpublished_idx;
+ALTER TABLE chirps DROP COLUMN published;
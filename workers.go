@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mattcollier/boot-go-server/internal/health"
+)
+
+// runEvery calls job immediately and then once per interval until ctx is
+// cancelled. Errors are logged and the job is retried on the next tick.
+// Every finished run is recorded on heartbeat for the readiness check.
+func runEvery(ctx context.Context, name string, interval time.Duration, heartbeat *health.Heartbeat, job func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := job(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Background job failed", "job", name, "error", err)
+		}
+		heartbeat.Beat(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// workerHeartbeat returns a heartbeat for a job run every interval. A run
+// may take a while, so it gets a second interval and some slack before it
+// counts as overdue.
+func workerHeartbeat(interval time.Duration) *health.Heartbeat {
+	return health.NewHeartbeat(2*interval + time.Minute)
+}